@@ -0,0 +1,162 @@
+package dell
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Alarm is a set of error / alarm conditions reported by a projector. More than one can be active at once,
+// so check for a particular one with Has
+type Alarm uint
+
+// The alarms we know how to decode
+const (
+	AlarmLampFailure Alarm = 1 << iota
+	AlarmOverTemperature
+	AlarmFanFailure
+	AlarmLampDoorOpen
+	AlarmLampExpired
+	AlarmColorWheelFailure
+)
+
+// alarmNames maps each Alarm to the name used for it in AlarmList and in "alarmraised" / "alarmcleared" events
+var alarmNames = []struct {
+	Alarm Alarm
+	Name  string
+}{
+	{AlarmLampFailure, "LampFailure"},
+	{AlarmOverTemperature, "OverTemperature"},
+	{AlarmFanFailure, "FanFailure"},
+	{AlarmLampDoorOpen, "LampDoorOpen"},
+	{AlarmLampExpired, "LampExpired"},
+	{AlarmColorWheelFailure, "ColorWheelFailure"},
+}
+
+// AlarmList is a JSON object that maps each alarm to the digital join the projector raises when it happens.
+// Joins are written in the same byte order as CommandList.
+//
+// This list is provisional. Dell don't publish the joins, and these haven't been confirmed against a real projector
+// with a real fault, so they may well be wrong for your model. If an alarm never shows up (or the wrong one does), turn
+// on Debug, cause the fault, and see which digital join changes. Then either replace AlarmList or call SetAlarmJoin for
+// just the ones that are wrong. Either way, do it before Init, which is when the list is read. The emulator reads it
+// too (when a FakeProjector starts listening), so fake projectors always raise alarms on whatever joins you've set
+var AlarmList = []byte(`
+  {
+	"LampFailure": "3114",
+	"OverTemperature": "3314",
+	"FanFailure": "5a14",
+	"LampDoorOpen": "5b14",
+	"LampExpired": "5f14",
+	"ColorWheelFailure": "6314"
+}
+`)
+
+// ErrUnknownAlarm is returned by SetAlarmJoin for an alarm we don't know how to decode
+var ErrUnknownAlarm = errors.New("unknown alarm")
+
+// SetAlarmJoin changes the join for a single alarm in AlarmList, e.g. SetAlarmJoin("FanFailure", "5c14"). name is one
+// of the names in AlarmList. Like AlarmList, it needs to be called before Init
+func SetAlarmJoin(name string, join string) error {
+	known := false
+	for _, n := range alarmNames {
+		known = known || n.Name == name
+	}
+	if !known {
+		return ErrUnknownAlarm
+	}
+
+	var ids map[string]string
+	err := json.Unmarshal(AlarmList, &ids)
+	if err != nil {
+		return err
+	}
+	ids[name] = join
+	list, err := json.MarshalIndent(ids, "", "\t")
+	if err != nil {
+		return err
+	}
+	AlarmList = list
+	return nil
+}
+
+// alarms is AlarmList, unmarshalled and flipped around so we can look up an Alarm by its join
+var alarms map[string]Alarm
+
+// loadAlarms unmarshals AlarmList. It's called from Init
+func loadAlarms() error {
+	var ids map[string]string
+	err := json.Unmarshal(AlarmList, &ids)
+	if err != nil {
+		return err
+	}
+
+	alarms = make(map[string]Alarm)
+	for _, a := range alarmNames {
+		if id, ok := ids[a.Name]; ok {
+			alarms[strings.ToLower(id)] = a.Alarm
+		}
+	}
+
+	return nil
+}
+
+// Has tells us whether a particular alarm is active
+func (a Alarm) Has(alarm Alarm) bool {
+	return a&alarm != 0
+}
+
+// Names returns the names of all of the active alarms
+func (a Alarm) Names() []string {
+	var names []string
+	for _, n := range alarmNames {
+		if a.Has(n.Alarm) {
+			names = append(names, n.Name)
+		}
+	}
+	return names
+}
+
+// String lists the active alarms, separated by commas. It's empty if there aren't any
+func (a Alarm) String() string {
+	return strings.Join(a.Names(), ", ")
+}
+
+// MarshalJSON lets Alarm show up as a list of alarm names in JSON
+func (a Alarm) MarshalJSON() ([]byte, error) {
+	names := a.Names()
+	if names == nil {
+		names = []string{}
+	}
+	return json.Marshal(names)
+}
+
+// applyAlarm sets or clears an alarm if the join is one of the joins in AlarmList. It returns false if it isn't
+func applyAlarm(projector *Projector, j join) bool {
+	alarm, ok := alarms[j.ID]
+	if !ok {
+		return false
+	}
+
+	if j.Digital {
+		projector.Alarms |= alarm
+	} else {
+		projector.Alarms &^= alarm
+	}
+	projector.Error = projector.Alarms.String()
+
+	return true
+}
+
+// alarmEvents raises an "alarmraised" or "alarmcleared" event for every alarm that has changed.
+// The name of the alarm is passed in the event's Detail
+func alarmEvents(before Alarm, projector Projector) {
+	for _, n := range alarmNames {
+		switch {
+		case projector.Alarms.Has(n.Alarm) && !before.Has(n.Alarm):
+			passDetail("alarmraised", projector, n.Name)
+		case !projector.Alarms.Has(n.Alarm) && before.Has(n.Alarm):
+			passDetail("alarmcleared", projector, n.Name)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package api exposes the projectors in dell.Projectors over HTTP, so they can be controlled by anything that can
+// make a web request (like a room booking or scheduling system).
+//
+// Use it like any other http.Handler:
+//
+//	http.ListenAndServe(":8080", api.NewHandler())
+//
+// An OpenAPI description of every route is served at /openapi.json
+//
+// Projectors whose connection has dropped are taken out of dell.Projectors, but the handler remembers them (from the
+// "projectorremoved" event), so they're still listed with Online false, and anything asked of one gets a 503 rather
+// than a 404. A 404 means we've never heard of the projector at all.
+//
+// Routes are registered with the method and wildcard patterns (e.g. "GET /projectors/{uuid}") that http.ServeMux
+// gained in Go 1.22, so this package needs Go 1.22 or later. Older versions would treat each pattern as a literal path
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/Grayda/go-dell"
+)
+
+// route is a single entry in our route table. The same table is used to set up the handler and to generate
+// the OpenAPI description, so the two can't get out of step
+type route struct {
+	Method  string
+	Path    string
+	Summary string
+	Body    interface{} // An example of the request body, if there is one
+	Handler http.HandlerFunc
+}
+
+// PowerRequest is the body of PUT /projectors/{uuid}/power
+type PowerRequest struct {
+	On bool `json:"on"`
+}
+
+// InputRequest is the body of PUT /projectors/{uuid}/input
+type InputRequest struct {
+	Input string `json:"input"` // One of the names from dell.Commands.Input, e.g. "HDMI"
+}
+
+// VolumeRequest is the body of PUT /projectors/{uuid}/volume. Both fields are optional
+type VolumeRequest struct {
+	Muted  *bool `json:"muted,omitempty"`
+	Change int   `json:"change,omitempty"` // How many steps to turn the volume up (or down, if it's negative)
+}
+
+// Status is what we return for a single projector: everything in dell.Projector, plus a few things worked out from it
+type Status struct {
+	dell.Projector
+	Online        bool
+	LampRemaining int
+}
+
+// routes is our route table. It's filled in by init, because /openapi.json is itself generated from the table
+var routes []route
+
+func init() {
+	routes = []route{
+		{"GET", "/projectors", "List every projector", nil, listProjectors},
+		{"GET", "/projectors/{uuid}", "Get the full decoded status of a projector", nil, getProjector},
+		{"POST", "/projectors/{uuid}/commands/{name}", "Send a command (e.g. Power.On) to a projector", nil, sendCommand},
+		{"PUT", "/projectors/{uuid}/power", "Turn a projector on or off", PowerRequest{On: true}, setPower},
+		{"PUT", "/projectors/{uuid}/input", "Change a projector's input", InputRequest{Input: "HDMI"}, setInput},
+		{"PUT", "/projectors/{uuid}/volume", "Mute, unmute, or change the volume of a projector", VolumeRequest{Change: 2}, setVolume},
+		{"GET", "/commands", "List every command that can be sent", nil, listCommands},
+		{"GET", "/openapi.json", "Get this API's OpenAPI description", nil, openAPI},
+	}
+}
+
+// offline holds the last we knew of every projector that's been removed from dell.Projectors, by UUID
+var offline = make(map[string]dell.Projector)
+var offlineLock sync.Mutex
+
+// watching makes sure there's only ever one watchRemovals, however many handlers there are
+var watching sync.Once
+
+// watchRemovals keeps offline up to date. A projector is added when it's removed from dell.Projectors, and forgotten
+// again once it's back
+func watchRemovals() {
+	events, _ := dell.Subscribe()
+	go func() {
+		for e := range events {
+			offlineLock.Lock()
+			switch e.Name {
+			case "projectorremoved":
+				projector := e.ProjectorInfo
+				projector.Conn, projector.Driver = nil, nil
+				offline[projector.UUID] = projector
+			case "projectoradded":
+				delete(offline, e.ProjectorInfo.UUID)
+			}
+			offlineLock.Unlock()
+		}
+	}()
+}
+
+// NewHandler returns an http.Handler that serves the API
+func NewHandler() http.Handler {
+	watching.Do(watchRemovals)
+	mux := http.NewServeMux()
+	for _, r := range routes {
+		mux.HandleFunc(r.Method+" "+r.Path, r.Handler)
+	}
+	return mux
+}
+
+// status builds the Status for a projector
+func status(projector dell.Projector) Status {
+	return Status{
+		Projector:     projector,
+		Online:        projector.Connected(),
+		LampRemaining: dell.LampRemaining(projector),
+	}
+}
+
+func listProjectors(w http.ResponseWriter, r *http.Request) {
+	list := []Status{}
+	for _, projector := range dell.ListProjectors() {
+		list = append(list, status(projector))
+	}
+	offlineLock.Lock()
+	for _, projector := range offline {
+		if _, ok := dell.GetProjector(projector.UUID); !ok {
+			list = append(list, status(projector))
+		}
+	}
+	offlineLock.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].UUID < list[j].UUID })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func getProjector(w http.ResponseWriter, r *http.Request) {
+	projector, ok := lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, status(projector))
+}
+
+func sendCommand(w http.ResponseWriter, r *http.Request) {
+	projector, ok := lookup(w, r)
+	if !ok {
+		return
+	}
+	_, err := dell.SendNamedCommand(projector, r.PathValue("name"))
+	result(w, err)
+}
+
+func setPower(w http.ResponseWriter, r *http.Request) {
+	var body PowerRequest
+	projector, ok := lookupWithBody(w, r, &body)
+	if !ok {
+		return
+	}
+	_, err := dell.SetPower(projector, body.On)
+	result(w, err)
+}
+
+func setInput(w http.ResponseWriter, r *http.Request) {
+	var body InputRequest
+	projector, ok := lookupWithBody(w, r, &body)
+	if !ok {
+		return
+	}
+	_, err := dell.SetInput(projector, body.Input)
+	if errors.Is(err, dell.ErrUnknownCommand) {
+		// The route's fine, it's what was asked for that's wrong
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown input %q (see dell.InputNames)", body.Input))
+		return
+	}
+	result(w, err)
+}
+
+func setVolume(w http.ResponseWriter, r *http.Request) {
+	var body VolumeRequest
+	projector, ok := lookupWithBody(w, r, &body)
+	if !ok {
+		return
+	}
+
+	var err error
+	if body.Muted != nil {
+		_, err = dell.SetMute(projector, *body.Muted)
+	}
+	if err == nil && body.Change != 0 {
+		_, err = dell.ChangeVolume(projector, body.Change)
+	}
+	result(w, err)
+}
+
+func listCommands(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, dell.CommandNames())
+}
+
+func openAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, OpenAPI())
+}
+
+// lookup finds the projector named in the URL. If it's offline it writes a 503 with the last we knew of it, and if
+// we've never heard of it it writes a 404
+func lookup(w http.ResponseWriter, r *http.Request) (dell.Projector, bool) {
+	uuid := r.PathValue("uuid")
+	projector, ok := dell.GetProjector(uuid)
+	if ok {
+		return projector, true
+	}
+
+	offlineLock.Lock()
+	projector, known := offline[uuid]
+	offlineLock.Unlock()
+	if known {
+		writeJSON(w, http.StatusServiceUnavailable, struct {
+			Error string `json:"error"`
+			Status
+		}{dell.ErrOffline.Error(), status(projector)})
+	} else {
+		writeError(w, http.StatusNotFound, errors.New("no projector with that UUID"))
+	}
+	return projector, false
+}
+
+// lookupWithBody is lookup, but also decodes the request body into body
+func lookupWithBody(w http.ResponseWriter, r *http.Request, body interface{}) (dell.Projector, bool) {
+	projector, ok := lookup(w, r)
+	if !ok {
+		return projector, false
+	}
+	err := json.NewDecoder(r.Body).Decode(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return projector, false
+	}
+	return projector, true
+}
+
+// result writes the result of sending a command, turning any error into the matching status code
+func result(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusAccepted, map[string]bool{"sent": true})
+	case errors.Is(err, dell.ErrUnknownCommand):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, dell.ErrOffline):
+		writeError(w, http.StatusServiceUnavailable, err)
+	case errors.Is(err, dell.ErrUnsupported):
+		writeError(w, http.StatusUnprocessableEntity, err)
+	case errors.Is(err, dell.ErrCoolingDown):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// pathParams finds the {parameters} in a route's path
+var pathParams = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPI builds an OpenAPI 3 description of the API from the route table
+func OpenAPI() map[string]interface{} {
+	paths := make(map[string]interface{})
+
+	for _, r := range routes {
+		operation := map[string]interface{}{
+			"summary":   r.Summary,
+			"responses": responses(r),
+		}
+
+		var params []interface{}
+		for _, match := range pathParams.FindAllStringSubmatch(r.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]string{"type": "string"},
+			})
+		}
+		if params != nil {
+			operation["parameters"] = params
+		}
+
+		if r.Body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema":  schema(reflect.TypeOf(r.Body)),
+						"example": r.Body,
+					},
+				},
+			}
+		}
+
+		item, ok := paths[r.Path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[r.Path] = item
+		}
+		item[strings.ToLower(r.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]string{
+			"title":   "go-dell",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+	}
+}
+
+// responses lists the status codes a route can return
+func responses(r route) map[string]interface{} {
+	describe := func(text string) map[string]string { return map[string]string{"description": text} }
+
+	if r.Method == "GET" {
+		list := map[string]interface{}{"200": describe("OK")}
+		if strings.Contains(r.Path, "{uuid}") {
+			list["404"] = describe("No projector with that UUID")
+			list["503"] = describe("The projector is offline. The body is the last status we had")
+		}
+		return list
+	}
+
+	list := map[string]interface{}{
+		"202": describe("The command was sent (or will be, once the projector has warmed up)"),
+		"404": describe("No projector with that UUID, or no command with that name"),
+		"409": describe("The projector is cooling down"),
+		"422": describe("The projector's model doesn't support that command"),
+		"503": describe("The projector is offline"),
+	}
+	if r.Body != nil {
+		list["400"] = describe("The request body couldn't be read")
+	}
+	if _, ok := r.Body.(InputRequest); ok {
+		list["400"] = describe("The request body couldn't be read, or names an input that doesn't exist")
+	}
+	return list
+}
+
+// schema builds a JSON schema for a request body type
+func schema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if name == "" {
+				name = t.Field(i).Name
+			}
+			properties[name] = schema(t.Field(i).Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,126 @@
+package dell
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// MulticastAddr is where projectors send their DDDP beacons
+var MulticastAddr = "239.255.250.250:9131"
+
+// OriginTag is added to beacons by the relay package (if its AddOrigin is set), and holds the address of the projector
+// that sent the beacon (the beacon itself comes from the relay). If a beacon from one of TrustedRelays has one, it's
+// where we connect to
+const OriginTag = "Origin"
+
+// TrustedRelays are the addresses of the relays (see the relay package) whose Origin tags we believe. Beacons from
+// anywhere else are taken to come from wherever they came from, whatever their Origin says, so that nobody else on the
+// network can send us off to connect to an address of their choosing. Set it before listening for beacons
+var TrustedRelays []string
+
+// trustedRelay tells us whether a beacon from ip came through one of TrustedRelays
+func trustedRelay(ip net.IP) bool {
+	for _, relay := range TrustedRelays {
+		if trusted := net.ParseIP(relay); trusted != nil && trusted.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// BeaconSource is somewhere DDDP beacons come from. Listen uses multicast, which is what the projectors do, but
+// multicast is blocked in a lot of containers, CI sandboxes and Wi-Fi networks, so ListenOn can use any source
+type BeaconSource interface {
+	// ReadBeacon waits for the next beacon, and returns it along with the address of whoever sent it.
+	// It returns an error once the source has been closed
+	ReadBeacon() ([]byte, net.IP, error)
+	Close() error
+}
+
+// ErrSourceClosed is returned by a ChannelSource once it's been closed
+var ErrSourceClosed = errors.New("beacon source closed")
+
+// UDPSource reads beacons from a UDP socket. It's used for both multicast and unicast
+type UDPSource struct {
+	conn  *net.UDPConn
+	iface *net.Interface // Set if the source was made by MulticastSourceOn
+}
+
+// MulticastSource listens for beacons on a multicast address, usually MulticastAddr. The operating system picks
+// which interface to join the group on. Use MulticastSourceOn to choose
+func MulticastSource(addr string) (*UDPSource, error) {
+	return MulticastSourceOn(addr, nil)
+}
+
+// UnicastSource listens for beacons sent straight to us, e.g. on "127.0.0.1:9131" or ":9131". The emulator can
+// beacon to it, and so can a DDDP relay on a network where multicast doesn't make it to us
+func UnicastSource(addr string) (*UDPSource, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPSource{conn: conn}, nil
+}
+
+func (s *UDPSource) ReadBeacon() ([]byte, net.IP, error) {
+	buf := make([]byte, 1024)
+	n, addr, err := s.conn.ReadFromUDP(buf)
+	if err != nil {
+		return nil, nil, err
+	}
+	return buf[:n], addr.IP, nil
+}
+
+func (s *UDPSource) Close() error {
+	return s.conn.Close()
+}
+
+// Addr is the address the source is listening on, which is handy if you asked for port 0
+func (s *UDPSource) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// RawBeacon is a beacon as it arrived, before it's been parsed
+type RawBeacon struct {
+	Data []byte
+	IP   net.IP // Who sent it. This is the address we'll connect to
+}
+
+// ChannelSource is a BeaconSource that's fed by your own code, so discovery can be tested without a network at all
+type ChannelSource struct {
+	beacons chan RawBeacon
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewChannelSource makes an empty ChannelSource
+func NewChannelSource() *ChannelSource {
+	return &ChannelSource{beacons: make(chan RawBeacon, 100), done: make(chan struct{})}
+}
+
+// Send passes a beacon to whoever's listening, as if it came from ip
+func (c *ChannelSource) Send(data []byte, ip string) {
+	select {
+	case c.beacons <- RawBeacon{Data: data, IP: net.ParseIP(ip)}:
+	case <-c.done:
+	}
+}
+
+func (c *ChannelSource) ReadBeacon() ([]byte, net.IP, error) {
+	select {
+	case b := <-c.beacons:
+		return b.Data, b.IP, nil
+	case <-c.done:
+		return nil, nil, ErrSourceClosed
+	}
+}
+
+func (c *ChannelSource) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
@@ -0,0 +1,145 @@
+package dell
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+// CIPPort is the TCP port the projectors listen for Crestron CIP on, used if Projector.Port isn't set
+var CIPPort = 41794
+
+// ConnectTimeout is how long we wait for a projector to accept a connection before giving up on it
+var ConnectTimeout = 5 * time.Second
+
+// cipDriver is the Driver for the projectors' own protocol, Crestron CIP. Commands are the hex codes in Commands,
+// and feedback is decoded by handleMessage
+type cipDriver struct {
+	projector Projector
+	rest      []byte // Anything that arrived along with the connect response, for Read to handle first
+}
+
+// cipConnectResponse is the packet a CIP device answers connectRequest with
+const cipConnectResponse = 0x02
+
+// ErrHandshake is returned when a projector doesn't answer our connect request, or turns us down
+var ErrHandshake = errors.New("projector didn't accept our CIP connect request")
+
+func (c *cipDriver) Connect(projector Projector) (net.Conn, error) {
+	port := projector.Port
+	if port == 0 {
+		port = CIPPort
+	}
+	// If we know which interface the projector was found on, go out through it
+	dialer := net.Dialer{Timeout: ConnectTimeout}
+	if local := localAddr(projector.Interface, net.ParseIP(projector.IP)); local != nil {
+		dialer.LocalAddr = local
+	}
+	conn, err := dialer.Dial("tcp", net.JoinHostPort(projector.IP, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	// The projector ignores everything we send until we've registered with it
+	conn.SetReadDeadline(time.Now().Add(ConnectTimeout))
+	rest, ok := handshake(conn)
+	conn.SetReadDeadline(time.Time{})
+	if !ok {
+		conn.Close()
+		return nil, ErrHandshake
+	}
+	c.rest = rest
+	c.projector = projector
+	c.projector.Conn = conn
+	return conn, nil
+}
+
+func (c *cipDriver) Read(projector Projector) error {
+	if c.rest != nil {
+		rest := handleMessage(c.rest, projector)
+		c.rest = nil
+		projectorsLock.Lock()
+		buffers[projector.UUID] = rest
+		projectorsLock.Unlock()
+	}
+	_, err := readTCP(projector)
+	return err
+}
+
+// handshake sends a CIP connect request and waits for the connect response. ok is false if the other end doesn't
+// answer with one, or turns us down. A projector might ask us to register (0x0f) as soon as we connect, and can send
+// a heartbeat at any time, so other packets before the response are skipped. Anything read after the response is
+// returned, so it isn't lost
+func handshake(conn net.Conn) (rest []byte, ok bool) {
+	request, _ := hex.DecodeString(connectRequest)
+	_, err := conn.Write(request)
+	if err != nil {
+		return nil, false
+	}
+
+	var buf []byte
+	chunk := make([]byte, 512)
+	for {
+		for len(buf) >= 3 {
+			length := int(buf[1])<<8 | int(buf[2])
+			if len(buf) < 3+length {
+				break
+			}
+			packetType, payload := buf[0], buf[3:3+length]
+			buf = buf[3+length:]
+			if packetType == cipConnectResponse {
+				// Success is 00 00 00 <IP ID>. A projector that won't have us answers ff ff 02
+				return buf, len(payload) >= 4 && payload[0] != 0xff
+			}
+		}
+		if len(buf) > 4096 {
+			// Whatever this is, it isn't a CIP device saying hello
+			return nil, false
+		}
+		n, err := conn.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if err != nil {
+			return nil, false
+		}
+	}
+}
+
+func (c *cipDriver) Close() error {
+	if c.projector.Conn == nil {
+		return nil
+	}
+	return c.projector.Conn.Close()
+}
+
+func (c *cipDriver) PowerOn() error {
+	return c.Send(Commands.Power.On)
+}
+
+func (c *cipDriver) PowerOff() error {
+	return c.Send(Commands.Power.Off)
+}
+
+func (c *cipDriver) SetInput(input string) error {
+	command, ok := LookupCommand("Input." + input)
+	if !ok {
+		return ErrUnknownCommand
+	}
+	return c.Send(command)
+}
+
+func (c *cipDriver) Mute(muted bool) error {
+	if muted {
+		return c.Send(Commands.Volume.Mute)
+	}
+	return c.Send(Commands.Volume.Unmute)
+}
+
+func (c *cipDriver) Status() error {
+	return sendRaw(statusRequest, c.projector)
+}
+
+func (c *cipDriver) Send(command string) error {
+	return sendRaw(commandPrefix+command, c.projector)
+}
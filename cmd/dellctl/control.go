@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// status prints everything a projector knows about itself
+func status(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: dellctl status <ip|uuid>")
+	}
+
+	projector, err := connect(args[0])
+	if err != nil {
+		return err
+	}
+	projector = waitForStatus(projector)
+
+	if jsonOutput {
+		printJSON(struct {
+			dell.Projector
+			LampRemaining int
+		}{projector, dell.LampRemaining(projector)})
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", projector.Name)
+	fmt.Fprintf(w, "UUID:\t%s\n", projector.UUID)
+	fmt.Fprintf(w, "IP:\t%s\n", projector.IP)
+	fmt.Fprintf(w, "Make / Model:\t%s %s\n", projector.Make, projector.Model)
+	fmt.Fprintf(w, "Location:\t%s\n", projector.Location)
+	fmt.Fprintf(w, "Power:\t%s\n", projector.PowerState)
+	fmt.Fprintf(w, "Source:\t%s\n", projector.Source)
+	fmt.Fprintf(w, "Resolution:\t%s\n", projector.Resolution)
+	fmt.Fprintf(w, "Volume muted:\t%t\n", projector.VolumeMuted)
+	fmt.Fprintf(w, "Picture muted:\t%t\n", projector.PictureMuted)
+	fmt.Fprintf(w, "Frozen:\t%t\n", projector.Frozen)
+	fmt.Fprintf(w, "Lamp:\t%d hours (%s mode, %d hours remaining)\n", projector.LampHours, projector.LampMode, dell.LampRemaining(projector))
+	fmt.Fprintf(w, "Alarms:\t%s\n", projector.Alarms)
+	return w.Flush()
+}
+
+// send sends a named command (e.g. Power.On) to a projector
+func send(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: dellctl send <ip|uuid> <command>")
+	}
+
+	command, ok := dell.LookupCommand(args[1])
+	if !ok {
+		return fmt.Errorf("unknown command %q (see dellctl commands)", args[1])
+	}
+
+	projector, err := connect(args[0])
+	if err != nil {
+		return err
+	}
+
+	// Find out whether it's warming up or cooling down first, so the command can be held back or refused if need be
+	projector = waitForStatus(projector)
+
+	events, unsubscribe := dell.Subscribe()
+	defer unsubscribe()
+	_, err = dell.SendCommand(projector, command)
+	if err != nil {
+		return err
+	}
+
+	// SendCommand says yes whether the command went straight out or was held back until the projector has warmed up,
+	// so the events tell us which. A held back command is only sent if we're still running once it's warmed up, so
+	// wait for that rather than claiming it was sent
+	deferred := false
+	give := time.After(dell.WarmUpTime + timeout)
+	for sent := false; !sent; {
+		select {
+		case e := <-events:
+			if e.ProjectorInfo.UUID != projector.UUID {
+				continue
+			}
+			switch {
+			case e.Name == "commanddeferred" && e.Detail == command:
+				deferred = true
+				if !jsonOutput {
+					fmt.Println("Deferred", args[1], "until", projector.IP, "has warmed up..")
+				}
+			case e.Name == "commandsent" && e.Detail == command:
+				sent = true
+			case e.Name == "commandfailed" && deferred:
+				return fmt.Errorf("%s was deferred, then failed: %s", args[1], e.Detail)
+			}
+		case <-give:
+			return fmt.Errorf("%s was deferred until %s has warmed up, and still hadn't been sent after %s", args[1], projector.IP, dell.WarmUpTime+timeout)
+		}
+	}
+
+	if jsonOutput {
+		printJSON(map[string]interface{}{"uuid": projector.UUID, "ip": projector.IP, "command": args[1], "sent": true, "deferred": deferred})
+	} else {
+		fmt.Println("Sent", args[1], "to", projector.IP)
+	}
+	return nil
+}
+
+// raw sends raw hex to a projector
+func raw(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: dellctl raw <ip|uuid> <hex>")
+	}
+
+	_, err := hex.DecodeString(args[1])
+	if err != nil {
+		return fmt.Errorf("%q isn't valid hex: %v", args[1], err)
+	}
+
+	projector, err := connect(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = dell.WriteRaw(args[1], projector)
+	if err != nil {
+		return fmt.Errorf("unable to send %s to %s: %v", args[1], projector.IP, err)
+	}
+
+	if jsonOutput {
+		printJSON(map[string]interface{}{"uuid": projector.UUID, "ip": projector.IP, "raw": args[1], "sent": true})
+	} else {
+		fmt.Println("Sent", args[1], "to", projector.IP)
+	}
+	return nil
+}
+
+// watch prints feedback from a projector as it comes in. The projector is asked for its status every few seconds,
+// because it doesn't tell us about everything on its own
+func watch(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: dellctl watch <ip|uuid>")
+	}
+
+	projector, err := connect(args[0])
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	dell.GetStatus(projector)
+
+	for {
+		select {
+		case <-ticker.C:
+			dell.GetStatus(projector)
+		case msg := <-dell.Events:
+			if msg.ProjectorInfo.UUID != projector.UUID {
+				continue
+			}
+			printEvent(msg)
+			if msg.Name == "projectorremoved" {
+				return errors.New("projector disconnected")
+			}
+		}
+	}
+}
+
+// printEvent prints a single event. For property changes we also print the new value of the property
+func printEvent(msg dell.EventStruct) {
+	var value interface{}
+	if msg.Name == "propertychanged" {
+		value, _ = propertyValue(msg.ProjectorInfo, msg.Detail)
+	}
+
+	if jsonOutput {
+		printJSON(map[string]interface{}{
+			"time":   time.Now().Format(time.RFC3339),
+			"event":  msg.Name,
+			"uuid":   msg.ProjectorInfo.UUID,
+			"detail": msg.Detail,
+			"value":  value,
+		})
+		return
+	}
+
+	line := time.Now().Format("15:04:05") + " " + msg.Name
+	if msg.Detail != "" {
+		line += " " + msg.Detail
+	}
+	if value != nil {
+		line += fmt.Sprintf(" = %v", value)
+	}
+	fmt.Println(line)
+}
+
+// propertyValue looks up a field of a projector by name, for printing "propertychanged" events
+func propertyValue(projector dell.Projector, name string) (interface{}, bool) {
+	field := reflect.ValueOf(projector).FieldByName(name)
+	if !field.IsValid() {
+		return nil, false
+	}
+	return field.Interface(), true
+}
+
+// commands lists every command that can be used with send
+func commands(args []string) error {
+	names := dell.CommandNames()
+	if jsonOutput {
+		list := make(map[string]string)
+		for _, name := range names {
+			list[name], _ = dell.LookupCommand(name)
+		}
+		printJSON(list)
+		return nil
+	}
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// discover listens for DDDP beacons for a while, then prints every projector it heard from
+func discover(args []string) error {
+	flags := flag.NewFlagSet("discover", flag.ExitOnError)
+	duration := flags.Duration("duration", 35*time.Second, "how long to listen for (projectors announce themselves every 30 seconds or so)")
+	flags.Parse(args)
+
+	found := make(map[string]dell.Projector)
+	errs := listen()
+	done := time.After(*duration)
+
+	for waiting := true; waiting; {
+		select {
+		case err := <-errs:
+			return err
+		case <-done:
+			waiting = false
+		case msg := <-dell.Events:
+			if msg.Name != "projectorfound" {
+				continue
+			}
+			if _, seen := found[msg.ProjectorInfo.UUID]; !seen && !jsonOutput {
+				fmt.Fprintln(os.Stderr, "Found", msg.ProjectorInfo.UUID, "at", msg.ProjectorInfo.IP)
+			}
+			found[msg.ProjectorInfo.UUID] = msg.ProjectorInfo
+		}
+	}
+
+	list := make([]dell.Projector, 0, len(found))
+	for _, projector := range found {
+		list = append(list, projector)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].IP < list[j].IP })
+	if err := remember(list); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		printJSON(list)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UUID\tIP\tMAKE\tMODEL\tREVISION")
+	for _, p := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.UUID, p.IP, p.Make, p.Model, p.Revision)
+	}
+	return w.Flush()
+}
+
+// scan probes every address in a range for projectors, for networks where beacons don't get through
+func scan(args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: dellctl scan <cidr>")
+	}
+
+	// -timeout is how long each address gets to answer
+	dell.ScanTimeout = timeout
+	list, err := dell.Scan(context.Background(), flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].IP < list[j].IP })
+	if err := remember(list); err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		printJSON(list)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "UUID\tIP\tNAME\tREVISION")
+	for _, p := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.UUID, p.IP, p.Name, p.Revision)
+	}
+	return w.Flush()
+}
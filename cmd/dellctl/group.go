@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/inventory"
+)
+
+// group sends a named command to a group of projectors from the inventory, picked out by a tag query
+// (tag:building-c,!spare), a room (room:Lecture Theatre 2) or a list of UUIDs (uuid:ABC,DEF)
+func group(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: dellctl -inventory file group <tag:a,!b|room:name|uuid:a,b> <command>")
+	}
+	if inv == nil {
+		return errors.New("group needs an -inventory file to find projectors in")
+	}
+	if _, ok := dell.LookupCommand(args[1]); !ok {
+		return fmt.Errorf("unknown command %q (see dellctl commands)", args[1])
+	}
+
+	kind, value, _ := strings.Cut(args[0], ":")
+	var g dell.Group
+	switch kind {
+	case "tag":
+		g = inv.TagGroup(args[0], strings.Split(value, ",")...)
+	case "room":
+		g = inv.RoomGroup(args[0], value)
+	case "uuid":
+		g = dell.Group{Name: args[0], UUIDs: strings.Split(value, ",")}
+	default:
+		return fmt.Errorf("unknown group %q (use tag:, room: or uuid:)", args[0])
+	}
+
+	connectMembers(g)
+	report := g.SendNamedCommand(args[1])
+
+	if jsonOutput {
+		printJSON(report)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "UUID\tIP\tRESULT\tERROR")
+		for _, r := range report.Results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.UUID, r.IP, r.Status, r.Error)
+		}
+		w.Flush()
+	}
+	if !report.OK() {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// connectMembers connects to every projector in the group that's in the inventory, all at once. Ones that can't be
+// connected to are left out, and so end up reported as offline
+func connectMembers(g dell.Group) {
+	var wait sync.WaitGroup
+	for _, member := range g.Members() {
+		entry, ok := inv.Get(member.UUID)
+		if !ok {
+			continue
+		}
+		wait.Add(1)
+		go func(entry inventory.Entry) {
+			defer wait.Done()
+			projector, err := entry.Projector()
+			if err == nil {
+				_, err = dell.AddProjector(projector)
+			}
+			if err != nil && !jsonOutput {
+				fmt.Fprintln(os.Stderr, "Couldn't connect to", entry.UUID, ":", err)
+			}
+		}(entry)
+	}
+	wait.Wait()
+}
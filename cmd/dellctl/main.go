@@ -0,0 +1,293 @@
+// dellctl discovers and controls Dell projectors from the command line.
+//
+// Usage:
+//
+//	dellctl [-json] [-timeout 5s] [-debug] [-protocol cip|pjlink] [-password secret] [-beacons addr] [-interface eth1,10.20.0.0/16] [-relays 10.20.0.5] [-inventory file] <command> [arguments]
+//
+// The commands are:
+//
+//	discover [-duration 35s]        listen for projectors and print a table of what was found
+//	scan <cidr>                     look for projectors by connecting to every address in a range
+//	relay <interface|addr>...       pass beacons on to another interface or to other addresses
+//	status <ip|uuid>                print everything the projector knows about itself
+//	send <ip|uuid> <command>        send a command such as Power.On or Input.HDMI
+//	group <query> <command>         send a command to every projector in the inventory matching tag:, room: or uuid:
+//	raw <ip|uuid> <hex>             send raw hex to the projector
+//	watch <ip|uuid>                 print feedback from the projector as it arrives
+//	shell [-record file] <ip|uuid>  an interactive remote, with the arrow keys mapped to the menu
+//	replay <ip|uuid> <script>       run a script recorded with shell -record
+//	commands                        list the commands that can be used with send
+//
+// Projectors are talked to with Crestron CIP unless -protocol says otherwise. PJLink projectors have to be given by IP
+// address, as they don't send DDDP beacons.
+//
+// Beacons are listened for on the usual multicast address, unless -beacons gives a UDP address (e.g. ":9131") to
+// listen for them on instead, for networks where multicast doesn't get through. On a server with more than one network
+// card, -interface picks which ones (by name or CIDR, separated by commas) to listen for beacons on. relay listens on
+// the first of them, and passes what it hears on to the networks that can't hear the projectors themselves. Where
+// beacons come through a relay started with -origin, -relays lists the relays' addresses so that we believe them
+// about where the projectors are.
+//
+// With -inventory, projectors found by discover and scan are added to an inventory file (JSON, or YAML if it ends in
+// .yaml), and projectors given by UUID are looked up in it before waiting for their beacon
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/inventory"
+	_ "github.com/Grayda/go-dell/pjlink" // Registers the pjlink protocol
+)
+
+// jsonOutput is set by -json, and makes every command print JSON instead of text (handy for scripts)
+var jsonOutput bool
+
+// timeout is how long we'll wait for a projector to connect, be discovered or answer
+var timeout time.Duration
+
+// protocol and password are set by -protocol and -password, and are used when connecting to a projector by IP address
+var protocol, password string
+
+// beacons is set by -beacons. It's where we listen for DDDP beacons, if it isn't multicast
+var beacons string
+
+// interfaces is set by -interface. It's the interfaces to listen for multicast beacons on, separated by commas
+var interfaces string
+
+// inv is the inventory file given by -inventory, or nil if there isn't one
+var inv *inventory.Inventory
+
+func main() {
+	flag.BoolVar(&jsonOutput, "json", false, "print JSON instead of text")
+	flag.DurationVar(&timeout, "timeout", 5*time.Second, "how long to wait for a projector")
+	flag.BoolVar(&dell.Debug, "debug", false, "print what's being sent and received")
+	flag.StringVar(&protocol, "protocol", dell.ProtocolCIP, "the protocol to talk to the projector with (cip or pjlink)")
+	flag.StringVar(&password, "password", "", "the projector's password, if it has one (PJLink only)")
+	flag.StringVar(&beacons, "beacons", "multicast", "where to listen for beacons: multicast, or a UDP address such as :9131")
+	flag.StringVar(&interfaces, "interface", "", "the interfaces to listen for multicast beacons on, by name or CIDR (e.g. eth1,10.20.0.0/16)")
+	inventoryPath := flag.String("inventory", "", "an inventory file to add found projectors to, and look projectors up in")
+	relays := flag.String("relays", "", "the addresses of relays (started with relay -origin) to believe about where projectors are, separated by commas")
+	flag.Usage = usage
+	flag.Parse()
+	if *relays != "" {
+		dell.TrustedRelays = strings.Split(*relays, ",")
+	}
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+
+	_, err := dell.Init()
+	if err != nil {
+		fail(err)
+	}
+	if *inventoryPath != "" {
+		inv, err = inventory.Load(*inventoryPath)
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "discover":
+		err = discover(args)
+	case "scan":
+		err = scan(args)
+	case "relay":
+		err = relayBeacons(args)
+	case "status":
+		err = status(args)
+	case "send":
+		err = send(args)
+	case "group":
+		err = group(args)
+	case "raw":
+		err = raw(args)
+	case "watch":
+		err = watch(args)
+	case "shell":
+		err = shell(args)
+	case "replay":
+		err = replay(args)
+	case "commands":
+		err = commands(args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fail(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: dellctl [-json] [-timeout 5s] [-debug] [-beacons addr] [-interface list] [-relays list] [-inventory file] <command> [arguments]
+
+Commands:
+  discover [-duration 35s]        listen for projectors and print a table of what was found
+  scan <cidr>                     look for projectors by connecting to every address in a range
+  relay <interface|addr>...       pass beacons on to another interface or to other addresses
+  status <ip|uuid>                print everything the projector knows about itself
+  send <ip|uuid> <command>        send a command such as Power.On or Input.HDMI
+  group <query> <command>         send a command to every projector in the inventory matching tag:, room: or uuid:
+  raw <ip|uuid> <hex>             send raw hex to the projector
+  watch <ip|uuid>                 print feedback from the projector as it arrives
+  shell [-record file] <ip|uuid>  an interactive remote, with the arrow keys mapped to the menu
+  replay <ip|uuid> <script>       run a script recorded with shell -record
+  commands                        list the commands that can be used with send
+
+Flags:`)
+	flag.PrintDefaults()
+}
+
+// fail prints an error and exits
+func fail(err error) {
+	if jsonOutput {
+		printJSON(map[string]string{"error": err.Error()})
+	} else {
+		fmt.Fprintln(os.Stderr, "dellctl:", err)
+	}
+	os.Exit(1)
+}
+
+// printJSON prints v to stdout as a single line of JSON
+func printJSON(v interface{}) {
+	out, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dellctl:", err)
+		return
+	}
+	fmt.Println(string(out))
+}
+
+// connect connects to a projector. target can be an IP address, in which case we connect straight to it,
+// or a UUID, in which case we listen for the projector's DDDP beacon first to find out its IP address
+func connect(target string) (dell.Projector, error) {
+	projector := dell.Projector{UUID: target, IP: target, Protocol: protocol, Password: password}
+
+	if entry, ok := lookup(target); ok {
+		listed, err := entry.Projector()
+		if err != nil {
+			return dell.Projector{}, err
+		}
+		projector = listed
+	} else if net.ParseIP(target) == nil {
+		found, err := findProjector(target)
+		if err != nil {
+			return dell.Projector{}, err
+		}
+		projector = found
+	}
+
+	_, err := dell.AddProjector(projector)
+	if err != nil {
+		return dell.Projector{}, err
+	}
+
+	connected, ok := dell.GetProjector(projector.UUID)
+	if !ok {
+		return dell.Projector{}, errors.New("projector disconnected")
+	}
+	return connected, nil
+}
+
+// lookup finds a projector in the inventory, if there is one
+func lookup(uuid string) (inventory.Entry, bool) {
+	if inv == nil {
+		return inventory.Entry{}, false
+	}
+	return inv.Get(uuid)
+}
+
+// remember adds projectors to the inventory (if there is one) and saves it, warning about any conflicts
+func remember(list []dell.Projector) error {
+	if inv == nil {
+		return nil
+	}
+	for _, projector := range list {
+		inv.Merge(projector)
+	}
+	for _, c := range inv.Conflicts() {
+		fmt.Fprintln(os.Stderr, "dellctl: inventory conflict:", c)
+	}
+	return inv.Save()
+}
+
+// findProjector listens for DDDP beacons until it hears from the projector with the given UUID
+func findProjector(uuid string) (dell.Projector, error) {
+	errs := listen()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case err := <-errs:
+			return dell.Projector{}, err
+		case <-deadline:
+			return dell.Projector{}, fmt.Errorf("couldn't find projector %s (try a longer -timeout, projectors only announce themselves every 30 seconds or so)", uuid)
+		case msg := <-dell.Events:
+			if msg.Name == "projectorfound" && msg.ProjectorInfo.UUID == uuid {
+				return msg.ProjectorInfo, nil
+			}
+		}
+	}
+}
+
+// listen starts listening for DDDP beacons in the background. Listen only returns if something goes wrong,
+// so any error is passed back on the channel
+func listen() chan error {
+	errs := make(chan error, 1)
+	go func() {
+		var err error
+		if beacons == "multicast" && interfaces != "" {
+			_, err = dell.ListenInterfaces(strings.Split(interfaces, ",")...)
+		} else if beacons == "multicast" {
+			_, err = dell.Listen()
+		} else {
+			var source *dell.UDPSource
+			source, err = dell.UnicastSource(beacons)
+			if err == nil {
+				_, err = dell.ListenOn(source)
+			}
+		}
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return errs
+}
+
+// statusQuiet is how long a projector has to go without telling us anything before waitForStatus decides it's finished
+var statusQuiet = 500 * time.Millisecond
+
+// waitForStatus asks a projector for its status, then waits until it's gone quiet (or we hit the timeout)
+func waitForStatus(projector dell.Projector) dell.Projector {
+	dell.GetStatus(projector)
+
+	deadline := time.After(timeout)
+	quiet := time.NewTimer(statusQuiet)
+	defer quiet.Stop()
+	for {
+		select {
+		case <-deadline:
+			updated, _ := dell.GetProjector(projector.UUID)
+			return updated
+		case <-quiet.C:
+			updated, _ := dell.GetProjector(projector.UUID)
+			return updated
+		case msg := <-dell.Events:
+			if msg.ProjectorInfo.UUID == projector.UUID && msg.Name == "propertychanged" {
+				quiet.Reset(statusQuiet)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/relay"
+)
+
+// relayBeacons passes beacons heard on -interface (or -beacons) on to another interface, or to a list of addresses.
+// Each destination is either a UDP address such as 10.30.0.5:9131, or an interface (by name or CIDR) to multicast on
+func relayBeacons(args []string) error {
+	flags := flag.NewFlagSet("relay", flag.ExitOnError)
+	interval := flags.Duration("interval", 5*time.Second, "the least time between relayed beacons from the same projector")
+	rate := flags.Int("rate", 0, "the most beacons to relay each second (0 for no limit)")
+	origin := flags.Bool("origin", false, "add an Origin tag with the projector's address, for dellctl -relays on the other side")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		return errors.New("usage: dellctl relay [-interval 5s] [-rate n] [-origin] <interface|addr>...")
+	}
+
+	r := &relay.Relay{MinInterval: *interval, MaxPerSecond: *rate, AddOrigin: *origin}
+	for _, to := range flags.Args() {
+		if _, _, err := net.SplitHostPort(to); err == nil {
+			r.Targets = append(r.Targets, to)
+			continue
+		}
+		if r.Interface != nil {
+			return errors.New("beacons can only be relayed to one interface")
+		}
+		iface, err := dell.FindInterface(to)
+		if err != nil {
+			return err
+		}
+		r.Interface = iface
+	}
+
+	source, err := beaconSource()
+	if err != nil {
+		return err
+	}
+	r.Source = source
+
+	if !jsonOutput {
+		fmt.Fprintln(os.Stderr, "Relaying beacons to", strings.Join(flags.Args(), ", "))
+	}
+	return r.Run()
+}
+
+// beaconSource is where the relay hears beacons from: the first interface in -interface, or -beacons
+func beaconSource() (dell.BeaconSource, error) {
+	if beacons != "multicast" {
+		return dell.UnicastSource(beacons)
+	}
+	if interfaces == "" {
+		return dell.MulticastSource(dell.MulticastAddr)
+	}
+	iface, err := dell.FindInterface(strings.Split(interfaces, ",")[0])
+	if err != nil {
+		return nil, err
+	}
+	return dell.MulticastSourceOn(dell.MulticastAddr, iface)
+}
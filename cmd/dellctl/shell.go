@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// shellWords are the things you can type in the shell (and in scripts) on top of the command names
+var shellWords = []string{"help", "quit", "raw", "sleep", "status"}
+
+// shell is an interactive remote for a single projector. The arrow keys walk through the projector's menu,
+// Enter on its own presses OK, and anything else you type is run as a command (with Tab completion).
+// Feedback from the projector is printed as it comes in, and the session can be recorded as a script for replay
+func shell(args []string) error {
+	flags := flag.NewFlagSet("shell", flag.ExitOnError)
+	record := flags.String("record", "", "record the session as a script that can be run with dellctl replay")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: dellctl shell [-record file] <ip|uuid>")
+	}
+
+	projector, err := connect(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	s := &session{projector: projector, out: os.Stdout, newline: "\n"}
+	if *record != "" {
+		f, err := os.Create(*record)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		fmt.Fprintf(f, "# dellctl script recorded %s\n", time.Now().Format(time.RFC1123))
+		s.script = f
+	}
+
+	// Put the terminal into raw mode so we get the arrow keys as they're pressed. If we can't (e.g. on Windows,
+	// or if stdin isn't a terminal), we'll fall back to reading a line at a time
+	restore, raw := rawTerminal()
+	if raw {
+		defer restore()
+		s.newline = "\r\n"
+	}
+
+	s.println("Connected to " + projector.IP + ". Arrow keys move around the menu, Enter presses OK, Tab completes, type help for more")
+	dell.GetStatus(projector)
+	go s.feedback()
+
+	if raw {
+		return s.readKeys(os.Stdin)
+	}
+	return s.readLines(os.Stdin)
+}
+
+// replay runs a script recorded by dellctl shell -record (or written by hand) against a projector
+func replay(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: dellctl replay <ip|uuid> <script>")
+	}
+
+	f, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	projector, err := connect(args[0])
+	if err != nil {
+		return err
+	}
+
+	s := &session{projector: projector, out: os.Stdout, newline: "\n"}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s.println("> " + line)
+		quit, err := s.run(line)
+		if err != nil {
+			return err
+		}
+		if quit {
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+// session is a single shell (or replay) connected to one projector
+type session struct {
+	projector dell.Projector
+	out       io.Writer
+	newline   string
+	prompt    string
+	line      []byte
+
+	script   io.Writer // Where we record to, if we're recording
+	lastStep time.Time // When the last recorded step happened, so we can record the pauses in between
+
+	lock sync.Mutex // Because feedback is printed from another goroutine
+}
+
+// println prints a line without trampling over whatever is being typed at the prompt
+func (s *session) println(text string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.prompt == "" {
+		fmt.Fprint(s.out, text+s.newline)
+		return
+	}
+	fmt.Fprint(s.out, "\r\x1b[K"+text+s.newline+s.prompt+string(s.line))
+}
+
+// redraw prints the prompt and whatever has been typed so far
+func (s *session) redraw() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	fmt.Fprint(s.out, "\r\x1b[K"+s.prompt+string(s.line))
+}
+
+// feedback prints events from the projector as they arrive
+func (s *session) feedback() {
+	for msg := range dell.Events {
+		if msg.ProjectorInfo.UUID != s.projector.UUID || msg.Name == "commandsent" {
+			continue
+		}
+		line := msg.Name
+		if msg.Name == "propertychanged" {
+			line = msg.Detail + " changed"
+			if value, ok := propertyValue(msg.ProjectorInfo, msg.Detail); ok {
+				line = fmt.Sprintf("%s = %v", msg.Detail, value)
+			}
+		} else if msg.Detail != "" {
+			line += " " + msg.Detail
+		}
+		s.println("  " + line)
+	}
+}
+
+// escapeTimeout is how long we wait after an Escape for the rest of an arrow key. Terminals send the whole sequence
+// at once, so if nothing else arrives by then, Escape was pressed on its own
+var escapeTimeout = 50 * time.Millisecond
+
+// arrows maps the letter at the end of an arrow key's escape sequence to the command it presses
+var arrows = map[byte]string{'A': "Menu.Up", 'B': "Menu.Down", 'C': "Menu.Right", 'D': "Menu.Left"}
+
+// readKeys reads key presses from a terminal in raw mode
+func (s *session) readKeys(in io.Reader) error {
+	// Keys are read in the background, so we can stop waiting for the rest of an arrow key that isn't coming
+	keys := make(chan byte, 16)
+	go func() {
+		defer close(keys)
+		reader := bufio.NewReader(in)
+		for {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return
+			}
+			keys <- b
+		}
+	}()
+	next := func() (byte, bool) {
+		select {
+		case b, ok := <-keys:
+			return b, ok
+		case <-time.After(escapeTimeout):
+			return 0, false
+		}
+	}
+
+	s.lock.Lock()
+	s.prompt = "dell> "
+	s.lock.Unlock()
+	s.redraw()
+
+	for {
+		b, ok := <-keys
+		if !ok {
+			return nil
+		}
+		for ok && b == 27 {
+			b, ok = s.escape(next)
+		}
+		if ok && s.key(b) {
+			return nil
+		}
+	}
+}
+
+// escape handles whatever follows an Escape. Arrow keys come through as Escape, [ and then a letter (or Escape, O and
+// a letter when the terminal is in application cursor mode), and Escape on its own presses Menu, like the button on
+// the remote. Anything else is handed back (ok is true) to be treated as a key in its own right
+func (s *session) escape(next func() (byte, bool)) (b byte, ok bool) {
+	b, ok = next()
+	if !ok {
+		s.press("Menu.Menu")
+		return 0, false
+	}
+	switch b {
+	case 27:
+		// Escape twice. The first was on its own, and the second may be the start of something
+		s.press("Menu.Menu")
+		return b, true
+	case 'O':
+		key, _ := next()
+		if name, found := arrows[key]; found {
+			s.press(name)
+		}
+		return 0, false
+	case '[':
+		// Skip over any parameters (e.g. the 1;5 in Ctrl+Up) to get to the letter at the end
+		key, more := next()
+		for more && key >= 0x20 && key < 0x40 {
+			key, more = next()
+		}
+		if name, found := arrows[key]; found {
+			s.press(name)
+		}
+		return 0, false
+	}
+	return b, true
+}
+
+// key handles a single key press that isn't part of an escape sequence. It returns true if the shell should end
+func (s *session) key(b byte) bool {
+	switch b {
+	case 3, 4: // Ctrl+C, Ctrl+D
+		s.println("")
+		return true
+	case 9: // Tab
+		s.complete()
+	case 127, 8: // Backspace
+		s.lock.Lock()
+		if len(s.line) > 0 {
+			s.line = s.line[:len(s.line)-1]
+		}
+		s.lock.Unlock()
+		s.redraw()
+	case '\r', '\n':
+		s.lock.Lock()
+		line := strings.TrimSpace(string(s.line))
+		s.line = nil
+		prompt := s.prompt
+		s.lock.Unlock()
+		if line == "" {
+			// Enter on its own presses OK, because that's the most common thing you'll do in a menu
+			line = "Menu.OK"
+		}
+		s.println(prompt + line)
+		quit, err := s.run(line)
+		if err != nil {
+			s.println("  error: " + err.Error())
+		}
+		return quit
+	default:
+		if b >= 32 && b < 127 {
+			s.lock.Lock()
+			s.line = append(s.line, b)
+			s.lock.Unlock()
+			s.redraw()
+		}
+	}
+	return false
+}
+
+// press sends the command for a key, and prints it (and any error) as if it had been typed
+func (s *session) press(name string) {
+	s.lock.Lock()
+	prompt := s.prompt
+	s.lock.Unlock()
+	s.println(prompt + name)
+	if _, err := s.run(name); err != nil {
+		s.println("  error: " + err.Error())
+	}
+}
+
+// readLines reads a line at a time, for when we can't put the terminal into raw mode
+func (s *session) readLines(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(s.out, "dell> ")
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			quit, err := s.run(line)
+			if err != nil {
+				s.println("  error: " + err.Error())
+			}
+			if quit {
+				return nil
+			}
+		}
+		fmt.Fprint(s.out, "dell> ")
+	}
+	return scanner.Err()
+}
+
+// complete does Tab completion over the command names. If there's more than one match, they're all printed and
+// the line is filled in as far as they have in common
+func (s *session) complete() {
+	s.lock.Lock()
+	line := string(s.line)
+	s.lock.Unlock()
+	typed := strings.ToLower(line)
+	var matches []string
+	for _, name := range append(dell.CommandNames(), shellWords...) {
+		if strings.HasPrefix(strings.ToLower(name), typed) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+
+	switch len(matches) {
+	case 0:
+		return
+	case 1:
+		line = matches[0]
+	default:
+		prefix := matches[0]
+		for _, m := range matches[1:] {
+			for !strings.HasPrefix(strings.ToLower(m), strings.ToLower(prefix)) {
+				prefix = prefix[:len(prefix)-1]
+			}
+		}
+		if len(prefix) > len(line) {
+			line = prefix
+		}
+		s.println("  " + strings.Join(matches, "  "))
+	}
+	s.lock.Lock()
+	s.line = []byte(line)
+	s.lock.Unlock()
+	s.redraw()
+}
+
+// run runs a single line from the shell or a script. It returns true if the line was "quit"
+func (s *session) run(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "quit", "exit":
+		return true, nil
+	case "help":
+		s.println("  Arrow keys: Menu.Up/Down/Left/Right. Enter on its own: Menu.OK. Escape: Menu.Menu")
+		s.println("  <command>      send a command, e.g. Power.On (Tab completes)")
+		s.println("  raw <hex>      send raw hex")
+		s.println("  sleep <time>   wait, e.g. sleep 500ms")
+		s.println("  status         ask the projector for its status")
+		s.println("  quit           leave the shell")
+		return false, nil
+	case "status":
+		s.recordStep(line)
+		dell.GetStatus(s.projector)
+		return false, nil
+	case "sleep":
+		if len(fields) != 2 {
+			return false, errors.New("usage: sleep <time>")
+		}
+		d, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return false, err
+		}
+		time.Sleep(d)
+		return false, nil
+	case "raw":
+		if len(fields) != 2 {
+			return false, errors.New("usage: raw <hex>")
+		}
+		if _, err := hex.DecodeString(fields[1]); err != nil {
+			return false, err
+		}
+		s.recordStep(line)
+		return false, dell.WriteRaw(fields[1], s.projector)
+	}
+
+	command, ok := dell.LookupCommand(fields[0])
+	if !ok {
+		return false, fmt.Errorf("unknown command %q (type help, or press Tab)", fields[0])
+	}
+	s.recordStep(fields[0])
+	_, err := dell.SendCommand(s.projector, command)
+	return false, err
+}
+
+// recordStep writes a step to the script, with a sleep beforehand so that the replay keeps the same pace
+func (s *session) recordStep(line string) {
+	if s.script == nil {
+		return
+	}
+	now := time.Now()
+	if !s.lastStep.IsZero() {
+		if pause := now.Sub(s.lastStep).Round(100 * time.Millisecond); pause > 0 {
+			fmt.Fprintln(s.script, "sleep", pause)
+		}
+	}
+	s.lastStep = now
+	fmt.Fprintln(s.script, line)
+}
+
+// rawTerminal puts the terminal into raw mode using stty, and returns a function to put it back the way it was
+func rawTerminal() (func(), bool) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+
+	saved, err := stty("-g")
+	if err != nil {
+		return func() {}, false
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return func() {}, false
+	}
+	return func() { stty(saved) }, true
+}
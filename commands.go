@@ -0,0 +1,76 @@
+package dell
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CommandNames lists every command in Commands using dot notation, e.g. "Power.On" or "Picture.Contrast.Up"
+func CommandNames() []string {
+	var names []string
+	walkCommands(reflect.ValueOf(Commands), "", func(name string, _ string) {
+		names = append(names, name)
+	})
+	sort.Strings(names)
+	return names
+}
+
+// LookupCommand finds a command by its dotted name (e.g. "Input.HDMI") and returns its hex code.
+// The name isn't case sensitive, so "input.hdmi" works too
+func LookupCommand(name string) (string, bool) {
+	var found string
+	walkCommands(reflect.ValueOf(Commands), "", func(n string, command string) {
+		if strings.EqualFold(n, name) {
+			found = command
+		}
+	})
+	return found, found != ""
+}
+
+// CommandName is the opposite of LookupCommand: it finds the dotted name of a command's hex code (e.g. "Power.On" for
+// Commands.Power.On). It returns an empty string if the command isn't in Commands
+func CommandName(command string) string {
+	var found string
+	walkCommands(reflect.ValueOf(Commands), "", func(name string, c string) {
+		if c == command && found == "" {
+			found = name
+		}
+	})
+	return found
+}
+
+// walkCommands goes through the Command struct and calls fn with the name and hex code of each command it finds
+func walkCommands(v reflect.Value, prefix string, fn func(name string, command string)) {
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Struct:
+			walkCommands(field, name, fn)
+		case reflect.String:
+			if field.String() != "" {
+				fn(name, field.String())
+			}
+		}
+	}
+}
+
+// changedProperties compares two copies of a projector and returns the names of the fields that are different
+func changedProperties(before Projector, after Projector) []string {
+	var changed []string
+	b, a := reflect.ValueOf(before), reflect.ValueOf(after)
+	for i := 0; i < b.NumField(); i++ {
+		if name := b.Type().Field(i).Name; name == "Conn" || name == "Driver" {
+			continue
+		}
+		if b.Field(i).Interface() != a.Field(i).Interface() {
+			changed = append(changed, b.Type().Field(i).Name)
+		}
+	}
+	return changed
+}
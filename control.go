@@ -0,0 +1,116 @@
+package dell
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrOffline is returned when a command is sent to a projector that we aren't connected to
+var ErrOffline = errors.New("projector is offline")
+
+// ErrUnsupported is returned when a command is sent to a projector whose model doesn't support it
+var ErrUnsupported = errors.New("command is not supported by this model")
+
+// ErrUnknownCommand is returned when a command name can't be found in Commands
+var ErrUnknownCommand = errors.New("unknown command")
+
+// Supports tells us whether a projector's model supports a command, going by the Unsupported list in ModelList
+func Supports(projector Projector, name string) bool {
+	for _, unsupported := range ModelInfo(projector).Unsupported {
+		if strings.EqualFold(unsupported, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// SendNamedCommand sends a command by its dotted name (e.g. "Input.HDMI"), after checking that the projector's
+// model supports it
+func SendNamedCommand(projector Projector, name string) (bool, error) {
+	command, ok := LookupCommand(name)
+	if !ok {
+		return false, ErrUnknownCommand
+	}
+	if !Supports(projector, name) {
+		return false, ErrUnsupported
+	}
+	return SendCommand(projector, command)
+}
+
+// SetPower turns a projector on or off
+func SetPower(projector Projector, on bool) (bool, error) {
+	if on {
+		return SendNamedCommand(projector, "Power.On")
+	}
+	return SendNamedCommand(projector, "Power.Off")
+}
+
+// SetInput changes a projector's input. input is one of the names from Commands.Input, such as "HDMI" or "VGAA"
+func SetInput(projector Projector, input string) (bool, error) {
+	return SendNamedCommand(projector, "Input."+input)
+}
+
+// SetMute mutes or unmutes a projector's volume
+func SetMute(projector Projector, muted bool) (bool, error) {
+	if muted {
+		return SendNamedCommand(projector, "Volume.Mute")
+	}
+	return SendNamedCommand(projector, "Volume.Unmute")
+}
+
+// SetPictureMute blanks or unblanks a projector's picture
+func SetPictureMute(projector Projector, muted bool) (bool, error) {
+	if muted {
+		return SendNamedCommand(projector, "Picture.Mute")
+	}
+	return SendNamedCommand(projector, "Picture.Unmute")
+}
+
+// ChangeVolume turns the volume up (if steps is positive) or down (if it's negative) by a number of steps
+func ChangeVolume(projector Projector, steps int) (bool, error) {
+	name := "Volume.Up"
+	if steps < 0 {
+		name = "Volume.Down"
+		steps = -steps
+	}
+	for i := 0; i < steps; i++ {
+		_, err := SendNamedCommand(projector, name)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// InputNames lists the inputs that can be passed to SetInput
+func InputNames() []string {
+	var inputs []string
+	for _, name := range CommandNames() {
+		if strings.HasPrefix(name, "Input.") {
+			inputs = append(inputs, strings.TrimPrefix(name, "Input."))
+		}
+	}
+	return inputs
+}
+
+// InputForSource turns the source a projector reports (e.g. "VGA-A" or "Composite Video") into one of the names
+// from InputNames (e.g. "VGAA" or "Composite"). It returns an empty string if there's no match
+func InputForSource(source string) string {
+	simplify := func(s string) string {
+		return strings.ToUpper(strings.Map(func(r rune) rune {
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return -1
+		}, s))
+	}
+
+	source = simplify(source)
+	best := ""
+	for _, input := range InputNames() {
+		if strings.HasPrefix(source, simplify(input)) && len(input) > len(best) {
+			best = input
+		}
+	}
+	return best
+}
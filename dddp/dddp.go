@@ -0,0 +1,171 @@
+// Package dddp reads and writes AMX DDDP (Dynamic Device Discovery Protocol) beacons, which is how the projectors
+// announce themselves. A beacon looks like this:
+//
+//	AMXB<-UUID=DEADBEEF><-SDKClass=VideoProjector><-Make=Dell><-Model=S300wi><-Revision=1.0.3>
+//
+// It's the letters AMXB, followed by a tag for each property, written as <-Name=Value>. A value ends at the next "><-"
+// (or the > at the very end), so values can have > in them, as long as it's not followed by <-
+package dddp
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// Header is what every beacon starts with
+const Header = "AMXB"
+
+// ClassVideoProjector is the SDKClass projectors announce themselves with
+const ClassVideoProjector = "VideoProjector"
+
+// ErrNotDDDP is returned by Parse when a packet doesn't start with Header
+var ErrNotDDDP = errors.New("dddp: not a DDDP beacon")
+
+// ErrMalformed is returned by Parse when a packet starts with Header, but its tags don't make sense
+var ErrMalformed = errors.New("dddp: malformed beacon")
+
+// ErrUnencodable is returned by Marshal when a tag can't be written in a way that Parse would read back
+var ErrUnencodable = errors.New("dddp: tag can't be encoded")
+
+// Beacon is a parsed DDDP beacon. The tags we know about have their own fields, and anything else is kept in Tags
+// (in the order it arrived), so nothing is lost if the beacon is marshalled again
+type Beacon struct {
+	UUID       string
+	SDKClass   string
+	Make       string
+	Model      string
+	Revision   string
+	ConfigName string // The Config-Name tag
+	ConfigURL  string // The Config-URL tag
+	Tags       []Tag
+}
+
+// Tag is a single <-Name=Value> property
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// IsProjector tells us whether the beacon came from a projector, rather than some other AMX device
+func (b Beacon) IsProjector() bool {
+	return b.SDKClass == ClassVideoProjector
+}
+
+// Get returns the value of any tag, whether it has its own field or not. The name is case sensitive, like it is on the wire
+func (b Beacon) Get(name string) (string, bool) {
+	if field := b.field(name); field != nil {
+		return *field, *field != ""
+	}
+	for _, t := range b.Tags {
+		if t.Name == name {
+			return t.Value, true
+		}
+	}
+	return "", false
+}
+
+// field returns the field for one of the tags we know about, or nil if it isn't one
+func (b *Beacon) field(name string) *string {
+	switch name {
+	case "UUID":
+		return &b.UUID
+	case "SDKClass":
+		return &b.SDKClass
+	case "Make":
+		return &b.Make
+	case "Model":
+		return &b.Model
+	case "Revision":
+		return &b.Revision
+	case "Config-Name":
+		return &b.ConfigName
+	case "Config-URL":
+		return &b.ConfigURL
+	}
+	return nil
+}
+
+// known is the order the tags with their own fields are marshalled in
+var known = []string{"SDKClass", "UUID", "Make", "Model", "Revision", "Config-Name", "Config-URL"}
+
+// Parse reads a beacon. Trailing NULs and whitespace (which some devices pad their packets with) are ignored.
+// If a tag we know about appears more than once, the last one wins
+func Parse(data []byte) (Beacon, error) {
+	var b Beacon
+	if !bytes.HasPrefix(data, []byte(Header)) {
+		return b, ErrNotDDDP
+	}
+	rest := strings.TrimRight(string(data[len(Header):]), "\x00 \t\r\n")
+
+	for rest != "" {
+		if !strings.HasPrefix(rest, "<-") {
+			return Beacon{}, ErrMalformed
+		}
+		rest = rest[2:]
+
+		equals := strings.IndexByte(rest, '=')
+		if equals < 1 {
+			return Beacon{}, ErrMalformed
+		}
+		name := rest[:equals]
+		rest = rest[equals+1:]
+
+		var value string
+		if end := strings.Index(rest, "><-"); end >= 0 {
+			value, rest = rest[:end], rest[end+1:]
+		} else if strings.HasSuffix(rest, ">") {
+			value, rest = rest[:len(rest)-1], ""
+		} else {
+			return Beacon{}, ErrMalformed
+		}
+		if strings.Contains(name, ">") {
+			return Beacon{}, ErrMalformed
+		}
+
+		if field := b.field(name); field != nil {
+			*field = value
+		} else {
+			b.Tags = append(b.Tags, Tag{name, value})
+		}
+	}
+	return b, nil
+}
+
+// Marshal writes a beacon. Tags with their own fields come first (and are left out if they're empty), followed by Tags
+func Marshal(b Beacon) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(Header)
+
+	write := func(name string, value string) error {
+		if !encodable(name, value) {
+			return ErrUnencodable
+		}
+		buf.WriteString("<-" + name + "=" + value + ">")
+		return nil
+	}
+
+	for _, name := range known {
+		if value := *b.field(name); value != "" {
+			if err := write(name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, t := range b.Tags {
+		if b.field(t.Name) != nil {
+			// It'd be read back into its field, not Tags
+			return nil, ErrUnencodable
+		}
+		if err := write(t.Name, t.Value); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// encodable tells us whether a tag would survive being parsed again. Names can't have = or > in them, and values
+// can't have "><-" in them, because that's where the next tag would start
+func encodable(name string, value string) bool {
+	return name != "" && !strings.ContainsAny(name, "=>") && !strings.Contains(value, "><-")
+}
@@ -4,12 +4,13 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net"
-	"os"
-	"regexp"
-	"strings"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/Grayda/go-dell/dddp"
 )
 
 // EventStruct is our equivalent to node.js's Emitters, of sorts.
@@ -18,23 +19,33 @@ import (
 type EventStruct struct {
 	Name          string
 	ProjectorInfo Projector
+	Detail        string // Extra information for some events, such as which alarm was raised
 }
 
-// Events is our events channel which will notify calling code that we have an event happening
-var Events = make(chan EventStruct, 1)
+// Events is our events channel which will notify calling code that we have an event happening.
+// It's buffered so that a burst of events (e.g. from a status dump) isn't lost while the calling code catches up
+var Events = make(chan EventStruct, 100)
+
+// Debug prints out what we're sending and receiving, to help track down problems
+var Debug = false
 
 // Projector holds information about our Projectors
 // Is there a neater way to do this?
 type Projector struct {
-	Conn     net.Conn
-	IP       string
-	Name     string
-	UUID     string // AKA MAC Address
-	Model    string
-	Make     string
-	Revision string
+	Conn      net.Conn `json:"-"`
+	Driver    Driver   `json:"-"`
+	Protocol  string   // Which Driver to use. Defaults to ProtocolCIP
+	Password  string   `json:"-"` // For drivers that need one, such as PJLink
+	IP        string
+	Port      int    // Leave this as 0 to use the protocol's usual port
+	Interface string // The network interface the projector was discovered on (if discovery was told which to use). We connect through it too
+	Name      string
+	UUID      string // AKA MAC Address
+	Model     string
+	Make      string
+	Revision  string
 	// Properties
-	PowerState   bool
+	PowerState   PowerState
 	VolumeMuted  bool
 	PictureMuted bool
 	Frozen       bool
@@ -43,8 +54,10 @@ type Projector struct {
 	Brightness   int
 	Location     string
 	Resolution   string
-	LampHours    string
-	Error        string
+	LampHours    int
+	LampMode     LampMode
+	Error        string // The names of any active alarms, separated by commas
+	Alarms       Alarm
 	Source       string
 }
 
@@ -146,18 +159,20 @@ var CommandList = []byte(`
 
 // PropertyList contains a list of properties present in the DCE/RPC packet that comes back.
 // This list helps us parse the packet and extract info like lamp hours and such back from it.
+// Each ID is the serial join that the property's text arrives on
 var PropertyList = []byte(`
 
 	{
-		"Power": "138a",
+		"Power": "1388",
 		"Lamp": "138b",
-		"Input": "13af",
-		"MAC": "13b4",
-		"Name": "13ba",
-		"Assigned": "13bb"
-		"Location": "13bc",
-		"Position": "13bd",
-		"Resolution": "13be",
+		"LampMode": "138a",
+		"Input": "1391",
+		"MAC": "13b3",
+		"Name": "13b9",
+		"Assigned": "13ba",
+		"Location": "13bb",
+		"Position": "13bc",
+		"Resolution": "13bd",
 		"Firmware": "13bf"
 	}
 
@@ -169,13 +184,101 @@ var Commands Command
 // Projectors is a map that contains all of the projectors we've added
 var Projectors map[string]Projector
 
-// UDP and TCP connections for reading and writing
-var udpConn *net.UDPConn
-var udpAddr *net.UDPAddr
+// projectorsLock guards Projectors, because feedback from each projector is read in its own goroutine
+var projectorsLock sync.Mutex
+
+// GetProjector returns the latest copy of a projector from Projectors
+func GetProjector(uuid string) (Projector, bool) {
+	projectorsLock.Lock()
+	defer projectorsLock.Unlock()
+	projector, ok := Projectors[uuid]
+	return projector, ok
+}
+
+// ListProjectors returns a copy of every projector in Projectors
+func ListProjectors() []Projector {
+	projectorsLock.Lock()
+	defer projectorsLock.Unlock()
+	list := make([]Projector, 0, len(Projectors))
+	for _, projector := range Projectors {
+		list = append(list, projector)
+	}
+	return list
+}
+
+// RoundTripHook, if it's set, is called with the time between sending something to a projector and the projector's
+// next reply. Replies that take longer than RoundTripTimeout aren't counted, as they probably weren't replies at all.
+// Set it before connecting to any projectors, as it's read without a lock. To add a hook later (or more than one),
+// use AddRoundTripHook
+var RoundTripHook func(projector Projector, d time.Duration)
+
+// roundTripHooks holds the hooks added with AddRoundTripHook, keyed by the order they were added in. Guarded by
+// projectorsLock
+var roundTripHooks = make(map[int]func(projector Projector, d time.Duration))
+var nextRoundTripHook int
+
+// AddRoundTripHook adds a hook that's called just like RoundTripHook, and returns a function that removes it again.
+// It's safe to call at any time, and hooks are called in the order they were added
+func AddRoundTripHook(hook func(projector Projector, d time.Duration)) (remove func()) {
+	projectorsLock.Lock()
+	defer projectorsLock.Unlock()
+	id := nextRoundTripHook
+	nextRoundTripHook++
+	roundTripHooks[id] = hook
+
+	return func() {
+		projectorsLock.Lock()
+		defer projectorsLock.Unlock()
+		delete(roundTripHooks, id)
+	}
+}
+
+// RoundTripTimeout is the longest round trip that's passed to RoundTripHook
+var RoundTripTimeout = 10 * time.Second
+
+// sentAt holds the time we first sent something to each projector since its last reply
+var sentAt = make(map[string]time.Time)
+
+// roundTrip works out how long the projector took to reply, and passes it to RoundTripHook and the added hooks
+func roundTrip(projector Projector) {
+	projectorsLock.Lock()
+	sent, waiting := sentAt[projector.UUID]
+	delete(sentAt, projector.UUID)
+	ids := make([]int, 0, len(roundTripHooks))
+	for id := range roundTripHooks {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	hooks := make([]func(Projector, time.Duration), 0, len(ids)+1)
+	if RoundTripHook != nil {
+		hooks = append(hooks, RoundTripHook)
+	}
+	for _, id := range ids {
+		hooks = append(hooks, roundTripHooks[id])
+	}
+	projectorsLock.Unlock()
+
+	if d := time.Since(sent); waiting && d <= RoundTripTimeout {
+		for _, hook := range hooks {
+			hook(projector, d)
+		}
+	}
+}
+
+// buffers holds any partial CIP packets we've read from each projector, waiting for the rest to arrive
+var buffers = make(map[string][]byte)
 
 // commandPrefix
 var commandPrefix = "05000600000300"
 
+// statusRequest asks a CIP projector for everything it knows
+var statusRequest = "050005000002031e"
+
+// connectRequest is a CIP connect packet (0x01), registering us as IP ID 0x03. CIP devices answer it with a connect
+// response (0x02). The CIP driver sends it as soon as it connects, and Scan uses it to tell projectors apart from
+// anything else that accepts connections
+var connectRequest = "01000b00000000000340fffff101"
+
 // Init gets the ball rolling by unmarshalling our command JSON and initializing our Projectors map
 func Init() (bool, error) {
 	Projectors = make(map[string]Projector)
@@ -184,6 +287,21 @@ func Init() (bool, error) {
 		return false, err
 	}
 
+	err = loadProperties()
+	if err != nil {
+		return false, err
+	}
+
+	err = loadModels()
+	if err != nil {
+		return false, err
+	}
+
+	err = loadAlarms()
+	if err != nil {
+		return false, err
+	}
+
 	// Tell our calling code that we're ready!
 	passMessage("ready", Projector{})
 
@@ -191,164 +309,262 @@ func Init() (bool, error) {
 
 }
 
-// Listen listens on port 9131 for projectors
+// Listen listens on port 9131 for projectors, on MulticastAddr. It only returns if something goes wrong
 func Listen() (bool, error) {
-	var err error
-	// Resolve our address, ready for listening. We're listening on port 9131 on the multicast address below
-	udpAddr, err = net.ResolveUDPAddr("udp4", "239.255.250.250:9131") // Get our address ready for listening
+	source, err := MulticastSource(MulticastAddr)
 	if err != nil {
 		// Errors. Errors everywhere.
 		return false, err
 	}
+	return ListenOn(source)
+}
 
-	// Now we're actually listening
-	udpConn, err = net.ListenMulticastUDP("udp", nil, udpAddr) // Now we listen on the address we just resolved
-	if err != nil {
-		return false, err
-	}
-
+// ListenOn is like Listen, but takes beacons from any BeaconSource (see beacon.go). It returns once the source is closed
+func ListenOn(source BeaconSource) (bool, error) {
 	passMessage("listening", Projector{})
-	// Because we need to be on the lookout for incoming projector discovery packets, we run
-	// this in a goroutine and loop forever
+	// Projectors that stop beaconing are noticed in the background (see discovery.go)
+	stop := startWatching()
+	defer stop()
 
+	// Because we need to be on the lookout for incoming projector discovery packets, we loop forever.
+	// Calling code usually runs this in a goroutine
 	for {
-
-		readUDP()
-		// for _, p := range Projectors {
-		// 	go readTCP(p)
-		// }
-
+		_, err := readUDP(source)
+		if err != nil {
+			return false, err
+		}
 	}
-
-	return true, nil
 }
 
+// adding holds the UUIDs of the projectors AddProjector is connecting to, so that two callers (e.g. a beacon and an
+// inventory sync) can't both connect to the same projector and leave one connection behind. Guarded by projectorsLock
+var adding = make(map[string]bool)
+
 // AddProjector adds a projector <name> to our Projectors list, and connects to the specified IP address
 func AddProjector(projector Projector) (bool, error) {
 
-	// Does this projector already exist?
+	// Does this projector already exist, or is someone else connecting to it? If not, it's ours until we're done
+	projectorsLock.Lock()
 	_, exists := Projectors[projector.UUID]
-
-	// Yes?
-	if exists == true {
+	if exists || adding[projector.UUID] {
+		projectorsLock.Unlock()
 		// Return. We're not interested
 		return false, nil
 	}
+	adding[projector.UUID] = true
+	projectorsLock.Unlock()
 
-	// Connect to the projector
-	tmp, err := net.Dial("tcp", projector.IP+":41794")
+	added, err := connectProjector(projector)
+
+	// Add the projector to our list, if we managed to connect to it
+	projectorsLock.Lock()
+	delete(adding, projector.UUID)
+	if err == nil {
+		Projectors[projector.UUID] = added
+	}
+	projectorsLock.Unlock()
 	if err != nil {
-		fmt.Println(err)
+		return false, err
 	}
 
-	// Add the projector to our list.
-	Projectors[projector.UUID] = Projector{
-		UUID:  projector.UUID,
-		Name:  projector.UUID, // Because we don't know the name yet, but we do know the UUID
-		Make:  projector.Make,
-		Model: projector.Model,
-		IP:    projector.IP,
-		Conn:  tmp,
+	passMessage("projectoradded", added)
+	go readProjector(added)
+	return true, nil
+}
+
+// connectProjector connects to a projector using the driver for its protocol, and fills in everything we know about it
+// so far. It doesn't touch Projectors
+func connectProjector(projector Projector) (Projector, error) {
+	protocol := projector.Protocol
+	if protocol == "" {
+		protocol = ProtocolCIP
+	}
+	newDriver, ok := Drivers[protocol]
+	if !ok {
+		return Projector{}, ErrUnknownProtocol
 	}
 
-	passMessage("projectoradded", Projectors[projector.UUID])
+	// Connect to the projector
+	driver := newDriver()
+	tmp, err := driver.Connect(projector)
+	if err != nil {
+		return Projector{}, err
+	}
 
-	go func() {
-		for {
-			readTCP(Projectors[projector.UUID])
-			if isDisconnected(Projectors[projector.UUID]) {
-				RemoveProjector(Projectors[projector.UUID])
+	// If we weren't told its name we don't know it yet, but we do know the UUID
+	name := projector.Name
+	if name == "" {
+		name = projector.UUID
+	}
+	return Projector{
+		UUID:       projector.UUID,
+		Name:       name,
+		Location:   projector.Location,
+		Make:       projector.Make,
+		Model:      projector.Model,
+		IP:         projector.IP,
+		Port:       projector.Port,
+		Interface:  projector.Interface,
+		Protocol:   protocol,
+		Password:   projector.Password,
+		Conn:       tmp,
+		Driver:     driver,
+		PowerState: PowerUnknown, // Until the projector tells us otherwise
+	}, nil
+}
+
+// readProjector reads from a projector's driver until the connection fails, then removes the projector
+func readProjector(added Projector) {
+	for {
+		err := added.Driver.Read(added)
+		if err != nil {
+			// Only remove the projector if it's still this connection. If we've reconnected (e.g. because the
+			// projector has moved), the old connection's error is nothing to worry about
+			if current, ok := GetProjector(added.UUID); ok && current.Driver == added.Driver {
+				RemoveProjector(current)
 			}
+			return
 		}
-	}()
-
-	return true, nil
+	}
 }
 
 // RemoveProjector does what it says on the tin: Removes a projector from our list (after first closing the connection)
 func RemoveProjector(projector Projector) (bool, error) {
-	projector.Conn.Close()
+	closeProjector(projector)
+	clearPowerState(projector.UUID)
 	passMessage("projectorremoved", projector)
+	projectorsLock.Lock()
 	delete(Projectors, projector.UUID)
+	delete(buffers, projector.UUID)
+	delete(lampWarned, projector.UUID)
+	delete(sentAt, projector.UUID)
+	projectorsLock.Unlock()
 	return true, nil
 }
 
-// SendCommand issues a command to a projector
+// closeProjector closes our connection to a projector, through its driver if it has one
+func closeProjector(projector Projector) {
+	if projector.Driver != nil {
+		projector.Driver.Close()
+	} else if projector.Conn != nil {
+		projector.Conn.Close()
+	}
+}
+
+// SendCommand issues a command to a projector. If the projector is warming up, the command is held back until it's on.
+// Turning on a projector that's cooling down returns ErrCoolingDown, unless ScheduleDuringCoolDown is set
 func SendCommand(projector Projector, command string) (bool, error) {
-	fmt.Println("Sending Message to", projector.IP, ":", commandPrefix+command)
-	SendRaw(commandPrefix+command, projector)
-	passMessage("commandsent", projector)
+	if current, ok := GetProjector(projector.UUID); ok {
+		projector = current
+	}
+	if !projector.Connected() {
+		passDetail("commandfailed", projector, ErrOffline.Error())
+		return false, ErrOffline
+	}
+
+	queued, err := queueCommand(projector, command)
+	if err != nil {
+		passDetail("commandfailed", projector, err.Error())
+		return false, err
+	}
+	if queued {
+		passDetail("commanddeferred", projector, command)
+		return true, nil
+	}
+
+	debug("Sending command to", projector.IP, ":", command)
+	err = dispatch(driverFor(projector), command)
+	if err != nil {
+		passDetail("commandfailed", projector, err.Error())
+		return false, err
+	}
+	passDetail("commandsent", projector, command)
+	commandSent(projector, command)
 	return true, nil
 }
 
-// SendRaw sends raw data
+// SendRaw sends raw data. It only works for projectors that speak CIP. Use WriteRaw to find out if it was sent
 func SendRaw(msg string, projector Projector) {
-	buf, _ := hex.DecodeString(msg)
-	_, _ = projector.Conn.Write(buf)
-
+	sendRaw(msg, projector)
 }
 
-func readUDP() (bool, error) { // Now we're checking for messages
-
-	var msg []byte // Holds the incoming message
-	buf := make([]byte, 1024)
-
-	var success bool
-	var err error
+// WriteRaw is SendRaw, but returns an error if the data couldn't be sent: ErrOffline if we aren't connected to the
+// projector, ErrUnsupported if it doesn't speak CIP, or the error from decoding msg or writing it
+func WriteRaw(msg string, projector Projector) error {
+	return sendRaw(msg, projector)
+}
 
-	n, addr, err := udpConn.ReadFromUDP(buf[:]) // Read 1024 bytes from the buffer
-	buf2 := buf[:n]
+// sendRaw is SendRaw, but tells us if the data couldn't be sent
+func sendRaw(msg string, projector Projector) error {
+	if projector.Conn == nil {
+		return ErrOffline
+	}
+	if projector.Protocol != "" && projector.Protocol != ProtocolCIP {
+		return ErrUnsupported
+	}
+	buf, err := hex.DecodeString(msg)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return err
+	}
+	_, err = projector.Conn.Write(buf)
+	if err != nil {
+		return err
 	}
-	if n > 0 { // If we've got more than 0 bytes and it's not from us
-		fmt.Println("MES!")
-		msg = buf2[0:n]
-
-		// If our message is an AMXB message (a.k.a DDDP, a.k.a Dynamic Device Discovery Protocol)
-		if strings.Contains(string(msg), "VideoProjector") {
-
-			// Regex was forged by Lucifer himself. If you can craft a better regex to search for what we need, FOR THE LOVE OF GO, CREATE A PULL REQUEST!
-			// To break this down: We start by looking for UUID=, then make a named group called UUID, which holds the contents of whatever comes after UUID=, up until the closing >
-			// We then repeat this for Make, Model and SDKClass. Finally, g makes it global so it won't stop after finding the first match
-			r, _ := regexp.Compile("<-([^=]+)=([^>]+)>")
-			result := make(map[string]string)
-
-			for _, p := range r.FindAllStringSubmatch(string(msg), -1) {
-				result[p[1]] = p[2]
-			}
-			//
-			// 	result[name] = match[i]
-			// }
-
-			// (this lets us check to see if we have this printer in our list)
-			_, ok := Projectors[result["UUID"]]
 
-			// And if this printer isn't in our list
-			if ok != true {
-				//	fmt.Println("Adding new projector. UUID is " + result["UUID"] + ", Model is " + result["Model"] + ", Make is " + result["Make"] + " and IP is " + addr.IP.String())
-				// Add it. The key will be the printer name, the value, a Printer struct
-				tmp := Projector{
-					UUID:     result["UUID"],
-					Model:    result["Model"],
-					Make:     result["Make"],
-					Revision: result["Revision"],
-					IP:       addr.IP.String(),
-				}
+	projectorsLock.Lock()
+	if _, waiting := sentAt[projector.UUID]; !waiting {
+		sentAt[projector.UUID] = time.Now()
+	}
+	projectorsLock.Unlock()
+	return nil
+}
 
-				passMessage("projectorfound", tmp)
+func readUDP(source BeaconSource) (bool, error) { // Now we're checking for messages
+	msg, ip, err := source.ReadBeacon()
+	if err != nil {
+		return false, err
+	}
+	if len(msg) == 0 {
+		return false, nil
+	}
+	debug("Received DDDP packet from", ip.String())
 
-			} else {
+	// AMXB messages (a.k.a DDDP, a.k.a Dynamic Device Discovery Protocol) are parsed by the dddp package
+	beacon, err := dddp.Parse(msg)
+	if err != nil {
+		debug("Ignoring packet from", ip.String(), ":", err)
+		return false, nil
+	}
 
-			}
-		}
-		fmt.Println("OK")
-		msg = nil // Clear out our msg property so we don't run handleMessage on old data
+	tmp := Projector{
+		UUID:     beacon.UUID,
+		Model:    beacon.Model,
+		Make:     beacon.Make,
+		Revision: beacon.Revision,
+		IP:       ip.String(),
+	}
+	tmp.Interface = interfaceFor(source, ip)
+	// Relayed beacons come from the relay, but say where the projector really is. We only take the relay's word for it
+	if origin, ok := beacon.Get(OriginTag); ok && net.ParseIP(origin) != nil && trustedRelay(ip) {
+		tmp.IP = origin
+	}
+	// Projectors don't send a port (they're always on CIPPort), but emulated ones might be somewhere else
+	if port, ok := beacon.Get("Port"); ok {
+		tmp.Port, _ = strconv.Atoi(port)
+	}
 
+	if !beacon.IsProjector() {
+		// Some other AMX device. We can't control it, but the calling code might like to know it's there
+		passDetail("devicefound", tmp, beacon.SDKClass)
+		return true, nil
 	}
+	passMessage("beaconseen", tmp)
 
-	return success, err
+	// Let the calling code know if this is a projector we haven't heard from before (so it can add it if it wants to),
+	// or if something about it has changed
+	sawBeacon(tmp)
+
+	return true, nil
 }
 
 func readTCP(projector Projector) (bool, error) { // Now we're checking for messages
@@ -360,96 +576,122 @@ func readTCP(projector Projector) (bool, error) { // Now we're checking for mess
 
 	n, err := projector.Conn.Read(buf) // Read 1024 bytes from the buffer
 
-	buf2 := buf[:n]
-	if err != nil && n > 0 {
-		fmt.Println(err)
-		os.Exit(1)
-	}
 	if n > 0 { // If we've got more than 0 bytes and it's not from us
+		roundTrip(projector)
 
-		msg := hex.EncodeToString(buf2)
+		// Tack it on to anything left over from last time, in case a packet was split across reads
+		projectorsLock.Lock()
+		msg := append(buffers[projector.UUID], buf[:n]...)
+		projectorsLock.Unlock()
 
-		handleMessage(msg, projector)
+		msg = handleMessage(msg, projector)
 
-		// fmt.Println(string("Message!: "), msg, n)
-		// 050005000002031d
-
-		// If our message is an AMXB message (a.k.a DDDP, a.k.a Dynamic Device Discovery Protocol)
-
-		msg = "" // Clear out our msg property so we don't run handleMessage on old data
+		projectorsLock.Lock()
+		buffers[projector.UUID] = msg
+		projectorsLock.Unlock()
+		success = true
 	}
 
 	return success, err
 }
 
-// GetStatus asks the projector for everything it knows. It's returned as a DCE/RPC packet
+// GetStatus asks the projector for everything it knows. The answer comes back as feedback, which updates Projectors
 func GetStatus(projector Projector) {
-	SendRaw("050005000002031e", projector)
-
+	err := driverFor(projector).Status()
+	if err != nil {
+		debug("Unable to get status from", projector.IP, ":", err)
+	}
 }
 
-// This function parses the RPC message we get back from GetStatus and updates 'projector' accordingly.
-// Each "line" from our RPC packet is separated by "03" (in hex) and the end of the readable data is separated by a "05" (again, in hex)
-// So to retrieve your data, you split the whole packet up by 03, loop through and find a line that ends with the ID you want (e.g. a line that has "13b4" at the end will contain your MAC)
-// then you split that line up by 05, and the first part is your MAC address.
-// The exception to the rule is the firmware revision, which has the ID at the START of the line. I'd check for this, but different projectors might put different properties last
-func handleMessage(msg string, projector Projector) {
-	// Holds the IDs we're looking for
-	var ids map[string]string
-
-	json.Unmarshal(PropertyList, &ids)
-
-	parts := strings.Split(msg, "03")
-	for _, p := range parts {
-		for c := range ids {
-			if strings.HasSuffix(p, ids[c]) {
-				// Now, we could use the reflect library to dynamically set these properties, but I'll try that later.
-				data := strings.Split(p, "05")[0]
-				dec, _ := hex.DecodeString(data)
-				switch ids[c] {
-				case "Input":
-					projector.Source = string(dec)
-				case "Power":
-					if string(dec) == "On" {
-						projector.PowerState = true
-					} else {
-						projector.PowerState = false
-					}
-				case "Name":
-					projector.Name = string(dec)
-					passMessage("namechanged", projector)
-				case "Lamp":
-					projector.LampHours = string(dec)
-				}
-			}
+// This function parses the CIP packets we get back from the projector (either on their own, or the big dump
+// that GetStatus asks for) and updates 'projector' in Projectors accordingly.
+// The packet format is described in feedback.go. Any bytes belonging to a packet that hasn't fully arrived yet are returned
+func handleMessage(msg []byte, projector Projector) []byte {
+	joins, rest := parseJoins(msg)
+	if len(joins) == 0 {
+		return rest
+	}
+
+	for _, j := range joins {
+		if j.Type == joinDigital {
+			// Handy for finding out which join an alarm (or anything else) is on. See AlarmList
+			debug("Digital join from", projector.IP, ":", j.ID, j.Digital)
 		}
 	}
 
-}
+	var power []PowerState
 
-func isDisconnected(projector Projector) bool {
-	fmt.Println("Checking for disconnection")
-	one := make([]byte, 1)
-	projector.Conn.SetReadDeadline(time.Now())
-	if _, err := projector.Conn.Read(one); err == io.EOF {
-		projector.Conn.SetReadDeadline(time.Time{})
-		return true
+	UpdateProjector(projector.UUID, func(current *Projector) {
+		for _, j := range joins {
+			if state, ok := powerFeedback(j); ok {
+				power = append(power, state)
+				continue
+			}
+			applyJoin(current, j)
+		}
+	})
+
+	for _, state := range power {
+		setPowerState(projector.UUID, state)
 	}
 
-	projector.Conn.SetReadDeadline(time.Time{})
-	return false
+	return rest
+}
 
+// debug prints a message if Debug is turned on
+func debug(a ...interface{}) {
+	if Debug {
+		fmt.Println(a...)
+	}
 }
 
 // passMessage adds items to our Events channel so the calling code can be informed
 // It's non-blocking or whatever.
 func passMessage(message string, projector Projector) bool {
+	return passDetail(message, projector, "")
+}
+
+// passDetail is passMessage, but with some extra detail about the event (e.g. the name of an alarm)
+func passDetail(message string, projector Projector, detail string) bool {
+	event := EventStruct{message, projector, detail}
 
 	select {
-	case Events <- EventStruct{message, projector}:
+	case Events <- event:
 
 	default:
 	}
 
+	subscribersLock.Lock()
+	for subscriber := range subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+	subscribersLock.Unlock()
+
 	return true
 }
+
+// subscribers holds the channels handed out by Subscribe
+var subscribers = make(map[chan EventStruct]bool)
+var subscribersLock sync.Mutex
+
+// Subscribe returns a channel that gets a copy of every event, for code that wants to watch events without taking
+// them away from whoever is reading Events. Call the returned function to unsubscribe when you're done
+func Subscribe() (chan EventStruct, func()) {
+	subscriber := make(chan EventStruct, 100)
+
+	subscribersLock.Lock()
+	subscribers[subscriber] = true
+	subscribersLock.Unlock()
+
+	return subscriber, func() {
+		subscribersLock.Lock()
+		if subscribers[subscriber] {
+			delete(subscribers, subscriber)
+			close(subscriber)
+		}
+		subscribersLock.Unlock()
+	}
+}
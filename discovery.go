@@ -0,0 +1,200 @@
+package dell
+
+import (
+	"sync"
+	"time"
+)
+
+// AnnounceInterval is how often projectors send a DDDP beacon. Real projectors announce themselves every 30 seconds or so
+var AnnounceInterval = 30 * time.Second
+
+// BeaconExpiry is how many announce intervals can go by without a beacon before a projector is considered lost
+var BeaconExpiry = 3
+
+// sighting is the last beacon we saw from a projector, and when we saw it
+type sighting struct {
+	projector Projector
+	seen      time.Time
+}
+
+// sightings holds the last beacon from every projector we've heard from (and haven't lost since), keyed by UUID
+var sightings = make(map[string]sighting)
+var sightingsLock sync.Mutex
+
+// LastSeen tells us when we last heard a beacon from a projector. ok is false if we've never heard from it, or it's
+// been lost since
+func LastSeen(uuid string) (seen time.Time, ok bool) {
+	sightingsLock.Lock()
+	defer sightingsLock.Unlock()
+	s, ok := sightings[uuid]
+	return s.seen, ok
+}
+
+// sawBeacon keeps track of the projectors we've heard from. It raises "projectorfound" the first time we hear from a
+// projector (or the first time since it was lost), and "projectorupdated" when its beacon changes (e.g. it's been given
+// a new IP address by DHCP). If a projector in Projectors turns up somewhere new, we reconnect to it there
+func sawBeacon(projector Projector) {
+	sightingsLock.Lock()
+	previous, known := sightings[projector.UUID]
+	sightings[projector.UUID] = sighting{projector, time.Now()}
+	sightingsLock.Unlock()
+
+	switch {
+	case !known:
+		passMessage("projectorfound", projector)
+	case previous.projector.IP != projector.IP || previous.projector.Port != projector.Port ||
+		previous.projector.Make != projector.Make || previous.projector.Model != projector.Model ||
+		previous.projector.Revision != projector.Revision || previous.projector.Interface != projector.Interface:
+		passMessage("projectorupdated", projector)
+	}
+
+	current, ok := GetProjector(projector.UUID)
+	if ok && moved(current, projector) && startReconnect(projector.UUID) {
+		// Connecting can take a while if the new address doesn't answer, and beacons from everything else can't wait
+		go func() {
+			defer finishReconnect(projector.UUID)
+			reconnect(current, projector)
+		}()
+	}
+}
+
+// reconnecting holds the UUIDs of the projectors we're reconnecting to, so there's only ever one attempt at a time
+var reconnecting = make(map[string]bool)
+
+// startReconnect claims the right to reconnect to a projector. It's false if someone else is already doing it
+func startReconnect(uuid string) bool {
+	sightingsLock.Lock()
+	defer sightingsLock.Unlock()
+	if reconnecting[uuid] {
+		return false
+	}
+	reconnecting[uuid] = true
+	return true
+}
+
+// finishReconnect lets the next beacon start another reconnect if it needs to
+func finishReconnect(uuid string) {
+	sightingsLock.Lock()
+	defer sightingsLock.Unlock()
+	delete(reconnecting, uuid)
+}
+
+// moved tells us whether a beacon puts a projector somewhere other than where we're connected to it.
+// Only CIP projectors are checked, as they're the only ones that send beacons
+func moved(current Projector, beacon Projector) bool {
+	if current.Protocol != "" && current.Protocol != ProtocolCIP {
+		return false
+	}
+	port, beaconPort := current.Port, beacon.Port
+	if port == 0 {
+		port = CIPPort
+	}
+	if beaconPort == 0 {
+		beaconPort = CIPPort
+	}
+	return current.IP != beacon.IP || port != beaconPort
+}
+
+// reconnect connects to a projector at the address in its latest beacon, and once that's worked, swaps the new
+// connection in for the old one. If the new address doesn't answer we keep the old entry (the connection may yet
+// recover), and the next beacon tries again
+func reconnect(current Projector, beacon Projector) {
+	debug("Projector", current.UUID, "has moved from", current.IP, "to", beacon.IP, ", reconnecting")
+	moving := current
+	moving.IP = beacon.IP
+	moving.Port = beacon.Port
+	moving.Interface = beacon.Interface
+	added, err := connectProjector(moving)
+	if err != nil {
+		debug("Unable to reconnect to", current.UUID, ":", err)
+		return
+	}
+	// The name and location we'd learned carry over
+	added.Name = current.Name
+	added.Location = current.Location
+
+	// Only swap if nobody has removed or replaced the projector while we were connecting
+	projectorsLock.Lock()
+	existing, ok := Projectors[current.UUID]
+	if !ok || existing.Driver != current.Driver {
+		projectorsLock.Unlock()
+		added.Driver.Close()
+		return
+	}
+	Projectors[current.UUID] = added
+	delete(buffers, current.UUID)
+	delete(sentAt, current.UUID)
+	projectorsLock.Unlock()
+
+	// Closing the old connection ends its read loop, which leaves the new entry alone as it's not its driver
+	closeProjector(current)
+	clearPowerState(current.UUID)
+	passMessage("projectorremoved", current)
+	passMessage("projectoradded", added)
+	go readProjector(added)
+	GetStatus(added)
+}
+
+// expireSightings raises "projectorlost" for every projector we haven't heard from in BeaconExpiry announce intervals,
+// and forgets about it (so the next beacon raises "projectorfound" again). Lost projectors aren't removed from
+// Projectors, as the connection may well still be working
+func expireSightings() {
+	expiry := time.Duration(BeaconExpiry) * AnnounceInterval
+
+	var lost []Projector
+	sightingsLock.Lock()
+	for uuid, s := range sightings {
+		if time.Since(s.seen) > expiry {
+			lost = append(lost, s.projector)
+			delete(sightings, uuid)
+		}
+	}
+	sightingsLock.Unlock()
+
+	for _, projector := range lost {
+		passMessage("projectorlost", projector)
+	}
+}
+
+// watchers is how many ListenOns are running. The first starts watchSightings and the last stops it, so there's only
+// ever one no matter how many sources we're listening on
+var watchers int
+var stopWatching chan struct{}
+var watchersLock sync.Mutex
+
+// startWatching makes sure watchSightings is running, until the returned function is called
+func startWatching() func() {
+	watchersLock.Lock()
+	defer watchersLock.Unlock()
+	if watchers == 0 {
+		stopWatching = make(chan struct{})
+		go watchSightings(stopWatching)
+	}
+	watchers++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			watchersLock.Lock()
+			defer watchersLock.Unlock()
+			watchers--
+			if watchers == 0 {
+				close(stopWatching)
+			}
+		})
+	}
+}
+
+// watchSightings calls expireSightings every half an announce interval, until done is closed
+func watchSightings(done chan struct{}) {
+	ticker := time.NewTicker(AnnounceInterval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			expireSightings()
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package dell
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// Driver is how we talk to a projector. Each projector in Projectors has its own Driver, picked by its Protocol, so
+// projectors that speak different protocols can all live in Projectors and share the same events.
+//
+// Drivers pass what they learn about a projector back with UpdateProjector and ReportPowerState
+type Driver interface {
+	// Connect connects to the projector. The connection is stored in Projector.Conn. Drivers that don't keep a
+	// connection open return nil, and implement Connectionless
+	Connect(projector Projector) (net.Conn, error)
+	// Read is called over and over (once the projector has been added to Projectors) to read feedback from the
+	// projector. If it returns an error, the projector is removed
+	Read(projector Projector) error
+	Close() error
+
+	PowerOn() error
+	PowerOff() error
+	SetInput(input string) error // input is one of the names from Commands.Input, e.g. "HDMI"
+	Mute(muted bool) error
+	Status() error // Asks the projector for its status. The answer arrives through Read
+	// Send sends any other command from Commands. Drivers that can't do it return ErrUnsupported
+	Send(command string) error
+}
+
+// Connectionless is implemented by Drivers that don't keep a connection open (such as the web UI driver, which makes a
+// new HTTP request each time), so Connect hands back a nil net.Conn. Connected says whether the projector can still be
+// reached. See Projector.Connected
+type Connectionless interface {
+	Connected() bool
+}
+
+// Connected tells us whether we can talk to a projector. For most drivers that's whether Conn is set, but drivers
+// that don't keep a connection open implement Connectionless and say for themselves. Use this rather than checking Conn
+func (p Projector) Connected() bool {
+	if p.Conn != nil {
+		return true
+	}
+	if c, ok := p.Driver.(Connectionless); ok {
+		return c.Connected()
+	}
+	return false
+}
+
+// ProtocolCIP is the Crestron CIP protocol the Dell projectors speak, and the protocol used if Projector.Protocol is empty
+const ProtocolCIP = "cip"
+
+// ErrUnknownProtocol is returned by AddProjector when there's no Driver for the projector's Protocol
+var ErrUnknownProtocol = errors.New("no driver for this protocol")
+
+// Drivers holds a function that makes a new Driver for each protocol. Other packages add to it with RegisterDriver
+var Drivers = map[string]func() Driver{
+	ProtocolCIP: func() Driver { return &cipDriver{} },
+}
+
+// RegisterDriver makes a protocol available to AddProjector. It's usually called from a package's init function
+func RegisterDriver(protocol string, driver func() Driver) {
+	Drivers[protocol] = driver
+}
+
+// driverFor returns a projector's Driver. Projectors that were put together by hand (without AddProjector) don't have
+// one, so they get a CIP driver that uses their connection
+func driverFor(projector Projector) Driver {
+	if projector.Driver != nil {
+		return projector.Driver
+	}
+	return &cipDriver{projector: projector}
+}
+
+// dispatch sends a command from Commands through a Driver, using the Driver's own method for it if there is one
+func dispatch(driver Driver, command string) error {
+	switch command {
+	case Commands.Power.On:
+		return driver.PowerOn()
+	case Commands.Power.Off:
+		return driver.PowerOff()
+	case Commands.Volume.Mute:
+		return driver.Mute(true)
+	case Commands.Volume.Unmute:
+		return driver.Mute(false)
+	}
+	if name := CommandName(command); strings.HasPrefix(name, "Input.") {
+		return driver.SetInput(strings.TrimPrefix(name, "Input."))
+	}
+	return driver.Send(command)
+}
+
+// UpdateProjector lets a Driver change a projector in Projectors. update is called with Projectors locked, so it
+// mustn't call anything else in this package. Afterwards, the usual events (propertychanged, namechanged, alarmraised
+// and so on) are raised for whatever changed. It returns false if the projector isn't in Projectors
+func UpdateProjector(uuid string, update func(projector *Projector)) bool {
+	projectorsLock.Lock()
+	current, exists := Projectors[uuid]
+	if !exists {
+		projectorsLock.Unlock()
+		return false
+	}
+
+	before := current
+	update(&current)
+	current.Error = current.Alarms.String()
+	Projectors[uuid] = current
+	projectorsLock.Unlock()
+
+	for _, property := range changedProperties(before, current) {
+		passDetail("propertychanged", current, property)
+	}
+
+	if current.Name != before.Name {
+		passMessage("namechanged", current)
+	}
+
+	alarmEvents(before.Alarms, current)
+	checkLamp(current)
+	return true
+}
+
+// ReportPowerState lets a Driver tell us what power state the projector says it's in. It goes through the same
+// warm up and cool down handling as CIP feedback
+func ReportPowerState(uuid string, state PowerState) {
+	setPowerState(uuid, state)
+}
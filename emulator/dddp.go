@@ -0,0 +1,83 @@
+package emulator
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/dddp"
+)
+
+// MulticastAddr is where real projectors send their DDDP beacons, and where dell.Listen listens for them.
+// To beacon somewhere multicast doesn't work (like a CI sandbox), Announce to "127.0.0.1:9131" instead and use
+// dell.UnicastSource
+var MulticastAddr = dell.MulticastAddr
+
+// Beacon is the DDDP packet the projector announces itself with (see the dddp package).
+// Real projectors are always on dell.CIPPort, so they don't say which port they're on. If we're listening somewhere
+// else, we add a Port tag so that dell can still find us
+func (p *FakeProjector) Beacon() []byte {
+	beacon := dddp.Beacon{
+		SDKClass: dddp.ClassVideoProjector,
+		UUID:     p.UUID,
+		Make:     p.Make,
+		Model:    p.Model,
+		Revision: p.Revision,
+	}
+	if port := p.Port(); port != 0 && port != dell.CIPPort {
+		beacon.Tags = append(beacon.Tags, dddp.Tag{Name: "Port", Value: strconv.Itoa(port)})
+	}
+	// This only fails if one of the properties has "><-" in it, in which case there's nothing sensible to send
+	data, _ := dddp.Marshal(beacon)
+	return data
+}
+
+// Announce sends a beacon to addr (usually MulticastAddr) straight away, then every interval until the projector
+// is closed. If the projector is listening on a particular IP address, beacons are sent from it, because dell
+// connects to whichever address a beacon came from
+func (p *FakeProjector) Announce(addr string, interval time.Duration) error {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	var laddr *net.UDPAddr
+	p.lock.Lock()
+	if p.listener != nil {
+		if ip := p.listener.Addr().(*net.TCPAddr).IP; !ip.IsUnspecified() {
+			laddr = &net.UDPAddr{IP: ip}
+		}
+	}
+	p.lock.Unlock()
+
+	conn, err := net.DialUDP("udp", laddr, raddr)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(p.Beacon())
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	p.lock.Lock()
+	p.announcers = append(p.announcers, conn)
+	p.lock.Unlock()
+
+	go func() {
+		for {
+			time.Sleep(interval)
+			_, err := conn.Write(p.Beacon())
+			if err != nil && isClosed(err) {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// isClosed tells us whether err came from using a connection after Close
+func isClosed(err error) bool {
+	op, ok := err.(*net.OpError)
+	return ok && op.Err.Error() == "use of closed network connection"
+}
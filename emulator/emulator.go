@@ -0,0 +1,487 @@
+// Package emulator pretends to be a Dell projector, so that code using dell can be tested without any hardware.
+//
+//	projector := emulator.New("DEADBEEF")
+//	err := projector.Listen("127.0.0.1:0")
+//	dell.AddProjector(dell.Projector{UUID: "DEADBEEF", IP: "127.0.0.1", Port: projector.Port()})
+//
+// A FakeProjector speaks Crestron CIP like the real thing: it asks each client to register (0x0f) as it connects and
+// ignores it until it sends a connect request (0x01), acts on the commands in dell.Commands, answers status requests
+// with a full feedback dump (in the same format as a real projector's) and sends feedback to every connected client
+// whenever its state changes, whether that's because of a command or because a test called Update
+package emulator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// State is everything a FakeProjector knows about itself
+type State struct {
+	Power        dell.PowerState
+	Input        string // One of the names in dell.Commands.Input, e.g. "HDMI"
+	Volume       int
+	VolumeMuted  bool
+	PictureMuted bool
+	Frozen       bool
+	Contrast     int
+	Brightness   int
+	LampHours    int
+	LampMode     dell.LampMode
+	Alarms       dell.Alarm
+	Name         string
+	Location     string
+	Resolution   string // The resolution of the input signal. Set it to "" for no signal
+	Firmware     string
+	Network      Network
+	Message      string // The last text sent to dell.MessageJoin, if it's been set
+}
+
+// Network is the projector's network configuration, as shown in its feedback
+type Network struct {
+	IP      string
+	Subnet  string
+	Gateway string
+	DNS     string
+	MAC     string
+}
+
+// FakeProjector is an emulated projector
+type FakeProjector struct {
+	// These are announced in DDDP beacons
+	UUID     string
+	Make     string
+	Model    string
+	Revision string
+
+	// How long the projector takes to warm up and cool down. Commands other than power are ignored until it's on.
+	// Both default to 0, so power changes straight away
+	WarmUpTime   time.Duration
+	CoolDownTime time.Duration
+
+	lock       sync.Mutex
+	state      State
+	listener   net.Listener
+	port       int
+	announcers []net.Conn
+	clients    map[*client]bool
+	received   []string
+	timer      *time.Timer
+	tables     tables
+	faults     Faults
+	steps      []Step
+}
+
+// client is a single connection to a FakeProjector
+type client struct {
+	conn      net.Conn
+	lock      sync.Mutex // Guards writes to conn
+	sent      int        // Packets sent, for Faults.DropEvery
+	connected bool       // Whether the client has sent a connect request. Guarded by FakeProjector.lock
+}
+
+// New creates a FakeProjector that's switched off, with the same settings as the projector our sample feedback
+// dump came from
+func New(uuid string) *FakeProjector {
+	p := &FakeProjector{
+		UUID:     uuid,
+		Make:     "Dell",
+		Model:    "S300wi",
+		Revision: "0.0.2.0",
+		state: State{
+			Power:      dell.PowerOff,
+			Input:      "HDMI",
+			Volume:     38,
+			LampHours:  275,
+			LampMode:   dell.LampNormal,
+			Name:       "D33128",
+			Resolution: "1280 x 800",
+			Firmware:   "0.0.2.0",
+			Network: Network{
+				IP:      "192.168.1.11",
+				Subnet:  "255.255.255.0",
+				Gateway: "192.168.1.1",
+				DNS:     "192.168.1.1",
+				MAC:     "B8:AC:6F:DF:E1:E2",
+			},
+		},
+		clients: make(map[*client]bool),
+	}
+	return p
+}
+
+// Listen starts accepting CIP connections on addr. Use "127.0.0.1:0" to pick any free port, then Port or Addr to
+// find out which
+func (p *FakeProjector) Listen(addr string) error {
+	err := p.load()
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	p.lock.Lock()
+	p.listener = l
+	p.port = l.Addr().(*net.TCPAddr).Port
+	p.lock.Unlock()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if p.Faults().Refuse {
+				reset(conn)
+				continue
+			}
+			go p.serve(conn)
+		}
+	}()
+	return nil
+}
+
+// load works out the hex code of every command and the joins we send feedback on, so we know what we're being asked
+// to do and how to answer. It uses dell's JSON lists directly, so it doesn't rely on dell.Init having been called
+func (p *FakeProjector) load() error {
+	var commands dell.Command
+	err := json.Unmarshal(dell.CommandList, &commands)
+	if err != nil {
+		return err
+	}
+	var properties map[string]string
+	err = json.Unmarshal(dell.PropertyList, &properties)
+	if err != nil {
+		return err
+	}
+	var alarms map[string]string
+	err = json.Unmarshal(dell.AlarmList, &alarms)
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.tables = tables{
+		names:      make(map[string]string),
+		codes:      make(map[string]string),
+		properties: make(map[string]string),
+		alarms:     make(map[string]string),
+	}
+	walk(reflect.ValueOf(commands), "", func(name string, command string) {
+		command = strings.ToLower(command)
+		p.tables.names[command] = name
+		p.tables.codes[name] = command
+	})
+	for name, id := range properties {
+		p.tables.properties[name] = strings.ToLower(id)
+	}
+	for name, id := range alarms {
+		p.tables.alarms[name] = strings.ToLower(id)
+	}
+	return nil
+}
+
+// walk goes through a dell.Command and calls fn with the dotted name and hex code of each command it finds
+func walk(v reflect.Value, prefix string, fn func(name string, command string)) {
+	for i := 0; i < v.NumField(); i++ {
+		name := prefix + v.Type().Field(i).Name
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Struct:
+			walk(field, name+".", fn)
+		case reflect.String:
+			if field.String() != "" {
+				fn(name, field.String())
+			}
+		}
+	}
+}
+
+// Addr is the address the projector is listening on
+func (p *FakeProjector) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Port is the port the projector is listening on, ready to go in dell.Projector.Port
+func (p *FakeProjector) Port() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.port
+}
+
+// Close stops the projector and disconnects every client
+func (p *FakeProjector) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for c := range p.clients {
+		c.conn.Close()
+	}
+	for _, conn := range p.announcers {
+		conn.Close()
+	}
+	p.announcers = nil
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	if p.listener == nil {
+		return nil
+	}
+	return p.listener.Close()
+}
+
+// State returns a copy of the projector's state
+func (p *FakeProjector) State() State {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.state
+}
+
+// Update changes the projector's state (e.g. to add some lamp hours or raise an alarm) and sends feedback for
+// whatever changed to every client
+func (p *FakeProjector) Update(update func(state *State)) {
+	p.lock.Lock()
+	before := p.state
+	update(&p.state)
+	after := p.state
+	p.lock.Unlock()
+
+	p.broadcast(p.changes(before, after))
+}
+
+// Received returns the dotted names (e.g. "Power.On") of the commands the projector has been sent, in order.
+// Commands that aren't in dell.CommandList show up as their hex code
+func (p *FakeProjector) Received() []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]string(nil), p.received...)
+}
+
+// Clients is the number of clients connected to the projector
+func (p *FakeProjector) Clients() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.clients)
+}
+
+// serve talks to a single client until it goes away
+func (p *FakeProjector) serve(conn net.Conn) {
+	c := &client{conn: conn}
+	p.lock.Lock()
+	p.clients[c] = true
+	p.lock.Unlock()
+
+	defer func() {
+		p.lock.Lock()
+		delete(p.clients, c)
+		p.lock.Unlock()
+		conn.Close()
+	}()
+
+	// Like a real projector, ask the client to register before it does anything else
+	p.send(c, packet(cipRegister, []byte{0x02}))
+
+	var buf []byte
+	chunk := make([]byte, 1024)
+	for {
+		n, err := conn.Read(chunk)
+		if err != nil {
+			return
+		}
+		buf = append(buf, chunk[:n]...)
+
+		// Handle every complete packet we've got, and keep whatever's left for next time
+		for len(buf) >= 3 {
+			length := int(buf[1])<<8 | int(buf[2])
+			if len(buf) < 3+length {
+				break
+			}
+			packetType, payload := buf[0], buf[3:3+length]
+			buf = buf[3+length:]
+			if !p.handle(c, packetType, payload) {
+				return
+			}
+		}
+	}
+}
+
+// The CIP packet types we understand, other than cipData
+const (
+	cipConnect         = 0x01
+	cipConnectResponse = 0x02
+	cipDisconnect      = 0x03
+	cipData            = 0x05
+	cipHeartbeat       = 0x0d
+	cipHeartbeatReply  = 0x0e
+	cipRegister        = 0x0f // Sent to every client as it connects, asking for a connect request
+)
+
+// handle deals with a single packet from a client. It returns false if the client should be disconnected
+func (p *FakeProjector) handle(c *client, packetType byte, payload []byte) bool {
+	if p.Faults().Hang {
+		return true
+	}
+
+	switch packetType {
+	case cipConnect:
+		p.lock.Lock()
+		c.connected = true
+		p.lock.Unlock()
+		p.send(c, packet(cipConnectResponse, []byte{0x00, 0x00, 0x00, 0x03}))
+	case cipHeartbeat:
+		p.send(c, packet(cipHeartbeatReply, []byte{0x00, 0x00}))
+	case cipDisconnect:
+		return false
+	case cipData:
+		// Data from a client that hasn't connected yet is ignored, as it is by real projectors
+		p.lock.Lock()
+		connected := c.connected
+		p.lock.Unlock()
+		if !connected || len(payload) < 4 {
+			return true
+		}
+		data := payload[4:]
+		switch payload[3] {
+		case joinDigital:
+			// Only presses count. Releases (with the high bit set) are ignored, like a real button
+			if len(data) >= 2 && data[1]&0x80 == 0 {
+				p.command(hex.EncodeToString(data[:2]))
+			}
+		case joinUpdate:
+			p.send(c, p.dump())
+		case joinSerial:
+			if len(data) >= 3 {
+				p.serial(hex.EncodeToString(data[:2]), string(data[3:]))
+			}
+		}
+	}
+	return true
+}
+
+// command acts on a command from dell.Commands
+func (p *FakeProjector) command(command string) {
+	p.lock.Lock()
+	name, ok := p.tables.names[command]
+	if !ok {
+		name = command
+	}
+	p.received = append(p.received, name)
+	p.runScript()
+	before := p.state
+	s := &p.state
+
+	switch {
+	case p.rejected(name):
+		// Received, but ignored
+	case name == "Power.On":
+		if s.Power == dell.PowerOff {
+			p.transition(dell.PowerWarmingUp, dell.PowerOn, p.WarmUpTime)
+		}
+	case name == "Power.Off":
+		if s.Power == dell.PowerOn {
+			p.transition(dell.PowerCoolingDown, dell.PowerOff, p.CoolDownTime)
+		}
+	case s.Power != dell.PowerOn:
+		// Projectors ignore just about everything unless they're on
+	case len(name) > 6 && name[:6] == "Input.":
+		s.Input = name[6:]
+	case name == "Volume.Up":
+		s.Volume = clamp(s.Volume + 1)
+	case name == "Volume.Down":
+		s.Volume = clamp(s.Volume - 1)
+	case name == "Volume.Mute":
+		s.VolumeMuted = true
+	case name == "Volume.Unmute":
+		s.VolumeMuted = false
+	case name == "Picture.Mute":
+		s.PictureMuted = true
+	case name == "Picture.Unmute":
+		s.PictureMuted = false
+	case name == "Picture.Freeze":
+		s.Frozen = true
+	case name == "Picture.Unfreeze":
+		s.Frozen = false
+	case name == "Picture.Contrast.Up":
+		s.Contrast = clamp(s.Contrast + 1)
+	case name == "Picture.Contrast.Down":
+		s.Contrast = clamp(s.Contrast - 1)
+	case name == "Picture.Brightness.Up":
+		s.Brightness = clamp(s.Brightness + 1)
+	case name == "Picture.Brightness.Down":
+		s.Brightness = clamp(s.Brightness - 1)
+	}
+	after := p.state
+	p.lock.Unlock()
+
+	p.broadcast(p.changes(before, after))
+}
+
+// transition starts warming up or cooling down (p.lock must be held). If it takes no time at all, we go straight to the end
+func (p *FakeProjector) transition(during dell.PowerState, end dell.PowerState, d time.Duration) {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	if d <= 0 {
+		p.state.Power = end
+		return
+	}
+	p.state.Power = during
+	p.timer = time.AfterFunc(d, func() {
+		p.Update(func(s *State) {
+			if s.Power == during {
+				s.Power = end
+			}
+		})
+	})
+}
+
+// serial handles text sent to a serial join. The projector's name and location can be changed this way, and
+// messages sent to dell.MessageJoin are kept in State.Message
+func (p *FakeProjector) serial(id string, text string) {
+	switch id {
+	case strings.ToLower(dell.MessageJoin):
+		p.Update(func(s *State) { s.Message = text })
+	case p.tables.properties["Name"]:
+		p.Update(func(s *State) { s.Name = text })
+	case p.tables.properties["Location"]:
+		p.Update(func(s *State) { s.Location = text })
+	}
+}
+
+// broadcast sends feedback to every client that has connected
+func (p *FakeProjector) broadcast(feedback []byte) {
+	if len(feedback) == 0 {
+		return
+	}
+	p.lock.Lock()
+	clients := make([]*client, 0, len(p.clients))
+	for c := range p.clients {
+		if c.connected {
+			clients = append(clients, c)
+		}
+	}
+	p.lock.Unlock()
+
+	for _, c := range clients {
+		p.send(c, feedback)
+	}
+}
+
+// clamp keeps a level (like the volume) between 0 and 100
+func clamp(n int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > 100 {
+		return 100
+	}
+	return n
+}
@@ -0,0 +1,167 @@
+package emulator
+
+import (
+	"net"
+	"time"
+)
+
+// Faults makes a FakeProjector misbehave, so that timeouts and reconnection can be tested. The zero value is a
+// perfectly behaved projector. Everything here is deterministic (nothing is left to chance), so tests get the same
+// result every time
+type Faults struct {
+	Latency       time.Duration // Added before every reply
+	DropEvery     int           // Drop every nth packet we send to each client (1 drops them all)
+	FragmentSize  int           // Send replies in pieces of at most this many bytes..
+	FragmentDelay time.Duration // ..with this long between each piece
+	Garbage       []byte        // Sent before every reply. Bytes 1 and 2 will be read as a CIP length, so choose them carefully
+	ResetAfter    int           // Reset (RST) the connection after sending this many bytes of a reply
+	Hang          bool          // Keep the connection open, but never answer or act on anything
+	Refuse        bool          // Reset new connections as soon as they're accepted
+	Reject        []string      // Commands (dotted names, e.g. "Input.HDMI") that are received but ignored
+}
+
+// Step is a change to the faults, made once the projector has received a number of commands
+type Step struct {
+	After  int // The number of commands. The step applies to the reply to the command that triggers it
+	Faults Faults
+}
+
+// SetFaults changes how the projector misbehaves, from now on
+func (p *FakeProjector) SetFaults(faults Faults) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.faults = faults
+}
+
+// Faults returns how the projector is currently misbehaving
+func (p *FakeProjector) Faults() Faults {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.faults
+}
+
+// Script lines up changes to the faults, e.g. to start hanging after the third command. Steps replace any that were
+// already lined up, and are counted from the number of commands already received
+func (p *FakeProjector) Script(steps ...Step) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.steps = nil
+	for _, step := range steps {
+		step.After += len(p.received)
+		p.steps = append(p.steps, step)
+	}
+}
+
+// Reset drops every client with a TCP reset, as if the projector had crashed
+func (p *FakeProjector) Reset() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for c := range p.clients {
+		reset(c.conn)
+	}
+}
+
+// runScript applies any steps that are due (p.lock must be held)
+func (p *FakeProjector) runScript() {
+	var remaining []Step
+	for _, step := range p.steps {
+		if len(p.received) >= step.After {
+			p.faults = step.Faults
+		} else {
+			remaining = append(remaining, step)
+		}
+	}
+	p.steps = remaining
+}
+
+// rejected tells us whether a command should be ignored (p.lock must be held)
+func (p *FakeProjector) rejected(name string) bool {
+	for _, r := range p.faults.Reject {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// send writes to a client, misbehaving as much as the faults say we should
+func (p *FakeProjector) send(c *client, b []byte) error {
+	faults := p.Faults()
+	if faults.Hang {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	time.Sleep(faults.Latency)
+
+	if faults.DropEvery > 0 {
+		var kept []byte
+		for _, pkt := range split(b) {
+			c.sent++
+			if c.sent%faults.DropEvery != 0 {
+				kept = append(kept, pkt...)
+			}
+		}
+		b = kept
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	b = append(append([]byte(nil), faults.Garbage...), b...)
+
+	resetting := faults.ResetAfter > 0 && faults.ResetAfter < len(b)
+	if resetting {
+		b = b[:faults.ResetAfter]
+	}
+
+	size := faults.FragmentSize
+	if size <= 0 {
+		size = len(b)
+	}
+	for len(b) > 0 {
+		n := size
+		if n > len(b) {
+			n = len(b)
+		}
+		_, err := c.conn.Write(b[:n])
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+		if len(b) > 0 {
+			time.Sleep(faults.FragmentDelay)
+		}
+	}
+
+	if resetting {
+		reset(c.conn)
+	}
+	return nil
+}
+
+// split breaks a run of CIP packets up into single packets
+func split(b []byte) [][]byte {
+	var packets [][]byte
+	for len(b) >= 3 {
+		length := 3 + (int(b[1])<<8 | int(b[2]))
+		if length > len(b) {
+			length = len(b)
+		}
+		packets = append(packets, b[:length])
+		b = b[length:]
+	}
+	if len(b) > 0 {
+		packets = append(packets, b)
+	}
+	return packets
+}
+
+// reset closes a connection with a TCP reset rather than the usual polite FIN
+func reset(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
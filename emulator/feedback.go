@@ -0,0 +1,208 @@
+package emulator
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+
+	"github.com/Grayda/go-dell"
+)
+
+// The CIP data packet join types (see dell's feedback.go). joinUpdate is what dell's status request asks for
+const (
+	joinDigital = 0x00
+	joinUpdate  = 0x03
+	joinAnalog  = 0x14
+	joinSerial  = 0x15
+)
+
+// Joins that a real projector sends but that aren't in dell.PropertyList (because dell doesn't read them).
+// Analog and serial joins are big endian, like they're sent
+var (
+	LampJoin    = "0001" // Analog: lamp hours
+	VolumeJoin  = "139c" // Analog: volume
+	IPJoin      = "13af"
+	SubnetJoin  = "13b0"
+	GatewayJoin = "13b1"
+	DNSJoin     = "13b2"
+	PortJoin    = "13b6"
+)
+
+// InputNames are the names the projector shows for each input (keyed by the names in dell.Commands.Input).
+// They're sent as serial joins, and the current one is sent as the "Input" property
+var InputNames = map[string]string{
+	"VGAA":       "VGA-A",
+	"VGAB":       "VGA-B",
+	"Composite":  "Composite Video",
+	"SVideo":     "S-Video",
+	"HDMI":       "HDMI",
+	"Wireless":   "Wireless Display",
+	"USBDisplay": "USB Display",
+	"USBViewer":  "USB Viewer",
+}
+
+// tables holds CommandList, PropertyList and AlarmList, unmarshalled
+type tables struct {
+	names      map[string]string // Command hex codes to dotted names
+	codes      map[string]string // Dotted names to command hex codes
+	properties map[string]string // Property names to serial joins
+	alarms     map[string]string // Alarm names to digital joins
+}
+
+// item is a single piece of feedback. key identifies the join, so that two versions of the feedback can be compared
+type item struct {
+	key    string
+	packet []byte
+}
+
+// feedback builds every join that describes state, in the same order as a real projector's feedback dump
+func (p *FakeProjector) feedback(s State) []item {
+	var items []item
+	add := func(key string, packet []byte) {
+		// Joins that are missing from (or broken in) the JSON lists are left out
+		if packet != nil {
+			items = append(items, item{key, packet})
+		}
+	}
+	digital := func(name string, on bool) {
+		if id, ok := p.tables.codes[name]; ok {
+			add("d"+id, digitalJoin(id, on))
+		}
+	}
+	analog := func(id string, value int) {
+		add("a"+id, analogJoin(id, value))
+	}
+	serial := func(id string, text string) {
+		add("s"+id, serialJoin(id, text))
+	}
+
+	digital("Power.On", s.Power == dell.PowerOn)
+	digital("Power.Off", s.Power == dell.PowerOff)
+	for _, input := range inputOrder {
+		digital("Input."+input, s.Input == input)
+	}
+	digital("Picture.Mute", s.PictureMuted)
+	digital("Picture.Unmute", !s.PictureMuted)
+	digital("Picture.Freeze", s.Frozen)
+	digital("Picture.Unfreeze", !s.Frozen)
+	digital("Volume.Mute", s.VolumeMuted)
+	digital("Volume.Unmute", !s.VolumeMuted)
+	for _, name := range alarmOrder {
+		if id, ok := p.tables.alarms[name]; ok {
+			add("d"+id, digitalJoin(id, hasAlarm(s.Alarms, name)))
+		}
+	}
+
+	analog(LampJoin, s.LampHours)
+	analog(VolumeJoin, s.Volume)
+
+	// The name of every input goes out on the serial join that matches its digital join, byte swapped
+	for _, input := range inputOrder {
+		if id := p.tables.codes["Input."+input]; len(id) == 4 {
+			serial(id[2:]+id[:2], InputNames[input])
+		}
+	}
+	serial(p.tables.properties["Power"], s.Power.String())
+	serial(p.tables.properties["LampMode"], s.LampMode.String()+" Mode")
+	serial(p.tables.properties["Lamp"], strconv.Itoa(s.LampHours)+" Hours")
+	serial(p.tables.properties["Input"], InputNames[s.Input])
+	serial(IPJoin, s.Network.IP)
+	serial(SubnetJoin, s.Network.Subnet)
+	serial(GatewayJoin, s.Network.Gateway)
+	serial(DNSJoin, s.Network.DNS)
+	serial(p.tables.properties["MAC"], s.Network.MAC)
+	serial(PortJoin, strconv.Itoa(p.port))
+	serial(p.tables.properties["Name"], s.Name)
+	serial(p.tables.properties["Location"], s.Location)
+	serial(p.tables.properties["Resolution"], s.Resolution)
+	serial(p.tables.properties["Firmware"], s.Firmware)
+
+	return items
+}
+
+// inputOrder is the order inputs appear in the feedback dump
+var inputOrder = []string{"VGAA", "VGAB", "Composite", "SVideo", "HDMI", "Wireless", "USBDisplay", "USBViewer"}
+
+// alarmOrder is the order alarms appear in the feedback dump
+var alarmOrder = []string{"LampFailure", "OverTemperature", "FanFailure", "LampDoorOpen", "LampExpired", "ColorWheelFailure"}
+
+// hasAlarm tells us whether the alarm called name is one of alarms
+func hasAlarm(alarms dell.Alarm, name string) bool {
+	for _, n := range alarms.Names() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// dump is the answer to a status request: every join, one after the other
+func (p *FakeProjector) dump() []byte {
+	var buf bytes.Buffer
+	for _, i := range p.feedback(p.State()) {
+		buf.Write(i.packet)
+	}
+	return buf.Bytes()
+}
+
+// changes returns the joins that are different between two states, ready to be sent to clients
+func (p *FakeProjector) changes(before State, after State) []byte {
+	old := make(map[string][]byte)
+	for _, i := range p.feedback(before) {
+		old[i.key] = i.packet
+	}
+
+	var buf bytes.Buffer
+	for _, i := range p.feedback(after) {
+		if !bytes.Equal(old[i.key], i.packet) {
+			buf.Write(i.packet)
+		}
+	}
+	return buf.Bytes()
+}
+
+// packet wraps a payload in a CIP header
+func packet(packetType byte, payload []byte) []byte {
+	return append([]byte{packetType, byte(len(payload) >> 8), byte(len(payload))}, payload...)
+}
+
+// data builds a CIP data packet carrying a single join
+func data(joinType byte, join []byte) []byte {
+	return packet(cipData, append([]byte{0x00, 0x00, byte(len(join) + 1), joinType}, join...))
+}
+
+// digitalJoin builds a digital join. id is in CommandList order, and the high bit of the second byte is set if it's off
+func digitalJoin(id string, on bool) []byte {
+	b, ok := decode(id)
+	if !ok {
+		return nil
+	}
+	if !on {
+		b[1] |= 0x80
+	}
+	return data(joinDigital, b)
+}
+
+// analogJoin builds an analog join
+func analogJoin(id string, value int) []byte {
+	b, ok := decode(id)
+	if !ok {
+		return nil
+	}
+	return data(joinAnalog, append(b, byte(value>>8), byte(value)))
+}
+
+// serialJoin builds a serial join
+func serialJoin(id string, text string) []byte {
+	b, ok := decode(id)
+	if !ok {
+		return nil
+	}
+	return data(joinSerial, append(append(b, 0x03), text...))
+}
+
+// decode turns a two byte join ID into bytes
+func decode(id string) ([]byte, bool) {
+	b, err := hex.DecodeString(id)
+	return b, err == nil && len(b) == 2
+}
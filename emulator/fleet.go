@@ -0,0 +1,201 @@
+package emulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// FleetConfig describes a fleet of fake projectors. It's usually loaded from a JSON file like this one, which makes
+// 300 projectors on 127.0.1.1 to 127.0.2.44, plus one more on 127.0.0.1:
+//
+//	{
+//		"announce": "239.255.250.250:9131",
+//		"interval": "30s",
+//		"projectors": [
+//			{"count": 300, "uuid": "SIM%03d", "model": "S300wi", "name": "Room %d", "ip": "127.0.1.1"},
+//			{"uuid": "LOBBY", "model": "S500wi", "name": "Lobby", "ip": "127.0.0.1"}
+//		]
+//	}
+type FleetConfig struct {
+	Announce   string            `json:"announce"` // Where to send beacons. Defaults to MulticastAddr, and "none" turns them off
+	Interval   string            `json:"interval"` // How often to beacon, e.g. "30s". Defaults to 30 seconds
+	Projectors []ProjectorConfig `json:"projectors"`
+}
+
+// ProjectorConfig describes one projector in a fleet, or a batch of them if Count is more than 1.
+// In a batch, %d in UUID, Name and Location is replaced with the projector's number (starting at 1, so "SIM%03d"
+// becomes SIM001, SIM002 and so on), and each projector gets the next IP address along (or the next port, if Spread
+// is "port")
+type ProjectorConfig struct {
+	Count     int    `json:"count"`
+	UUID      string `json:"uuid"`
+	Make      string `json:"make"`
+	Model     string `json:"model"`
+	Revision  string `json:"revision"`
+	Name      string `json:"name"`
+	Location  string `json:"location"`
+	Input     string `json:"input"`
+	LampHours int    `json:"lamp_hours"`
+	On        bool   `json:"on"`
+	IP        string `json:"ip"`     // Defaults to 127.0.0.1. Every 127.x.x.x address is loopback on Linux, but not on macOS
+	Port      int    `json:"port"`   // Defaults to dell.CIPPort (41794), which is where dell looks for discovered projectors
+	Spread    string `json:"spread"` // "ip" (the default) or "port"
+}
+
+// Fleet is a group of fake projectors, each with its own listener and beacon
+type Fleet struct {
+	Projectors []*FakeProjector
+	Listen     []string // Where each projector listens, in the same order as Projectors
+
+	announce string
+	interval time.Duration
+}
+
+// LoadFleet reads a FleetConfig from a JSON file and makes the projectors it describes. Call Start to get them going
+func LoadFleet(path string) (*Fleet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config FleetConfig
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, err
+	}
+	return NewFleet(config)
+}
+
+// NewFleet makes the projectors described in config
+func NewFleet(config FleetConfig) (*Fleet, error) {
+	fleet := &Fleet{announce: config.Announce, interval: 30 * time.Second}
+	if fleet.announce == "" {
+		fleet.announce = MulticastAddr
+	}
+	if config.Interval != "" {
+		d, err := time.ParseDuration(config.Interval)
+		if err != nil {
+			return nil, err
+		}
+		fleet.interval = d
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range config.Projectors {
+		count := c.Count
+		if count < 1 {
+			count = 1
+		}
+		ip := net.ParseIP(c.IP)
+		if c.IP == "" {
+			ip = net.ParseIP("127.0.0.1")
+		}
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("emulator: %q isn't an IPv4 address", c.IP)
+		}
+		port := c.Port
+		if port == 0 {
+			port = dell.CIPPort
+		}
+
+		for i := 1; i <= count; i++ {
+			p := New(number(c.UUID, i))
+			if p.UUID == "" {
+				return nil, errors.New("emulator: every projector needs a UUID")
+			}
+			if seen[p.UUID] {
+				return nil, fmt.Errorf("emulator: UUID %s is used twice", p.UUID)
+			}
+			seen[p.UUID] = true
+			set(&p.Make, c.Make)
+			set(&p.Model, c.Model)
+			set(&p.Revision, c.Revision)
+			set(&p.state.Name, number(c.Name, i))
+			set(&p.state.Location, number(c.Location, i))
+			set(&p.state.Input, c.Input)
+			if c.LampHours != 0 {
+				p.state.LampHours = c.LampHours
+			}
+			if c.On {
+				p.state.Power = dell.PowerOn
+			}
+
+			host, hostPort := addIP(ip, i-1), port
+			if c.Spread == "port" {
+				host, hostPort = ip, port+i-1
+			}
+			p.state.Network.IP = host.String()
+			// Every projector gets its own (locally administered) MAC address, in case anything goes looking for them
+			n := len(fleet.Projectors) + 1
+			p.state.Network.MAC = fmt.Sprintf("02:00:00:%02X:%02X:%02X", byte(n>>16), byte(n>>8), byte(n))
+			address := net.JoinHostPort(host.String(), strconv.Itoa(hostPort))
+
+			fleet.Projectors = append(fleet.Projectors, p)
+			fleet.Listen = append(fleet.Listen, address)
+		}
+	}
+	return fleet, nil
+}
+
+// Start starts every projector listening and beaconing. If any of them can't, the ones already started are closed again
+func (f *Fleet) Start() error {
+	for i, p := range f.Projectors {
+		err := p.Listen(f.Listen[i])
+		if err == nil && f.announce != "none" {
+			err = p.Announce(f.announce, f.interval)
+		}
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("emulator: starting %s on %s: %w", p.UUID, f.Listen[i], err)
+		}
+	}
+	return nil
+}
+
+// Close stops every projector
+func (f *Fleet) Close() error {
+	for _, p := range f.Projectors {
+		p.Close()
+	}
+	return nil
+}
+
+// Get finds a projector in the fleet by its UUID
+func (f *Fleet) Get(uuid string) (*FakeProjector, bool) {
+	for _, p := range f.Projectors {
+		if p.UUID == uuid {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// number replaces %d (or something like %03d) in s with n
+func number(s string, n int) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	return fmt.Sprintf(s, n)
+}
+
+// set sets *field to value, unless value is empty
+func set(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
+
+// addIP adds n to an IPv4 address, carrying into the higher bytes (so 127.0.1.255 + 1 is 127.0.2.0)
+func addIP(ip net.IP, n int) net.IP {
+	v4 := ip.To4()
+	value := uint32(v4[0])<<24 | uint32(v4[1])<<16 | uint32(v4[2])<<8 | uint32(v4[3])
+	value += uint32(n)
+	return net.IPv4(byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+}
@@ -0,0 +1,186 @@
+package dell
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+// The projector talks Crestron CIP. Every packet starts with a type byte and a two byte (big endian) length.
+// Type 0x05 packets carry "joins", which is Crestron-speak for a numbered value. The payload of a 0x05 packet looks like:
+//
+//	00 00 <length> <join type> <join data>
+//
+// where the join type is 0x00 for digital joins (buttons / on-off states), 0x14 for analog joins (numbers)
+// and 0x15 for serial joins (strings). This is the same format our commands use. Power.On is "0400",
+// which is digital join 0x0004 being pressed.
+const (
+	cipData     = 0x05
+	joinDigital = 0x00
+	joinAnalog  = 0x14
+	joinSerial  = 0x15
+)
+
+// join is a single decoded value from a CIP data packet.
+// ID is the join number as a hex string. For digital joins we use the same byte order as CommandList (so Power.On
+// is "0400") so that feedback can be compared directly against our commands. Analog and serial joins use the
+// big endian order they're sent in (e.g. "13cd")
+type join struct {
+	Type    byte
+	ID      string
+	Digital bool
+	Analog  int
+	Serial  string
+}
+
+// parseJoins pulls as many complete CIP packets out of buf as it can, and returns the joins it found plus whatever
+// bytes are left over (because TCP might hand us half a packet, which we'll need to hang on to until the rest arrives)
+func parseJoins(buf []byte) ([]join, []byte) {
+	var joins []join
+
+	for len(buf) >= 3 {
+		length := int(buf[1])<<8 | int(buf[2])
+		if len(buf) < 3+length {
+			// Only part of this packet has arrived so far
+			break
+		}
+
+		packetType := buf[0]
+		payload := buf[3 : 3+length]
+		buf = buf[3+length:]
+
+		if packetType != cipData || len(payload) < 4 {
+			continue
+		}
+
+		data := payload[4:]
+		switch payload[3] {
+		case joinDigital:
+			if len(data) < 2 {
+				continue
+			}
+			// The high bit of the second byte is set when the join is "released" (i.e. false)
+			joins = append(joins, join{
+				Type:    joinDigital,
+				ID:      hex.EncodeToString([]byte{data[0], data[1] & 0x7f}),
+				Digital: data[1]&0x80 == 0,
+			})
+		case joinAnalog:
+			if len(data) < 4 {
+				continue
+			}
+			joins = append(joins, join{
+				Type:   joinAnalog,
+				ID:     hex.EncodeToString(data[0:2]),
+				Analog: int(data[2])<<8 | int(data[3]),
+			})
+		case joinSerial:
+			// Serial joins have a 0x03 flag byte between the join number and the text
+			if len(data) < 3 {
+				continue
+			}
+			joins = append(joins, join{
+				Type:   joinSerial,
+				ID:     hex.EncodeToString(data[0:2]),
+				Serial: string(data[3:]),
+			})
+		}
+	}
+
+	return joins, buf
+}
+
+// properties is PropertyList, unmarshalled and flipped around so we can look up a property name by its join ID
+var properties map[string]string
+
+// loadProperties unmarshals PropertyList. It's called from Init so that any changes made to PropertyList beforehand are picked up
+func loadProperties() error {
+	var ids map[string]string
+	err := json.Unmarshal(PropertyList, &ids)
+	if err != nil {
+		return err
+	}
+
+	properties = make(map[string]string)
+	for name, id := range ids {
+		properties[strings.ToLower(id)] = name
+	}
+
+	return nil
+}
+
+// applyJoin updates projector with the value from a single join. Power feedback isn't applied here
+// because it goes through the power state machine instead (see power.go)
+func applyJoin(projector *Projector, j join) {
+	switch j.Type {
+	case joinDigital:
+		if applyAlarm(projector, j) {
+			return
+		}
+		switch j.ID {
+		case Commands.Volume.Mute:
+			projector.VolumeMuted = j.Digital
+		case Commands.Picture.Mute:
+			projector.PictureMuted = j.Digital
+		case Commands.Picture.Freeze:
+			projector.Frozen = j.Digital
+		}
+	case joinSerial:
+		switch properties[j.ID] {
+		case "Input":
+			projector.Source = j.Serial
+		case "Name":
+			projector.Name = j.Serial
+		case "Location":
+			projector.Location = j.Serial
+		case "Resolution":
+			projector.Resolution = j.Serial
+		case "Lamp":
+			if hours, ok := ParseLampHours(j.Serial); ok {
+				projector.LampHours = hours
+			}
+		case "LampMode":
+			projector.LampMode = ParseLampMode(j.Serial)
+		}
+	}
+}
+
+// powerFeedback works out whether a join tells us anything about the power state.
+// Digital feedback mirrors the Power.On / Power.Off commands, and the "Power" serial join holds some text like "On"
+func powerFeedback(j join) (PowerState, bool) {
+	switch j.Type {
+	case joinDigital:
+		if !j.Digital {
+			return PowerUnknown, false
+		}
+		switch j.ID {
+		case Commands.Power.On:
+			return PowerOn, true
+		case Commands.Power.Off:
+			return PowerOff, true
+		}
+	case joinSerial:
+		if properties[j.ID] != "Power" {
+			return PowerUnknown, false
+		}
+		return ParsePowerState(j.Serial)
+	}
+
+	return PowerUnknown, false
+}
+
+// ParsePowerState turns the text the projector shows for its power state (e.g. "On" or "Warming Up") into a PowerState
+func ParsePowerState(text string) (PowerState, bool) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	switch {
+	case strings.Contains(text, "warm"):
+		return PowerWarmingUp, true
+	case strings.Contains(text, "cool"):
+		return PowerCoolingDown, true
+	case text == "on":
+		return PowerOn, true
+	case text == "off" || text == "standby":
+		return PowerOff, true
+	}
+	return PowerUnknown, false
+}
@@ -0,0 +1,269 @@
+package dell
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// GroupConcurrency is how many projectors a group command talks to at once. Anything less than 1 is treated as 1
+var GroupConcurrency = 16
+
+// ErrUnknownGroup is returned by GetGroup when there's no group with that name
+var ErrUnknownGroup = errors.New("unknown group")
+
+// Group is a named set of projectors that can be controlled together, such as everything in a building or both
+// projectors in a lecture theatre. A projector is in the group if it's picked out by any of UUIDs, Location, Match or
+// Lookup, so they can be combined:
+//
+//	dell.DefineGroup(dell.Group{Name: "LT2", Location: "Lecture Theatre 2"})
+//	group, _ := dell.GetGroup("LT2")
+//	report := group.SetInput("HDMI")
+//
+// Tag queries and rooms from an inventory file can be used through Lookup (the inventory package has TagGroup and
+// RoomGroup to make those)
+type Group struct {
+	Name     string
+	UUIDs    []string             // A fixed list of projectors, which are reported as offline if we aren't connected to them
+	Location string               // Every projector whose Location (as set on the projector itself) is this. Not case sensitive
+	Match    func(Projector) bool // Every projector in Projectors that this returns true for
+	Lookup   func() []string      // UUIDs worked out every time the group is used, for groups whose members change
+}
+
+// The Status of a Result
+const (
+	ResultOK      = "ok"
+	ResultFailed  = "failed"
+	ResultOffline = "offline"
+)
+
+// Result is what happened to one projector in a group command
+type Result struct {
+	UUID   string
+	Name   string
+	IP     string
+	Status string // ResultOK, ResultFailed or ResultOffline
+	Error  string `json:",omitempty"`
+	Err    error  `json:"-"`
+}
+
+// Report is what happened to every projector in a group command, in UUID order
+type Report struct {
+	Group   string
+	Action  string // What was done, e.g. "Power.On"
+	Results []Result
+}
+
+// Succeeded lists the projectors the command worked on
+func (r Report) Succeeded() []Result {
+	return r.filter(ResultOK)
+}
+
+// Failed lists the projectors we're connected to that the command didn't work on
+func (r Report) Failed() []Result {
+	return r.filter(ResultFailed)
+}
+
+// Offline lists the projectors in the group that we aren't connected to
+func (r Report) Offline() []Result {
+	return r.filter(ResultOffline)
+}
+
+// OK tells us whether the command worked on every projector in the group
+func (r Report) OK() bool {
+	return len(r.Succeeded()) == len(r.Results)
+}
+
+func (r Report) filter(status string) []Result {
+	var results []Result
+	for _, result := range r.Results {
+		if result.Status == status {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// groups holds the groups made with DefineGroup, keyed by name
+var groups = make(map[string]Group)
+var groupsLock sync.Mutex
+
+// DefineGroup adds a group (or replaces the one with the same name) so it can be found with GetGroup
+func DefineGroup(group Group) error {
+	if group.Name == "" {
+		return errors.New("groups need a name")
+	}
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+	groups[group.Name] = group
+	return nil
+}
+
+// GetGroup finds a group made with DefineGroup
+func GetGroup(name string) (Group, error) {
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+	group, ok := groups[name]
+	if !ok {
+		return Group{}, ErrUnknownGroup
+	}
+	return group, nil
+}
+
+// RemoveGroup forgets about a group. The projectors in it aren't affected
+func RemoveGroup(name string) {
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+	delete(groups, name)
+}
+
+// GroupNames lists the groups made with DefineGroup, in alphabetical order
+func GroupNames() []string {
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Members lists the projectors in the group, in UUID order. Projectors we aren't connected to only have their UUID set
+func (g Group) Members() []Projector {
+	members := make(map[string]Projector)
+	named := append([]string(nil), g.UUIDs...)
+	if g.Lookup != nil {
+		named = append(named, g.Lookup()...)
+	}
+	for _, uuid := range named {
+		if projector, ok := GetProjector(uuid); ok {
+			members[uuid] = projector
+		} else {
+			members[uuid] = Projector{UUID: uuid}
+		}
+	}
+	if g.Location != "" || g.Match != nil {
+		for _, projector := range ListProjectors() {
+			if (g.Location != "" && strings.EqualFold(strings.TrimSpace(projector.Location), strings.TrimSpace(g.Location))) ||
+				(g.Match != nil && g.Match(projector)) {
+				members[projector.UUID] = projector
+			}
+		}
+	}
+
+	list := make([]Projector, 0, len(members))
+	for _, projector := range members {
+		list = append(list, projector)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UUID < list[j].UUID })
+	return list
+}
+
+// Do runs action on every projector in the group at once (GroupConcurrency at a time), and reports what happened.
+// Projectors we aren't connected to are reported as offline without action being run for them. name is what goes in
+// the report's Action
+func (g Group) Do(name string, action func(Projector) error) Report {
+	members := g.Members()
+	report := Report{Group: g.Name, Action: name, Results: make([]Result, len(members))}
+
+	var wait sync.WaitGroup
+	concurrency := GroupConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	for i, projector := range members {
+		report.Results[i] = Result{UUID: projector.UUID, Name: projector.Name, IP: projector.IP}
+		if !projector.Connected() {
+			report.Results[i].Status = ResultOffline
+			continue
+		}
+
+		wait.Add(1)
+		slots <- struct{}{}
+		go func(result *Result, projector Projector) {
+			defer wait.Done()
+			defer func() { <-slots }()
+
+			err := action(projector)
+			switch {
+			case err == nil:
+				result.Status = ResultOK
+			case errors.Is(err, ErrOffline):
+				// It went away between us listing the group and getting to it
+				result.Status = ResultOffline
+			default:
+				result.Status = ResultFailed
+			}
+			if err != nil {
+				result.Err, result.Error = err, err.Error()
+			}
+		}(&report.Results[i], projector)
+	}
+	wait.Wait()
+	return report
+}
+
+// SendCommand sends a command (such as Commands.Power.On) to every projector in the group
+func (g Group) SendCommand(command string) Report {
+	name := CommandName(command)
+	if name == "" {
+		name = command
+	}
+	return g.Do(name, func(projector Projector) error {
+		_, err := SendCommand(projector, command)
+		return err
+	})
+}
+
+// SendNamedCommand sends a command by its dotted name (e.g. "Input.HDMI") to every projector in the group whose
+// model supports it. The others are reported as failed, with ErrUnsupported
+func (g Group) SendNamedCommand(name string) Report {
+	return g.Do(name, func(projector Projector) error {
+		_, err := SendNamedCommand(projector, name)
+		return err
+	})
+}
+
+// SetPower turns every projector in the group on or off
+func (g Group) SetPower(on bool) Report {
+	if on {
+		return g.SendNamedCommand("Power.On")
+	}
+	return g.SendNamedCommand("Power.Off")
+}
+
+// SetInput changes the input of every projector in the group. input is one of the names from InputNames
+func (g Group) SetInput(input string) Report {
+	return g.SendNamedCommand("Input." + input)
+}
+
+// SetMute mutes or unmutes every projector in the group
+func (g Group) SetMute(muted bool) Report {
+	if muted {
+		return g.SendNamedCommand("Volume.Mute")
+	}
+	return g.SendNamedCommand("Volume.Unmute")
+}
+
+// SetPictureMute blanks or unblanks every projector in the group
+func (g Group) SetPictureMute(muted bool) Report {
+	if muted {
+		return g.SendNamedCommand("Picture.Mute")
+	}
+	return g.SendNamedCommand("Picture.Unmute")
+}
+
+// ChangeVolume turns every projector in the group up or down by a number of steps
+func (g Group) ChangeVolume(steps int) Report {
+	name := "Volume.Up"
+	if steps < 0 {
+		name = "Volume.Down"
+	}
+	return g.Do(name, func(projector Projector) error {
+		_, err := ChangeVolume(projector, steps)
+		return err
+	})
+}
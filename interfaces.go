@@ -0,0 +1,153 @@
+package dell
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ErrNoInterface is returned by FindInterface when nothing matches
+var ErrNoInterface = errors.New("no matching network interface")
+
+// FindInterface finds a network interface by its name (e.g. "eth1") or by a CIDR that one of its addresses is in
+// (e.g. "10.20.0.0/16"), for servers with a network card per AV VLAN
+func FindInterface(spec string) (*net.Interface, error) {
+	if !strings.Contains(spec, "/") {
+		iface, err := net.InterfaceByName(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrNoInterface, spec)
+		}
+		return iface, nil
+	}
+
+	_, network, err := net.ParseCIDR(spec)
+	if err != nil {
+		return nil, err
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ifaces {
+		for _, ip := range interfaceIPs(&ifaces[i]) {
+			if network.Contains(ip) {
+				return &ifaces[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoInterface, spec)
+}
+
+// MulticastSourceOn is like MulticastSource, but joins the multicast group on a particular interface. Projectors found
+// through it have their Interface set, and are connected to through that interface
+func MulticastSourceOn(addr string, iface *net.Interface) (*UDPSource, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", iface, udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPSource{conn: conn, iface: iface}, nil
+}
+
+// ListenInterfaces is like Listen, but joins the multicast group on each of the given interfaces (names or CIDRs, as
+// used by FindInterface) rather than whichever one the operating system picks. Like Listen, it only returns if
+// something goes wrong
+func ListenInterfaces(specs ...string) (bool, error) {
+	var sources []*UDPSource
+	closeAll := func() {
+		for _, s := range sources {
+			s.Close()
+		}
+	}
+
+	for _, spec := range specs {
+		iface, err := FindInterface(spec)
+		if err != nil {
+			closeAll()
+			return false, err
+		}
+		source, err := MulticastSourceOn(MulticastAddr, iface)
+		if err != nil {
+			closeAll()
+			return false, err
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return Listen()
+	}
+
+	errs := make(chan error, len(sources))
+	for _, source := range sources {
+		go func(source *UDPSource) {
+			_, err := ListenOn(source)
+			errs <- err
+		}(source)
+	}
+	err := <-errs
+	closeAll()
+	return false, err
+}
+
+// Interface is the interface the source is listening on, or nil if it's listening on all of them
+func (s *UDPSource) Interface() *net.Interface {
+	return s.iface
+}
+
+// interfaceFor works out which interface a beacon arrived on. The operating system can hand a multicast packet to
+// every socket that's joined the group, whichever interface it came in on, so we look for the interface whose
+// network the sender is on, and only fall back to the source's own interface if there isn't one
+func interfaceFor(source BeaconSource, ip net.IP) string {
+	s, ok := source.(*UDPSource)
+	if !ok || s.iface == nil {
+		return ""
+	}
+
+	ifaces, err := net.Interfaces()
+	if err == nil {
+		for i := range ifaces {
+			addrs, _ := ifaces[i].Addrs()
+			for _, addr := range addrs {
+				if network, ok := addr.(*net.IPNet); ok && network.Contains(ip) {
+					return ifaces[i].Name
+				}
+			}
+		}
+	}
+	return s.iface.Name
+}
+
+// interfaceIPs returns the addresses of an interface
+func interfaceIPs(iface *net.Interface) []net.IP {
+	var ips []net.IP
+	addrs, _ := iface.Addrs()
+	for _, addr := range addrs {
+		if network, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, network.IP)
+		}
+	}
+	return ips
+}
+
+// localAddr picks an address on the named interface to connect from, so that connections to a projector go out the
+// interface it was discovered on. It returns nil (let the operating system choose) if there's no interface, or it
+// doesn't have an address of the right kind
+func localAddr(name string, remote net.IP) *net.TCPAddr {
+	if name == "" {
+		return nil
+	}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range interfaceIPs(iface) {
+		if (ip.To4() != nil) == (remote.To4() != nil) && !ip.IsLinkLocalUnicast() {
+			return &net.TCPAddr{IP: ip}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package inventory
+
+import (
+	"strings"
+
+	"github.com/Grayda/go-dell"
+)
+
+// Tagged lists the UUIDs (or IP addresses, for entries without one) of the projectors that match a tag query. Every
+// tag in the query has to be there, apart from ones starting with !, which mustn't be. Tagged("building-c", "!spare")
+// is everything in Building C that isn't a spare
+func (inv *Inventory) Tagged(query ...string) []string {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	var uuids []string
+	for _, e := range inv.entries {
+		matches := true
+		for _, tag := range query {
+			if strings.HasPrefix(tag, "!") {
+				matches = matches && !e.HasTag(tag[1:])
+			} else {
+				matches = matches && e.HasTag(tag)
+			}
+		}
+		if matches {
+			uuids = append(uuids, e.key())
+		}
+	}
+	return uuids
+}
+
+// InRoom lists the UUIDs of the projectors in a room. Room names aren't case sensitive
+func (inv *Inventory) InRoom(room string) []string {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	var uuids []string
+	for _, e := range inv.entries {
+		if strings.EqualFold(strings.TrimSpace(e.Room), strings.TrimSpace(room)) {
+			uuids = append(uuids, e.key())
+		}
+	}
+	return uuids
+}
+
+// TagGroup makes a group of the projectors that match a tag query (see Tagged). The query is run every time the group
+// is used, so it keeps up with changes to the inventory
+func (inv *Inventory) TagGroup(name string, query ...string) dell.Group {
+	query = append([]string(nil), query...)
+	return dell.Group{Name: name, Lookup: func() []string { return inv.Tagged(query...) }}
+}
+
+// RoomGroup makes a group of the projectors in a room: the ones the inventory says are there, and the ones whose own
+// Location setting says they're there
+func (inv *Inventory) RoomGroup(name string, room string) dell.Group {
+	return dell.Group{Name: name, Location: room, Lookup: func() []string { return inv.InRoom(room) }}
+}
@@ -0,0 +1,277 @@
+// Package inventory keeps a list of projectors in a file, so that projectors that don't beacon (or that you'd rather
+// not wait 30 seconds for) survive a restart, and so there's somewhere to record what the projectors themselves don't
+// know, such as the room they're in. The file is JSON, or YAML if its name ends in .yaml or .yml:
+//
+//	projectors:
+//	  - uuid: 0005A6123456
+//	    ip: 10.20.3.14
+//	    name: LT2 Left
+//	    room: Lecture Theatre 2
+//	    tags: [building-c, lecture]
+//	  - uuid: LOBBY
+//	    ip: 10.20.1.5
+//	    protocol: pjlink
+//	    credentials: env:LOBBY_PJLINK
+//
+// Only a small part of YAML is understood: a list of projectors under "projectors", each a set of "key: value" lines,
+// with tags written either as [a, b] or as a list. Anything fancier is an error, rather than being quietly misread
+//
+//	inv, err := inventory.Load("projectors.yaml")
+//	err = inv.Connect()                      // Connect to everything in the file
+//	stop := inv.Track(nil)                   // Add discovered projectors to it
+//	unwatch := inv.Watch(5*time.Second, nil) // Pick up edits to the file
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// Entry is a projector in the inventory
+type Entry struct {
+	UUID        string   `json:"uuid"`
+	IP          string   `json:"ip"`
+	Port        int      `json:"port,omitempty"`     // Leave it out to use the protocol's usual port
+	Protocol    string   `json:"protocol,omitempty"` // Defaults to dell.ProtocolCIP
+	Name        string   `json:"name,omitempty"`
+	Room        string   `json:"room,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Credentials string   `json:"credentials,omitempty"` // Where to find the projector's password (see Resolve), never the password itself
+}
+
+// file is how the inventory is laid out on disk
+type file struct {
+	Projectors []Entry `json:"projectors"`
+}
+
+// Resolve turns an Entry's Credentials into a password. By default it understands "env:NAME" (the NAME environment
+// variable) and "file:/path" (the contents of a file, without a trailing newline). Replace it to use a secrets manager
+var Resolve = func(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		password, ok := os.LookupEnv(strings.TrimPrefix(ref, "env:"))
+		if !ok {
+			return "", errors.New("inventory: environment variable " + strings.TrimPrefix(ref, "env:") + " isn't set")
+		}
+		return password, nil
+	case strings.HasPrefix(ref, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return "", errors.New("inventory: don't know how to find credentials " + ref)
+}
+
+// HasTag tells us whether the entry has a tag. Tags aren't case sensitive
+func (e Entry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Projector turns the entry into something that can be passed to dell.AddProjector, looking up its password if it
+// has Credentials. Entries without a UUID (such as PJLink projectors, which don't have one) use their IP address
+func (e Entry) Projector() (dell.Projector, error) {
+	projector := dell.Projector{
+		UUID:     e.UUID,
+		IP:       e.IP,
+		Port:     e.Port,
+		Protocol: e.Protocol,
+		Name:     e.Name,
+		Location: e.Room,
+		Model:    e.Model,
+	}
+	if projector.UUID == "" {
+		projector.UUID = e.IP
+	}
+	if e.Credentials != "" {
+		password, err := Resolve(e.Credentials)
+		if err != nil {
+			return dell.Projector{}, err
+		}
+		projector.Password = password
+	}
+	return projector, nil
+}
+
+// key is what the entry is known by in dell.Projectors
+func (e Entry) key() string {
+	if e.UUID == "" {
+		return e.IP
+	}
+	return e.UUID
+}
+
+// copy returns a copy of the entry that doesn't share its tags
+func (e Entry) copy() Entry {
+	e.Tags = append([]string(nil), e.Tags...)
+	return e
+}
+
+// Inventory is a list of projectors, kept in a file
+type Inventory struct {
+	Path string
+
+	lock      sync.Mutex
+	entries   []Entry
+	modified  time.Time // When the file was last changed, as far as we know
+	size      int64
+	connected bool                // Set by Connect, so Watch knows to connect to new entries
+	reported  map[string]struct{} // Conflicts Track has already reported
+}
+
+// Load reads an inventory file. If the file doesn't exist yet, the inventory is empty and Save will create it
+func Load(path string) (*Inventory, error) {
+	inv := &Inventory{Path: path}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return inv, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	inv.entries = entries
+	inv.modified, inv.size = info.ModTime(), info.Size()
+	return inv, nil
+}
+
+// read reads the entries from a file
+func read(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isYAML(path) {
+		return parseYAML(data)
+	}
+	var f file
+	err = json.Unmarshal(data, &f)
+	return f.Projectors, err
+}
+
+// isYAML tells us whether the file should be YAML, going by its name
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// Save writes the inventory to its file. The file is replaced in one go, so anything watching it never sees half of it
+func (inv *Inventory) Save() error {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	return inv.save()
+}
+
+// save writes the inventory to its file (inv.lock must be held)
+func (inv *Inventory) save() error {
+	var data []byte
+	var err error
+	if isYAML(inv.Path) {
+		data = marshalYAML(inv.entries)
+	} else {
+		entries := inv.entries
+		if entries == nil {
+			entries = []Entry{}
+		}
+		data, err = json.MarshalIndent(file{entries}, "", "\t")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(inv.Path), "."+filepath.Base(inv.Path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), inv.Path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	// So Watch doesn't think someone else has changed it
+	if info, err := os.Stat(inv.Path); err == nil {
+		inv.modified, inv.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// Entries lists everything in the inventory, in the order it's in the file
+func (inv *Inventory) Entries() []Entry {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	entries := make([]Entry, len(inv.entries))
+	for i, e := range inv.entries {
+		entries[i] = e.copy()
+	}
+	return entries
+}
+
+// Get finds a projector by its UUID (or its IP address, for entries without one)
+func (inv *Inventory) Get(uuid string) (Entry, bool) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	i := inv.find(uuid)
+	if i < 0 {
+		return Entry{}, false
+	}
+	return inv.entries[i].copy(), true
+}
+
+// Put adds a projector to the inventory, or replaces the one with the same UUID. It doesn't save the file
+func (inv *Inventory) Put(entry Entry) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	entry = entry.copy()
+	if i := inv.find(entry.key()); i >= 0 {
+		inv.entries[i] = entry
+		return
+	}
+	inv.entries = append(inv.entries, entry)
+}
+
+// Remove takes a projector out of the inventory. It doesn't save the file, or disconnect from the projector
+func (inv *Inventory) Remove(uuid string) bool {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	i := inv.find(uuid)
+	if i < 0 {
+		return false
+	}
+	inv.entries = append(inv.entries[:i], inv.entries[i+1:]...)
+	return true
+}
+
+// find returns the index of the first entry known as key, or -1 (inv.lock must be held)
+func (inv *Inventory) find(key string) int {
+	for i, e := range inv.entries {
+		if e.key() == key {
+			return i
+		}
+	}
+	return -1
+}
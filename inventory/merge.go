@@ -0,0 +1,204 @@
+package inventory
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Grayda/go-dell"
+)
+
+// The kinds of Conflict
+const (
+	ConflictSharedIP      = "sharedip"      // More than one UUID at the same address
+	ConflictDuplicateUUID = "duplicateuuid" // The same UUID in the inventory more than once
+)
+
+// Conflict is something in the inventory that can't be right, and needs a person to sort it out. Usually it's a
+// projector that's been replaced, or a DHCP address that's been handed to a different projector
+type Conflict struct {
+	Kind  string
+	Key   string   // The address (for ConflictSharedIP) or UUID (for ConflictDuplicateUUID)
+	UUIDs []string // The UUIDs involved
+}
+
+// String describes the conflict
+func (c Conflict) String() string {
+	switch c.Kind {
+	case ConflictSharedIP:
+		return strings.Join(c.UUIDs, ", ") + " are all at " + c.Key
+	case ConflictDuplicateUUID:
+		return c.Key + " is in the inventory " + strconv.Itoa(len(c.UUIDs)) + " times"
+	}
+	return c.Kind + " " + c.Key
+}
+
+// Merge adds a discovered projector to the inventory, or updates its address and model if it's already there.
+// Everything that only a person would know (its room, tags, credentials, and its name once it has one) is left alone.
+// changed tells us whether anything was changed (and so whether it needs saving), and conflicts lists any conflicts
+// the projector is part of. It doesn't save the file
+func (inv *Inventory) Merge(projector dell.Projector) (changed bool, conflicts []Conflict) {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+
+	i := inv.find(projector.UUID)
+	if i < 0 {
+		entry := Entry{UUID: projector.UUID, IP: projector.IP, Port: projector.Port, Model: projector.Model, Room: projector.Location}
+		if projector.Protocol != dell.ProtocolCIP {
+			entry.Protocol = projector.Protocol
+		}
+		if projector.Name != projector.UUID {
+			// dell names projectors after their UUID until they tell us their real name
+			entry.Name = projector.Name
+		}
+		inv.entries = append(inv.entries, entry)
+		changed = true
+	} else {
+		entry := &inv.entries[i]
+		if entry.IP != projector.IP || entry.Port != projector.Port {
+			entry.IP, entry.Port = projector.IP, projector.Port
+			changed = true
+		}
+		if projector.Model != "" && entry.Model != projector.Model {
+			entry.Model = projector.Model
+			changed = true
+		}
+		if entry.Name == "" && projector.Name != "" && projector.Name != projector.UUID {
+			entry.Name = projector.Name
+			changed = true
+		}
+	}
+
+	for _, c := range inv.conflicts() {
+		for _, uuid := range c.UUIDs {
+			if uuid == projector.UUID {
+				conflicts = append(conflicts, c)
+				break
+			}
+		}
+	}
+	return changed, conflicts
+}
+
+// Conflicts lists everything in the inventory that can't be right
+func (inv *Inventory) Conflicts() []Conflict {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	return inv.conflicts()
+}
+
+// conflicts lists the conflicts in the inventory, sorted so the same conflicts always come out the same way
+// (inv.lock must be held)
+func (inv *Inventory) conflicts() []Conflict {
+	byAddr := make(map[string][]string)
+	byUUID := make(map[string]int)
+	for _, e := range inv.entries {
+		if e.UUID != "" {
+			byUUID[e.UUID]++
+		}
+		if e.IP == "" {
+			continue
+		}
+		addr := e.IP
+		if e.Port != 0 {
+			// Projectors on their usual port are all at the same "address" as far as we're concerned, but emulated
+			// ones sharing an IP address on different ports aren't in each other's way
+			addr = net.JoinHostPort(e.IP, strconv.Itoa(e.Port))
+		}
+		byAddr[addr] = append(byAddr[addr], e.key())
+	}
+
+	var conflicts []Conflict
+	for addr, uuids := range byAddr {
+		unique := dedupe(uuids)
+		if len(unique) > 1 {
+			conflicts = append(conflicts, Conflict{Kind: ConflictSharedIP, Key: addr, UUIDs: unique})
+		}
+	}
+	for uuid, count := range byUUID {
+		if count > 1 {
+			uuids := make([]string, count)
+			for i := range uuids {
+				uuids[i] = uuid
+			}
+			conflicts = append(conflicts, Conflict{Kind: ConflictDuplicateUUID, Key: uuid, UUIDs: uuids})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Kind != conflicts[j].Kind {
+			return conflicts[i].Kind < conflicts[j].Kind
+		}
+		return conflicts[i].Key < conflicts[j].Key
+	})
+	return conflicts
+}
+
+// dedupe sorts a list and takes out anything that's in it twice
+func dedupe(list []string) []string {
+	sorted := append([]string(nil), list...)
+	sort.Strings(sorted)
+	var unique []string
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// Track merges every projector dell finds (by beacon or by Scan) into the inventory, and saves it whenever something
+// changes. onConflict (which can be nil) is called once for each new conflict, the first time it turns up.
+// It returns a function that stops tracking
+func (inv *Inventory) Track(onConflict func(Conflict)) func() {
+	events, unsubscribe := dell.Subscribe()
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case e := <-events:
+				if e.Name != "projectorfound" && e.Name != "projectorupdated" {
+					continue
+				}
+				changed, conflicts := inv.Merge(e.ProjectorInfo)
+				if changed {
+					inv.Save()
+				}
+				for _, c := range inv.newConflicts(conflicts) {
+					if onConflict != nil {
+						onConflict(c)
+					}
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			unsubscribe()
+		})
+	}
+}
+
+// newConflicts filters out the conflicts we've reported before
+func (inv *Inventory) newConflicts(conflicts []Conflict) []Conflict {
+	inv.lock.Lock()
+	defer inv.lock.Unlock()
+	if inv.reported == nil {
+		inv.reported = make(map[string]struct{})
+	}
+	var fresh []Conflict
+	for _, c := range conflicts {
+		key := c.Kind + " " + c.Key + " " + strings.Join(c.UUIDs, ",")
+		if _, ok := inv.reported[key]; !ok {
+			inv.reported[key] = struct{}{}
+			fresh = append(fresh, c)
+		}
+	}
+	return fresh
+}
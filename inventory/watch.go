@@ -0,0 +1,137 @@
+package inventory
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// Connect connects to every projector in the inventory that isn't already in dell.Projectors. It carries on past
+// projectors it can't connect to, and returns all of their errors together. After Connect, Watch keeps dell.Projectors
+// in step with the file
+func (inv *Inventory) Connect() error {
+	inv.lock.Lock()
+	inv.connected = true
+	inv.lock.Unlock()
+
+	var errs []error
+	for _, e := range inv.Entries() {
+		if err := connect(e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// connect connects to a single entry's projector
+func connect(e Entry) error {
+	projector, err := e.Projector()
+	if err != nil {
+		return err
+	}
+	_, err = dell.AddProjector(projector)
+	return err
+}
+
+// disconnect drops our connection to an entry's projector, if we have one
+func disconnect(e Entry) {
+	if projector, ok := dell.GetProjector(e.key()); ok {
+		dell.RemoveProjector(projector)
+	}
+}
+
+// Watch checks the file every interval, and reloads it when it's been changed by something else (such as a person
+// with a text editor). If Connect has been called, projectors added to the file are connected to, ones taken out of
+// it are disconnected, and ones whose address has changed are reconnected. If the file can't be read (e.g. it's been
+// saved half way through an edit), the inventory is left as it was until the file is fixed.
+// onReload (which can be nil) is called after every reload, with the error if there was one.
+// It returns a function that stops watching
+func (inv *Inventory) Watch(interval time.Duration, onReload func(error)) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				reloaded, err := inv.reload()
+				if reloaded && onReload != nil {
+					onReload(err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// reload reads the file again if it's changed since we last read or wrote it. reloaded is false if it hasn't changed
+func (inv *Inventory) reload() (reloaded bool, err error) {
+	info, err := os.Stat(inv.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// It may be between being deleted and written again, so we'll wait for it to come back
+			return false, nil
+		}
+		return true, err
+	}
+
+	inv.lock.Lock()
+	if info.ModTime().Equal(inv.modified) && info.Size() == inv.size {
+		inv.lock.Unlock()
+		return false, nil
+	}
+	// Even if it can't be read, we don't want to try again until it's changed again
+	inv.modified, inv.size = info.ModTime(), info.Size()
+	inv.lock.Unlock()
+
+	entries, err := read(inv.Path)
+	if err != nil {
+		return true, err
+	}
+
+	inv.lock.Lock()
+	old := inv.entries
+	inv.entries = entries
+	connected := inv.connected
+	inv.lock.Unlock()
+
+	if connected {
+		inv.sync(old, entries)
+	}
+	return true, nil
+}
+
+// sync connects to projectors that have been added to the file, disconnects from ones that have been taken out of it,
+// and reconnects to ones that have moved
+func (inv *Inventory) sync(old []Entry, entries []Entry) {
+	before := make(map[string]Entry)
+	for _, e := range old {
+		before[e.key()] = e
+	}
+	after := make(map[string]bool)
+
+	for _, e := range entries {
+		after[e.key()] = true
+		previous, existed := before[e.key()]
+		if existed && (previous.IP != e.IP || previous.Port != e.Port || previous.Protocol != e.Protocol ||
+			previous.Credentials != e.Credentials) {
+			disconnect(previous)
+		}
+		// AddProjector leaves alone projectors we're already connected to
+		connect(e)
+	}
+	for key, e := range before {
+		if !after[key] {
+			disconnect(e)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package inventory
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseYAML reads the small part of YAML that inventory files use. See the package documentation for what that is
+func parseYAML(data []byte) ([]Entry, error) {
+	var entries []Entry
+	var entry *Entry
+	started := false // Whether we've seen "projectors:"
+	itemIndent := -1 // How far the keys of the current projector are indented
+	inTags := false  // Whether we're in a block list of tags
+	tagsIndent := 0  // How far the "tags:" key was indented
+
+	for n, line := range strings.Split(string(data), "\n") {
+		fail := func(format string, args ...interface{}) ([]Entry, error) {
+			return nil, fmt.Errorf("inventory: line %d: "+format, append([]interface{}{n + 1}, args...)...)
+		}
+
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		if strings.TrimSpace(line) == "" || line == "---" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.HasPrefix(line[indent:], "\t") {
+			return fail("tabs can't be used for indentation")
+		}
+		text := line[indent:]
+
+		if !started {
+			if indent != 0 || (text != "projectors:" && text != "projectors: []") {
+				return fail("expected projectors:")
+			}
+			started = true
+			continue
+		}
+		if indent == 0 {
+			return fail("only projectors: is allowed at the top level")
+		}
+
+		if inTags && indent > tagsIndent && strings.HasPrefix(text, "- ") {
+			tag, err := unquote(strings.TrimSpace(text[2:]))
+			if err != nil {
+				return fail("%v", err)
+			}
+			entry.Tags = append(entry.Tags, tag)
+			continue
+		}
+		inTags = false
+
+		if strings.HasPrefix(text, "- ") || text == "-" {
+			// A new projector, which may have its first key on the same line
+			entries = append(entries, Entry{})
+			entry = &entries[len(entries)-1]
+			text = strings.TrimLeft(strings.TrimPrefix(text, "-"), " ")
+			itemIndent = len(line) - len(text)
+			if text == "" {
+				itemIndent = -1 // We'll find out from the next line
+				continue
+			}
+		} else if entry == nil {
+			return fail("expected - before the first projector")
+		} else if itemIndent == -1 {
+			itemIndent = indent
+		} else if indent != itemIndent {
+			return fail("unexpected indentation")
+		}
+
+		colon := strings.Index(text, ":")
+		if colon < 1 || (colon+1 < len(text) && text[colon+1] != ' ') {
+			return fail("expected key: value")
+		}
+		key, value := text[:colon], strings.TrimSpace(text[colon+1:])
+		if key == "tags" && value == "" {
+			inTags, tagsIndent = true, itemIndent
+			continue
+		}
+		err := setField(entry, key, value)
+		if err != nil {
+			return fail("%v", err)
+		}
+	}
+	if !started && len(bytes.TrimSpace(data)) > 0 {
+		return nil, fmt.Errorf("inventory: expected projectors:")
+	}
+	return entries, nil
+}
+
+// setField sets one of an entry's fields from a YAML value
+func setField(entry *Entry, key string, value string) error {
+	if key == "tags" {
+		if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+			return fmt.Errorf("tags should be a list, like [a, b]")
+		}
+		entry.Tags = nil
+		for _, tag := range splitFlow(value[1 : len(value)-1]) {
+			tag, err := unquote(tag)
+			if err != nil {
+				return err
+			}
+			entry.Tags = append(entry.Tags, tag)
+		}
+		return nil
+	}
+
+	text, err := unquote(value)
+	if err != nil {
+		return err
+	}
+	switch key {
+	case "uuid":
+		entry.UUID = text
+	case "ip":
+		entry.IP = text
+	case "port":
+		entry.Port, err = strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("port should be a number")
+		}
+	case "protocol":
+		entry.Protocol = text
+	case "name":
+		entry.Name = text
+	case "room":
+		entry.Room = text
+	case "model":
+		entry.Model = text
+	case "credentials":
+		entry.Credentials = text
+	default:
+		return fmt.Errorf("unknown key %s", key)
+	}
+	return nil
+}
+
+// stripComment removes a # comment from the end of a line, unless the # is in quotes
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitFlow splits the inside of a [a, b] list at its commas, leaving commas in quotes alone
+func splitFlow(text string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(text[start:]); last != "" || len(items) > 0 {
+		items = append(items, last)
+	}
+	return items
+}
+
+// unquote reads a YAML scalar, which can be in double quotes, single quotes or neither
+func unquote(value string) (string, error) {
+	switch {
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		return strconv.Unquote(value)
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	case strings.HasPrefix(value, "\"") || strings.HasPrefix(value, "'"):
+		return "", fmt.Errorf("unterminated quote")
+	}
+	return value, nil
+}
+
+// marshalYAML writes entries in the same form parseYAML reads
+func marshalYAML(entries []Entry) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("projectors:\n")
+	for _, e := range entries {
+		first := true
+		write := func(key string, value string) {
+			if first {
+				buf.WriteString("  - ")
+				first = false
+			} else {
+				buf.WriteString("    ")
+			}
+			buf.WriteString(key + ": " + value + "\n")
+		}
+		write("uuid", quote(e.UUID))
+		write("ip", quote(e.IP))
+		if e.Port != 0 {
+			write("port", strconv.Itoa(e.Port))
+		}
+		for _, field := range []struct{ key, value string }{
+			{"protocol", e.Protocol}, {"name", e.Name}, {"room", e.Room}, {"model", e.Model},
+		} {
+			if field.value != "" {
+				write(field.key, quote(field.value))
+			}
+		}
+		if len(e.Tags) > 0 {
+			tags := make([]string, len(e.Tags))
+			for i, tag := range e.Tags {
+				tags[i] = quote(tag)
+			}
+			write("tags", "["+strings.Join(tags, ", ")+"]")
+		}
+		if e.Credentials != "" {
+			write("credentials", quote(e.Credentials))
+		}
+	}
+	return buf.Bytes()
+}
+
+// quote puts a value in double quotes if it'd be misread without them
+func quote(value string) string {
+	plain := value != "" && value == strings.TrimSpace(value) &&
+		!strings.ContainsAny(value, ":#[]{},&*!|>'\"%@`\\\n\r\t") && !strings.HasPrefix(value, "-")
+	if plain {
+		return value
+	}
+	return strconv.Quote(value)
+}
@@ -0,0 +1,141 @@
+package dell
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// LampMode is the lamp mode the projector is running in. Eco mode is dimmer but the lamp lasts longer
+type LampMode int
+
+// The lamp modes we know about
+const (
+	LampUnknown LampMode = iota
+	LampNormal
+	LampEco
+)
+
+// String turns a LampMode into something a bit more readable
+func (m LampMode) String() string {
+	switch m {
+	case LampNormal:
+		return "Normal"
+	case LampEco:
+		return "Eco"
+	}
+	return "Unknown"
+}
+
+// MarshalText lets LampMode show up as text in JSON
+func (m LampMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Model holds what we know about a particular projector model (e.g. how long its lamp is rated for, and which
+// commands it doesn't support)
+type Model struct {
+	LampLife    int      // Rated lamp life in hours, in normal mode
+	EcoLampLife int      // Rated lamp life in hours, in eco mode
+	Unsupported []string // Commands (e.g. "Input.SVideo") that this model doesn't have
+}
+
+// ModelList is a JSON object containing capability data for each projector model, keyed by the model name that
+// the projector announces. "Default" is used for any model that isn't listed.
+// It's exported so that you can add your own models if necessary
+var ModelList = []byte(`
+  {
+	"Default": {
+		"LampLife": 3000,
+		"EcoLampLife": 4000
+	},
+	"S300wi": {
+		"LampLife": 3000,
+		"EcoLampLife": 4000
+	},
+	"S500wi": {
+		"LampLife": 3000,
+		"EcoLampLife": 4000
+	}
+}
+`)
+
+// Models is ModelList, unmarshalled. It's populated by Init
+var Models map[string]Model
+
+// LampWarningHours is how many hours of lamp life can be left before a "lampreplacement" event is raised
+var LampWarningHours = 200
+
+// lampWarned keeps track of which projectors we've already warned about, so we only raise the event once per lamp
+var lampWarned = make(map[string]bool)
+
+// loadModels unmarshals ModelList. It's called from Init
+func loadModels() error {
+	Models = make(map[string]Model)
+	return json.Unmarshal(ModelList, &Models)
+}
+
+// ModelInfo returns the capability data for a projector's model, falling back to "Default" if we don't know it
+func ModelInfo(projector Projector) Model {
+	for name, model := range Models {
+		if strings.EqualFold(name, projector.Model) {
+			return model
+		}
+	}
+	return Models["Default"]
+}
+
+// LampRemaining works out how many hours the lamp has left, based on the model's rated lamp life and the current lamp mode.
+// It can go negative if the lamp has outlived its rating
+func LampRemaining(projector Projector) int {
+	model := ModelInfo(projector)
+	life := model.LampLife
+	if projector.LampMode == LampEco && model.EcoLampLife > 0 {
+		life = model.EcoLampLife
+	}
+	return life - projector.LampHours
+}
+
+// ParseLampHours turns the text the projector shows for lamp hours (e.g. "275 Hours") into a number
+func ParseLampHours(text string) (int, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	hours, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, false
+	}
+	return hours, true
+}
+
+// ParseLampMode turns the text the projector shows for the lamp mode (e.g. "Normal Mode") into a LampMode
+func ParseLampMode(text string) LampMode {
+	text = strings.ToLower(text)
+	switch {
+	case strings.HasPrefix(text, "normal"):
+		return LampNormal
+	case strings.HasPrefix(text, "eco"):
+		return LampEco
+	}
+	return LampUnknown
+}
+
+// checkLamp raises a "lampreplacement" event when a projector's remaining lamp life drops below LampWarningHours.
+// If the hours go back up (because someone has put a new lamp in), we'll warn again next time
+func checkLamp(projector Projector) {
+	if projector.LampHours == 0 {
+		return
+	}
+
+	low := LampRemaining(projector) < LampWarningHours
+
+	projectorsLock.Lock()
+	warned := lampWarned[projector.UUID]
+	lampWarned[projector.UUID] = low
+	projectorsLock.Unlock()
+
+	if low && !warned {
+		passMessage("lampreplacement", projector)
+	}
+}
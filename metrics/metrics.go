@@ -0,0 +1,234 @@
+// Package metrics exposes projector health in the Prometheus text format, so it can be scraped and graphed
+// (and alerted on) in something like Grafana.
+//
+//	http.Handle("/metrics", metrics.New())
+//
+// Gauges come from dell.Projectors each time the handler is scraped, and are labelled with each projector's
+// UUID, model, name and location. Counters and the round trip histogram are built up from events as they happen
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// Buckets are the upper bounds (in seconds) of the command round trip histogram
+var Buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Collector keeps track of the counters and histograms, and serves everything up on ServeHTTP
+type Collector struct {
+	lock            sync.Mutex
+	commandsSent    map[string]float64
+	commandFailures map[string]float64
+	reconnects      map[string]float64
+	beacons         map[string]float64
+	seen            map[string]bool // Projectors that have connected before, so we can count reconnects
+	latency         map[string]*histogram
+	stop            func()
+	removeHook      func()
+}
+
+// histogram is a Prometheus histogram for a single projector
+type histogram struct {
+	counts []float64 // One per bucket, not cumulative
+	sum    float64
+	count  float64
+}
+
+// New starts collecting metrics. Round trips come from a hook added with dell.AddRoundTripHook, which Close removes
+func New() *Collector {
+	events, stop := dell.Subscribe()
+	c := &Collector{
+		commandsSent:    make(map[string]float64),
+		commandFailures: make(map[string]float64),
+		reconnects:      make(map[string]float64),
+		beacons:         make(map[string]float64),
+		seen:            make(map[string]bool),
+		latency:         make(map[string]*histogram),
+		stop:            stop,
+	}
+
+	c.removeHook = dell.AddRoundTripHook(func(projector dell.Projector, d time.Duration) {
+		c.observe(projector.UUID, d)
+	})
+
+	go func() {
+		for e := range events {
+			c.count(e)
+		}
+	}()
+
+	return c
+}
+
+// Close stops collecting metrics from events and round trips. What's been collected so far can still be served
+func (c *Collector) Close() {
+	c.stop()
+	c.removeHook()
+}
+
+// count updates the counters for a single event
+func (c *Collector) count(e dell.EventStruct) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	uuid := e.ProjectorInfo.UUID
+	switch e.Name {
+	case "commandsent":
+		c.commandsSent[uuid]++
+	case "commandfailed":
+		c.commandFailures[uuid]++
+	case "beaconseen":
+		c.beacons[uuid]++
+	case "projectoradded":
+		if c.seen[uuid] {
+			c.reconnects[uuid]++
+		}
+		c.seen[uuid] = true
+	}
+}
+
+// observe adds a round trip to a projector's histogram
+func (c *Collector) observe(uuid string, d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	h, ok := c.latency[uuid]
+	if !ok {
+		h = &histogram{counts: make([]float64, len(Buckets))}
+		c.latency[uuid] = h
+	}
+
+	seconds := d.Seconds()
+	for i, bound := range Buckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// ServeHTTP writes every metric in the Prometheus text format
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Write(w)
+}
+
+// Write writes every metric in the Prometheus text format
+func (c *Collector) Write(w io.Writer) {
+	projectors := dell.ListProjectors()
+	sort.Slice(projectors, func(i, j int) bool { return projectors[i].UUID < projectors[j].UUID })
+
+	gauge := func(name string, help string, value func(dell.Projector) float64) {
+		header(w, name, "gauge", help)
+		for _, p := range projectors {
+			fmt.Fprintf(w, "%s{%s} %s\n", name, labels(p), number(value(p)))
+		}
+	}
+
+	gauge("dell_projector_connected", "Whether we're connected to the projector (1) or not (0).", func(p dell.Projector) float64 {
+		return boolean(p.Connected())
+	})
+	gauge("dell_projector_power_state", "Power state: 0 unknown, 1 off, 2 warming up, 3 on, 4 cooling down.", func(p dell.Projector) float64 {
+		return float64(p.PowerState)
+	})
+	gauge("dell_projector_power_on", "Whether the projector is on or warming up (1) or not (0).", func(p dell.Projector) float64 {
+		return boolean(p.PowerState == dell.PowerOn || p.PowerState == dell.PowerWarmingUp)
+	})
+	gauge("dell_projector_lamp_hours", "Hours on the lamp.", func(p dell.Projector) float64 {
+		return float64(p.LampHours)
+	})
+	gauge("dell_projector_lamp_remaining_hours", "Hours of rated lamp life left.", func(p dell.Projector) float64 {
+		return float64(dell.LampRemaining(p))
+	})
+	gauge("dell_projector_volume", "Volume level.", func(p dell.Projector) float64 {
+		return float64(p.Volume)
+	})
+	gauge("dell_projector_volume_muted", "Whether the volume is muted (1) or not (0).", func(p dell.Projector) float64 {
+		return boolean(p.VolumeMuted)
+	})
+
+	header(w, "dell_projector_alarm", "gauge", "Whether an alarm is active (1) or not (0).")
+	for _, p := range projectors {
+		for _, alarm := range []dell.Alarm{dell.AlarmLampFailure, dell.AlarmOverTemperature, dell.AlarmFanFailure,
+			dell.AlarmLampDoorOpen, dell.AlarmLampExpired, dell.AlarmColorWheelFailure} {
+			fmt.Fprintf(w, "dell_projector_alarm{%s,alarm=%s} %s\n", labels(p), quote(alarm.String()), number(boolean(p.Alarms.Has(alarm))))
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	counter := func(name string, help string, values map[string]float64) {
+		header(w, name, "counter", help)
+		for _, uuid := range keys(values) {
+			fmt.Fprintf(w, "%s{uuid=%s} %s\n", name, quote(uuid), number(values[uuid]))
+		}
+	}
+	counter("dell_commands_sent_total", "Commands sent to each projector.", c.commandsSent)
+	counter("dell_command_failures_total", "Commands that couldn't be sent to each projector.", c.commandFailures)
+	counter("dell_reconnects_total", "Times we've reconnected to each projector.", c.reconnects)
+	counter("dell_discovery_beacons_total", "DDDP discovery beacons seen from each projector.", c.beacons)
+
+	header(w, "dell_command_round_trip_seconds", "histogram", "Time between sending something to a projector and its reply, measured at the TCP layer.")
+	uuids := make([]string, 0, len(c.latency))
+	for uuid := range c.latency {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	for _, uuid := range uuids {
+		h := c.latency[uuid]
+		cumulative := 0.0
+		for i, bound := range Buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "dell_command_round_trip_seconds_bucket{uuid=%s,le=%s} %s\n", quote(uuid), quote(number(bound)), number(cumulative))
+		}
+		fmt.Fprintf(w, "dell_command_round_trip_seconds_bucket{uuid=%s,le=\"+Inf\"} %s\n", quote(uuid), number(h.count))
+		fmt.Fprintf(w, "dell_command_round_trip_seconds_sum{uuid=%s} %s\n", quote(uuid), number(h.sum))
+		fmt.Fprintf(w, "dell_command_round_trip_seconds_count{uuid=%s} %s\n", quote(uuid), number(h.count))
+	}
+}
+
+func header(w io.Writer, name string, kind string, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labels builds the labels we put on every projector gauge
+func labels(p dell.Projector) string {
+	return "uuid=" + quote(p.UUID) + ",model=" + quote(p.Model) + ",name=" + quote(p.Name) + ",location=" + quote(p.Location)
+}
+
+// quote quotes a label value, escaping anything the text format needs escaped
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func number(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func keys(m map[string]float64) []string {
+	list := make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
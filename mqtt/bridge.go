@@ -0,0 +1,284 @@
+// Package mqtt bridges dell's projectors onto an MQTT broker, with Home Assistant discovery so projectors show up
+// in Home Assistant automatically.
+//
+//	bridge := mqtt.NewBridge(&mqtt.Client{ClientID: "go-dell"})
+//	err := bridge.Start("localhost:1883")
+//
+// Each projector's decoded state is published (retained) to <prefix>/<uuid>/state as JSON, and commands can be sent by
+// publishing to <prefix>/<uuid>/command (e.g. "Power.On"), <prefix>/<uuid>/power/set ("ON" or "OFF"),
+// <prefix>/<uuid>/input/set (e.g. "HDMI") or <prefix>/<uuid>/mute/set ("ON" or "OFF"). If a command can't be sent,
+// the reason is published (not retained) to <prefix>/<uuid>/error.
+//
+// The package has its own small MQTT client and an in-process Broker, so it doesn't need anything outside the
+// standard library, and the bridge can be tried out (or tested) without a real broker
+package mqtt
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Grayda/go-dell"
+)
+
+// Bridge publishes projector state to MQTT and turns MQTT messages into commands
+type Bridge struct {
+	Client          *Client
+	Prefix          string // The start of every topic we publish to. Defaults to "dell"
+	DiscoveryPrefix string // Home Assistant's discovery prefix. Defaults to "homeassistant". Set to "-" to turn discovery off
+
+	lock  sync.Mutex
+	ids   map[string]string // Topic-safe IDs back to projector UUIDs
+	stop  func()
+	known map[string]bool // Projectors we've already published discovery configs for
+}
+
+// State is what's published to <prefix>/<uuid>/state
+type State struct {
+	Name          string          `json:"name"`
+	Location      string          `json:"location"`
+	Power         dell.PowerState `json:"power"`
+	PowerSwitch   string          `json:"power_switch"` // "ON" while warming up or on, "OFF" otherwise
+	Source        string          `json:"source"`
+	Input         string          `json:"input"` // Source, as one of the names from dell.Commands.Input
+	VolumeMuted   bool            `json:"volume_muted"`
+	PictureMuted  bool            `json:"picture_muted"`
+	LampHours     int             `json:"lamp_hours"`
+	LampMode      dell.LampMode   `json:"lamp_mode"`
+	LampRemaining int             `json:"lamp_remaining"`
+	Alarms        dell.Alarm      `json:"alarms"`
+	Problem       string          `json:"problem"` // "ON" if there are any alarms, for Home Assistant's binary_sensor
+}
+
+// NewBridge creates a bridge that will use client to talk to the broker
+func NewBridge(client *Client) *Bridge {
+	return &Bridge{
+		Client:          client,
+		Prefix:          "dell",
+		DiscoveryPrefix: "homeassistant",
+		ids:             make(map[string]string),
+		known:           make(map[string]bool),
+	}
+}
+
+// Start connects to the broker at addr and starts bridging. The bridge's availability is published to
+// <prefix>/status, and the broker is asked to set it to "offline" if the bridge disappears
+func (b *Bridge) Start(addr string) error {
+	b.Client.Will = &Message{Topic: b.Prefix + "/status", Payload: []byte("offline"), Retain: true}
+	err := b.Client.Connect(addr)
+	if err != nil {
+		return err
+	}
+
+	events, stop := dell.Subscribe()
+	b.stop = stop
+
+	err = b.Client.Publish(Message{Topic: b.Prefix + "/status", Payload: []byte("online"), Retain: true})
+	if err != nil {
+		return err
+	}
+
+	for _, topic := range []string{"command", "power/set", "input/set", "mute/set"} {
+		err = b.Client.Subscribe(b.Prefix+"/+/"+topic, b.handleCommand)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, projector := range dell.ListProjectors() {
+		b.publishProjector(projector)
+	}
+
+	go func() {
+		for e := range events {
+			switch e.Name {
+			case "projectorremoved":
+				b.publish(b.topic(e.ProjectorInfo, "availability"), "offline")
+			case "projectoradded", "propertychanged", "powerstatechanged", "alarmraised", "alarmcleared":
+				if projector, ok := dell.GetProjector(e.ProjectorInfo.UUID); ok {
+					b.publishProjector(projector)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops bridging, marks the bridge offline and disconnects from the broker
+func (b *Bridge) Stop() error {
+	if b.stop != nil {
+		b.stop()
+	}
+	b.publish(b.Prefix+"/status", "offline")
+	return b.Client.Close()
+}
+
+// unsafeID matches anything that can't go in a topic level (or in Home Assistant's IDs)
+var unsafeID = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// id makes a projector's UUID safe to use in a topic, and remembers which projector it belongs to
+func (b *Bridge) id(projector dell.Projector) string {
+	id := unsafeID.ReplaceAllString(projector.UUID, "_")
+	b.lock.Lock()
+	b.ids[id] = projector.UUID
+	b.lock.Unlock()
+	return id
+}
+
+func (b *Bridge) topic(projector dell.Projector, name string) string {
+	return b.Prefix + "/" + b.id(projector) + "/" + name
+}
+
+func (b *Bridge) publish(topic string, payload string) error {
+	return b.Client.Publish(Message{Topic: topic, Payload: []byte(payload), Retain: true})
+}
+
+// publishProjector publishes a projector's state and availability, plus its discovery configs the first time we see it
+func (b *Bridge) publishProjector(projector dell.Projector) {
+	b.lock.Lock()
+	first := !b.known[projector.UUID]
+	b.known[projector.UUID] = true
+	b.lock.Unlock()
+
+	if first && b.DiscoveryPrefix != "-" {
+		b.publishDiscovery(projector)
+	}
+
+	availability := "offline"
+	if projector.Connected() {
+		availability = "online"
+	}
+	b.publish(b.topic(projector, "availability"), availability)
+
+	state := State{
+		Name:          projector.Name,
+		Location:      projector.Location,
+		Power:         projector.PowerState,
+		PowerSwitch:   onOff(projector.PowerState == dell.PowerOn || projector.PowerState == dell.PowerWarmingUp),
+		Source:        projector.Source,
+		Input:         dell.InputForSource(projector.Source),
+		VolumeMuted:   projector.VolumeMuted,
+		PictureMuted:  projector.PictureMuted,
+		LampHours:     projector.LampHours,
+		LampMode:      projector.LampMode,
+		LampRemaining: dell.LampRemaining(projector),
+		Alarms:        projector.Alarms,
+		Problem:       onOff(projector.Alarms != 0),
+	}
+	payload, _ := json.Marshal(state)
+	b.publish(b.topic(projector, "state"), string(payload))
+}
+
+// publishDiscovery publishes Home Assistant discovery configs for a projector: a switch for power, a select for the
+// input, a switch for mute, a sensor for lamp hours and a binary_sensor for alarms.
+// (Home Assistant's MQTT integration has no media_player platform, so power and input are separate entities)
+func (b *Bridge) publishDiscovery(projector dell.Projector) {
+	id := b.id(projector)
+	name := projector.Name
+	if name == "" || name == projector.UUID {
+		name = strings.TrimSpace(projector.Make + " " + projector.Model + " " + projector.UUID)
+	}
+
+	base := func(object string, entityName string) map[string]interface{} {
+		return map[string]interface{}{
+			"name":        entityName,
+			"unique_id":   "dell_" + id + "_" + object,
+			"state_topic": b.topic(projector, "state"),
+			"availability": []map[string]string{
+				{"topic": b.Prefix + "/status"},
+				{"topic": b.topic(projector, "availability")},
+			},
+			"availability_mode": "all",
+			"device": map[string]interface{}{
+				"identifiers":  []string{"dell_" + id},
+				"manufacturer": projector.Make,
+				"model":        projector.Model,
+				"name":         name,
+			},
+		}
+	}
+
+	configs := map[string]map[string]interface{}{}
+
+	power := base("power", "Power")
+	power["command_topic"] = b.topic(projector, "power/set")
+	power["value_template"] = "{{ value_json.power_switch }}"
+	power["icon"] = "mdi:projector"
+	configs["switch/dell_"+id+"/power"] = power
+
+	input := base("input", "Input")
+	input["command_topic"] = b.topic(projector, "input/set")
+	input["value_template"] = "{{ value_json.input }}"
+	input["options"] = dell.InputNames()
+	configs["select/dell_"+id+"/input"] = input
+
+	mute := base("mute", "Mute")
+	mute["command_topic"] = b.topic(projector, "mute/set")
+	mute["value_template"] = "{{ 'ON' if value_json.volume_muted else 'OFF' }}"
+	mute["icon"] = "mdi:volume-off"
+	configs["switch/dell_"+id+"/mute"] = mute
+
+	lamp := base("lamp_hours", "Lamp hours")
+	lamp["value_template"] = "{{ value_json.lamp_hours }}"
+	lamp["unit_of_measurement"] = "h"
+	lamp["state_class"] = "total_increasing"
+	lamp["icon"] = "mdi:lightbulb"
+	configs["sensor/dell_"+id+"/lamp_hours"] = lamp
+
+	problem := base("problem", "Problem")
+	problem["value_template"] = "{{ value_json.problem }}"
+	problem["device_class"] = "problem"
+	problem["json_attributes_topic"] = b.topic(projector, "state")
+	problem["json_attributes_template"] = "{{ {'alarms': value_json.alarms} | tojson }}"
+	configs["binary_sensor/dell_"+id+"/problem"] = problem
+
+	for path, config := range configs {
+		payload, _ := json.Marshal(config)
+		b.publish(b.DiscoveryPrefix+"/"+path+"/config", string(payload))
+	}
+}
+
+// handleCommand turns a message on one of our command topics into a command
+func (b *Bridge) handleCommand(m Message) {
+	parts := strings.SplitN(strings.TrimPrefix(m.Topic, b.Prefix+"/"), "/", 2)
+	if len(parts) != 2 {
+		return
+	}
+
+	b.lock.Lock()
+	uuid, ok := b.ids[parts[0]]
+	b.lock.Unlock()
+	if !ok {
+		uuid = parts[0]
+	}
+	projector, ok := dell.GetProjector(uuid)
+	if !ok {
+		b.Client.Publish(Message{Topic: b.Prefix + "/" + parts[0] + "/error", Payload: []byte(parts[1] + ": " + dell.ErrOffline.Error())})
+		return
+	}
+
+	payload := strings.TrimSpace(string(m.Payload))
+	var err error
+	switch parts[1] {
+	case "command":
+		_, err = dell.SendNamedCommand(projector, payload)
+	case "power/set":
+		_, err = dell.SetPower(projector, strings.EqualFold(payload, "ON"))
+	case "input/set":
+		_, err = dell.SetInput(projector, payload)
+	case "mute/set":
+		_, err = dell.SetMute(projector, strings.EqualFold(payload, "ON"))
+	}
+	if err != nil {
+		b.Client.Publish(Message{Topic: b.topic(projector, "error"), Payload: []byte(parts[1] + " " + payload + ": " + err.Error())})
+	}
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
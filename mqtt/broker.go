@@ -0,0 +1,266 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Broker is a tiny in-process MQTT broker. It does QoS 0, retained messages, wildcards and wills, which is
+// enough to run the bridge without a real broker (e.g. in tests, or to try it out)
+type Broker struct {
+	lock     sync.Mutex
+	listener net.Listener
+	clients  map[*brokerClient]bool
+	retained map[string]Message
+}
+
+// brokerClient is a single client connected to the broker
+type brokerClient struct {
+	conn    net.Conn
+	lock    sync.Mutex // Guards writes to conn
+	filters []string
+	will    *Message
+}
+
+// NewBroker creates a broker. Call Listen to start accepting connections
+func NewBroker() *Broker {
+	return &Broker{
+		clients:  make(map[*brokerClient]bool),
+		retained: make(map[string]Message),
+	}
+}
+
+// Listen starts accepting connections on addr. Use "127.0.0.1:0" to pick any free port, then Addr to find out which
+func (b *Broker) Listen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	b.listener = l
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return nil
+}
+
+// Addr is the address the broker is listening on
+func (b *Broker) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// Close stops the broker and disconnects every client
+func (b *Broker) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for c := range b.clients {
+		c.conn.Close()
+	}
+	return b.listener.Close()
+}
+
+// Publish publishes a message to every matching subscriber, as if a client had sent it
+func (b *Broker) Publish(m Message) {
+	b.lock.Lock()
+	if m.Retain {
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m
+		}
+	}
+	var subscribers []*brokerClient
+	for c := range b.clients {
+		for _, filter := range c.filters {
+			if Match(filter, m.Topic) {
+				subscribers = append(subscribers, c)
+				break
+			}
+		}
+	}
+	b.lock.Unlock()
+
+	// Retain is only set on messages sent to new subscribers, not live ones
+	live := Message{Topic: m.Topic, Payload: m.Payload}
+	for _, c := range subscribers {
+		c.write(publishPacket(live))
+	}
+}
+
+// Retained returns the retained message for a topic, if there is one
+func (b *Broker) Retained(topic string) (Message, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	m, ok := b.retained[topic]
+	return m, ok
+}
+
+func (c *brokerClient) write(p packet) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.conn.Write(p.encode())
+	return err
+}
+
+// serve talks to a single client until it goes away
+func (b *Broker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	c := &brokerClient{conn: conn}
+
+	p, err := readPacket(r)
+	if err != nil || p.Type != packetConnect {
+		return
+	}
+	c.will, err = parseConnect(p)
+	if err != nil {
+		return
+	}
+	if c.write(packet{Type: packetConnack, Body: []byte{0, 0}}) != nil {
+		return
+	}
+
+	b.lock.Lock()
+	b.clients[c] = true
+	b.lock.Unlock()
+
+	cleanly := false
+	defer func() {
+		b.lock.Lock()
+		delete(b.clients, c)
+		b.lock.Unlock()
+		if !cleanly && c.will != nil {
+			b.Publish(*c.will)
+		}
+	}()
+
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch p.Type {
+		case packetPublish:
+			m, id, err := parsePublish(p)
+			if err != nil {
+				return
+			}
+			if id != 0 {
+				c.write(packet{Type: packetPuback, Body: binary.BigEndian.AppendUint16(nil, id)})
+			}
+			b.Publish(m)
+		case packetSubscribe:
+			b.subscribe(c, p)
+		case packetUnsubscribe:
+			b.unsubscribe(c, p)
+		case packetPingreq:
+			c.write(packet{Type: packetPingresp})
+		case packetDisconnect:
+			cleanly = true
+			return
+		}
+	}
+}
+
+// subscribe handles a SUBSCRIBE packet, then sends the client any retained messages that match
+func (b *Broker) subscribe(c *brokerClient, p packet) {
+	if len(p.Body) < 2 {
+		return
+	}
+	ack := append([]byte(nil), p.Body[:2]...) // Packet ID
+	rest := p.Body[2:]
+
+	var filters []string
+	for len(rest) > 0 {
+		filter, after, err := readString(rest)
+		if err != nil || len(after) < 1 {
+			return
+		}
+		filters = append(filters, filter)
+		ack = append(ack, 0) // Everything is granted at QoS 0
+		rest = after[1:]
+	}
+
+	b.lock.Lock()
+	c.filters = append(c.filters, filters...)
+	var retained []Message
+	for topic, m := range b.retained {
+		for _, filter := range filters {
+			if Match(filter, topic) {
+				retained = append(retained, m)
+				break
+			}
+		}
+	}
+	b.lock.Unlock()
+
+	c.write(packet{Type: packetSuback, Body: ack})
+	for _, m := range retained {
+		c.write(publishPacket(m))
+	}
+}
+
+// unsubscribe handles an UNSUBSCRIBE packet
+func (b *Broker) unsubscribe(c *brokerClient, p packet) {
+	if len(p.Body) < 2 {
+		return
+	}
+	ack := p.Body[:2]
+	rest := p.Body[2:]
+
+	b.lock.Lock()
+	for len(rest) > 0 {
+		filter, after, err := readString(rest)
+		if err != nil {
+			break
+		}
+		for i, f := range c.filters {
+			if f == filter {
+				c.filters = append(c.filters[:i], c.filters[i+1:]...)
+				break
+			}
+		}
+		rest = after
+	}
+	b.lock.Unlock()
+
+	c.write(packet{Type: packetUnsuback, Body: ack})
+}
+
+// parseConnect reads a CONNECT packet and returns the client's will, if it has one
+func parseConnect(p packet) (*Message, error) {
+	_, rest, err := readString(p.Body) // Protocol name
+	if err != nil || len(rest) < 4 {
+		return nil, errors.New("mqtt: bad CONNECT")
+	}
+	flags := rest[1]
+	rest = rest[4:] // Protocol level, flags and keep alive
+
+	_, rest, err = readString(rest) // Client ID
+	if err != nil {
+		return nil, err
+	}
+
+	if flags&0x04 == 0 {
+		return nil, nil
+	}
+	topic, rest, err := readString(rest)
+	if err != nil {
+		return nil, err
+	}
+	payload, _, err := readString(rest)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Topic: topic, Payload: []byte(payload), Retain: flags&0x20 != 0}, nil
+}
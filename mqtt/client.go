@@ -0,0 +1,194 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Client is a small MQTT 3.1.1 client. It only does QoS 0, which is all we need for publishing state and
+// receiving commands. Fill in the fields you need, then call Connect
+type Client struct {
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration // How often to ping the broker. Defaults to 30 seconds
+	Will      *Message      // Published by the broker if we disappear without saying goodbye
+
+	conn     net.Conn
+	lock     sync.Mutex // Guards writes to conn, and handlers
+	handlers []handler
+	nextID   uint16
+	done     chan bool
+}
+
+// handler is a subscription filter and the function to call when a matching message arrives
+type handler struct {
+	filter string
+	fn     func(Message)
+}
+
+// Connect connects to the broker at addr (e.g. "localhost:1883")
+func (c *Client) Connect(addr string) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	if c.KeepAlive == 0 {
+		c.KeepAlive = 30 * time.Second
+	}
+
+	var flags byte = 0x02 // Clean session
+	body := appendString(nil, "MQTT")
+	body = append(body, 4) // Protocol level 4 is MQTT 3.1.1
+	if c.Will != nil {
+		flags |= 0x04
+		if c.Will.Retain {
+			flags |= 0x20
+		}
+	}
+	if c.Username != "" {
+		flags |= 0x80
+	}
+	if c.Password != "" {
+		flags |= 0x40
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.KeepAlive/time.Second))
+	body = appendString(body, c.ClientID)
+	if c.Will != nil {
+		body = appendString(body, c.Will.Topic)
+		body = appendString(body, string(c.Will.Payload))
+	}
+	if c.Username != "" {
+		body = appendString(body, c.Username)
+	}
+	if c.Password != "" {
+		body = appendString(body, c.Password)
+	}
+
+	_, err = conn.Write(packet{Type: packetConnect, Body: body}.encode())
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	ack, err := readPacket(r)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	conn.SetReadDeadline(time.Time{})
+	if ack.Type != packetConnack || len(ack.Body) < 2 {
+		conn.Close()
+		return errors.New("mqtt: expected CONNACK")
+	}
+	if ack.Body[1] != 0 {
+		conn.Close()
+		return fmt.Errorf("mqtt: connection refused (code %d)", ack.Body[1])
+	}
+
+	c.conn = conn
+	c.done = make(chan bool)
+	go c.readLoop(r)
+	go c.pingLoop()
+	return nil
+}
+
+// Done is closed when the connection to the broker is lost (or closed)
+func (c *Client) Done() chan bool {
+	return c.done
+}
+
+// Publish publishes a message
+func (c *Client) Publish(m Message) error {
+	return c.write(publishPacket(m))
+}
+
+// Subscribe subscribes to a topic filter, and calls fn for every message that matches it.
+// fn is called from the client's read loop, so it shouldn't block for long
+func (c *Client) Subscribe(filter string, fn func(Message)) error {
+	c.lock.Lock()
+	c.handlers = append(c.handlers, handler{filter, fn})
+	c.nextID++
+	id := c.nextID
+	c.lock.Unlock()
+
+	body := binary.BigEndian.AppendUint16(nil, id)
+	body = appendString(body, filter)
+	body = append(body, 0) // QoS 0
+	return c.write(packet{Type: packetSubscribe, Flags: 0x02, Body: body})
+}
+
+// Close says goodbye to the broker (so the will isn't published) and disconnects
+func (c *Client) Close() error {
+	c.write(packet{Type: packetDisconnect})
+	return c.conn.Close()
+}
+
+func (c *Client) write(p packet) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		return errors.New("mqtt: not connected")
+	}
+	_, err := c.conn.Write(p.encode())
+	return err
+}
+
+// readLoop reads packets from the broker and hands PUBLISHes to the matching handlers
+func (c *Client) readLoop(r *bufio.Reader) {
+	defer close(c.done)
+	defer c.conn.Close()
+
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		if p.Type != packetPublish {
+			continue
+		}
+
+		m, id, err := parsePublish(p)
+		if err != nil {
+			return
+		}
+		if id != 0 {
+			// The broker sent it at QoS 1, so it wants to hear that we got it
+			c.write(packet{Type: packetPuback, Body: binary.BigEndian.AppendUint16(nil, id)})
+		}
+
+		c.lock.Lock()
+		handlers := append([]handler(nil), c.handlers...)
+		c.lock.Unlock()
+		for _, h := range handlers {
+			if Match(h.filter, m.Topic) {
+				h.fn(m)
+			}
+		}
+	}
+}
+
+// pingLoop pings the broker so it knows we're still here
+func (c *Client) pingLoop() {
+	ticker := time.NewTicker(c.KeepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if c.write(packet{Type: packetPingreq}) != nil {
+				return
+			}
+		}
+	}
+}
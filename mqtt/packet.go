@@ -0,0 +1,151 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// MQTT 3.1.1 packet types. Only the ones we need are here
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetUnsubscribe = 10
+	packetUnsuback    = 11
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+)
+
+// packet is a single MQTT control packet: the type and flags from the first byte, then everything else
+type packet struct {
+	Type  byte
+	Flags byte
+	Body  []byte
+}
+
+// readPacket reads a single packet
+func readPacket(r *bufio.Reader) (packet, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+
+	// The remaining length is a variable length integer, 7 bits at a time
+	length, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+		if shift > 21 {
+			return packet{}, errors.New("mqtt: malformed remaining length")
+		}
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return packet{}, err
+	}
+
+	return packet{Type: first >> 4, Flags: first & 0x0f, Body: body}, nil
+}
+
+// encode turns a packet back into bytes
+func (p packet) encode() []byte {
+	out := []byte{p.Type<<4 | p.Flags}
+	length := len(p.Body)
+	for {
+		b := byte(length & 0x7f)
+		length >>= 7
+		if length > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(out, p.Body...)
+}
+
+// appendString adds a length-prefixed string, which is how MQTT sends topics, client IDs and so on
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// readString reads a length-prefixed string, and returns whatever comes after it
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("mqtt: packet too short")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("mqtt: packet too short")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// Message is a single published message
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// publishPacket builds a QoS 0 PUBLISH packet
+func publishPacket(m Message) packet {
+	var flags byte
+	if m.Retain {
+		flags = 1
+	}
+	return packet{Type: packetPublish, Flags: flags, Body: append(appendString(nil, m.Topic), m.Payload...)}
+}
+
+// parsePublish reads a PUBLISH packet. For QoS 1 and 2 the packet ID is returned too, so it can be acknowledged
+func parsePublish(p packet) (Message, uint16, error) {
+	topic, rest, err := readString(p.Body)
+	if err != nil {
+		return Message{}, 0, err
+	}
+
+	var id uint16
+	if qos := (p.Flags >> 1) & 3; qos > 0 {
+		if len(rest) < 2 {
+			return Message{}, 0, errors.New("mqtt: packet too short")
+		}
+		id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+
+	return Message{Topic: topic, Payload: rest, Retain: p.Flags&1 != 0}, id, nil
+}
+
+// Match tells us whether a topic matches a subscription filter, which can use the + (one level) and # (everything
+// from here down) wildcards
+func Match(filter string, topic string) bool {
+	filters, topics := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, f := range filters {
+		if f == "#" {
+			// "a/#" also matches "a" itself
+			return true
+		}
+		if i >= len(topics) || (f != "+" && f != topics[i]) {
+			return false
+		}
+	}
+	return len(filters) == len(topics)
+}
@@ -0,0 +1,328 @@
+package pjlink
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// Protocol is the name the PJLink driver is registered under. Set a projector's Protocol to this (and its Password,
+// if it has one) before passing it to dell.AddProjector
+const Protocol = "pjlink"
+
+// PollInterval is how often the driver asks PJLink projectors for their status. PJLink projectors hang up after
+// 30 seconds of quiet, so it should be less than that
+var PollInterval = 10 * time.Second
+
+// Timeout is how long we wait for a PJLink projector to answer
+var Timeout = 5 * time.Second
+
+// ErrAuthFailed is returned when a PJLink projector doesn't accept our password (or wants one and we don't have one)
+var ErrAuthFailed = errors.New("pjlink: authentication failed")
+
+// ReplyError is an error sent back by a PJLink projector, e.g. "ERR3"
+type ReplyError string
+
+func (e ReplyError) Error() string {
+	switch string(e) {
+	case ErrUndefined:
+		return "pjlink: command not supported"
+	case ErrParameter:
+		return "pjlink: bad parameter"
+	case ErrUnavailable:
+		return "pjlink: projector can't do that right now"
+	case ErrFailure:
+		return "pjlink: projector failure"
+	}
+	return "pjlink: " + string(e)
+}
+
+func init() {
+	dell.RegisterDriver(Protocol, func() dell.Driver { return &Client{} })
+}
+
+// Client is a dell.Driver for PJLink Class 1 and Class 2 projectors. It can also be used on its own, without adding the
+// projector to dell.Projectors, by calling Connect and then Command
+type Client struct {
+	Class int // 1 or 2, worked out by Connect
+
+	conn   net.Conn
+	r      *bufio.Reader
+	lock   sync.Mutex // Only one command can be waiting for an answer at a time
+	auth   string     // The digest to put in front of the next command, if the projector wants a password
+	uuid   string
+	polled bool
+}
+
+// Connect connects to a PJLink projector, and asks it which class of PJLink it speaks
+func (c *Client) Connect(projector dell.Projector) (net.Conn, error) {
+	port := projector.Port
+	if port == 0 {
+		port = Port
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(projector.IP, strconv.Itoa(port)), Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+	c.uuid = projector.UUID
+
+	// The projector starts by telling us whether it wants a password: "PJLINK 0" if it doesn't, and
+	// "PJLINK 1 <random number>" if it does
+	conn.SetReadDeadline(time.Now().Add(Timeout))
+	greeting, err := c.r.ReadString('\r')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	fields := strings.Fields(greeting)
+	switch {
+	case len(fields) >= 2 && fields[0] == "PJLINK" && fields[1] == "0":
+	case len(fields) >= 3 && fields[0] == "PJLINK" && fields[1] == "1":
+		if projector.Password == "" {
+			conn.Close()
+			return nil, ErrAuthFailed
+		}
+		c.auth = digest(fields[2], projector.Password)
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("pjlink: unexpected greeting %q", strings.TrimSpace(greeting))
+	}
+
+	c.Class = 1
+	class, err := c.Command(1, "CLSS", "?")
+	var reply ReplyError
+	if err != nil && !errors.As(err, &reply) {
+		conn.Close()
+		return nil, err
+	}
+	if class == "2" {
+		c.Class = 2
+	}
+	return conn, nil
+}
+
+// Command sends a command (e.g. "POWR") of a PJLink class with a parameter (e.g. "1", or "?" to ask about something)
+// and returns the projector's answer. Error answers are returned as a ReplyError
+func (c *Client) Command(class int, command string, param string) (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		return "", dell.ErrOffline
+	}
+
+	header := fmt.Sprintf("%%%d%s", class, command)
+	c.conn.SetDeadline(time.Now().Add(Timeout))
+	_, err := fmt.Fprint(c.conn, c.auth+header+" "+param+"\r")
+	if err != nil {
+		return "", err
+	}
+	c.auth = "" // Only the first command needs it
+
+	for {
+		line, err := c.r.ReadString('\r')
+		if err != nil {
+			return "", err
+		}
+		line = strings.Trim(line, "\r\n")
+		if line == "PJLINK "+ErrAuth {
+			return "", ErrAuthFailed
+		}
+		// Class 2 projectors can send other things our way, so skip anything that isn't our answer
+		if !strings.HasPrefix(line, header+"=") {
+			continue
+		}
+		value := strings.TrimPrefix(line, header+"=")
+		if len(value) == 4 && strings.HasPrefix(value, "ERR") {
+			return "", ReplyError(value)
+		}
+		return value, nil
+	}
+}
+
+// Read polls the projector for its status every PollInterval, which also stops the projector from hanging up on us
+func (c *Client) Read(projector dell.Projector) error {
+	if c.polled {
+		time.Sleep(PollInterval)
+	}
+	c.polled = true
+	return c.Status()
+}
+
+func (c *Client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
+
+func (c *Client) PowerOn() error {
+	return c.set(1, "POWR", "1")
+}
+
+func (c *Client) PowerOff() error {
+	return c.set(1, "POWR", "0")
+}
+
+func (c *Client) SetInput(input string) error {
+	code, ok := inputCode(input)
+	if !ok {
+		return dell.ErrUnsupported
+	}
+	return c.set(c.Class, "INPT", code)
+}
+
+func (c *Client) Mute(muted bool) error {
+	if muted {
+		return c.set(1, "AVMT", "21")
+	}
+	return c.set(1, "AVMT", "20")
+}
+
+// Send handles the few other commands PJLink has an equivalent for: picture mute, and (for Class 2) freeze and volume
+func (c *Client) Send(command string) error {
+	switch command {
+	case dell.Commands.Picture.Mute:
+		return c.set(1, "AVMT", "11")
+	case dell.Commands.Picture.Unmute:
+		return c.set(1, "AVMT", "10")
+	}
+
+	if c.Class >= 2 {
+		switch command {
+		case dell.Commands.Picture.Freeze:
+			return c.set(2, "FREZ", "1")
+		case dell.Commands.Picture.Unfreeze:
+			return c.set(2, "FREZ", "0")
+		case dell.Commands.Volume.Up:
+			return c.set(2, "SVOL", "1")
+		case dell.Commands.Volume.Down:
+			return c.set(2, "SVOL", "0")
+		}
+	}
+	return dell.ErrUnsupported
+}
+
+// set sends a command that should be answered with "OK"
+func (c *Client) set(class int, command string, param string) error {
+	answer, err := c.Command(class, command, param)
+	if err != nil {
+		return err
+	}
+	if answer != "OK" {
+		return fmt.Errorf("pjlink: unexpected answer %q to %s", answer, command)
+	}
+	return nil
+}
+
+// Status asks the projector about everything PJLink knows about and passes it on to dell.Projectors. Answers like
+// ERR3 (which a projector gives when asked about its input while it's off) are skipped
+func (c *Client) Status() error {
+	answers := make(map[string]string)
+	queries := []string{"POWR", "INPT", "AVMT", "ERST", "LAMP", "NAME", "INF1", "INF2", "INFO"}
+	if c.Class >= 2 {
+		queries = append(queries, "FREZ", "IRES")
+	}
+
+	for _, query := range queries {
+		class := 1
+		if query == "FREZ" || query == "IRES" || (query == "INPT" && c.Class >= 2) {
+			class = 2
+		}
+		answer, err := c.Command(class, query, "?")
+		var reply ReplyError
+		if errors.As(err, &reply) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		answers[query] = answer
+	}
+
+	dell.UpdateProjector(c.uuid, func(projector *dell.Projector) {
+		if input, ok := answers["INPT"]; ok {
+			projector.Source = input
+			if name, ok := Inputs[input]; ok {
+				projector.Source = name
+			}
+		}
+		if mute, ok := answers["AVMT"]; ok && len(mute) == 2 {
+			on := mute[1] == '1'
+			projector.PictureMuted = on && (mute[0] == '1' || mute[0] == '3')
+			projector.VolumeMuted = on && (mute[0] == '2' || mute[0] == '3')
+		}
+		if status, ok := answers["ERST"]; ok && len(status) == 6 {
+			projector.Alarms = alarms(status)
+		}
+		if lamp, ok := answers["LAMP"]; ok {
+			// Projectors with more than one lamp list them all. We only keep track of the first
+			fields := strings.Fields(lamp)
+			if len(fields) > 0 {
+				if hours, err := strconv.Atoi(fields[0]); err == nil {
+					projector.LampHours = hours
+				}
+			}
+		}
+		if name, ok := answers["NAME"]; ok && name != "" {
+			projector.Name = name
+		}
+		if manufacturer, ok := answers["INF1"]; ok && manufacturer != "" {
+			projector.Make = manufacturer
+		}
+		if model, ok := answers["INF2"]; ok && model != "" {
+			projector.Model = model
+		}
+		if info, ok := answers["INFO"]; ok && info != "" {
+			projector.Revision = info
+		}
+		if freeze, ok := answers["FREZ"]; ok {
+			projector.Frozen = freeze == "1"
+		}
+		if resolution, ok := answers["IRES"]; ok {
+			// "-" means there's no signal, so there's no resolution either (see dell.HasSignal)
+			if resolution == "-" || resolution == "*" {
+				resolution = ""
+			}
+			projector.Resolution = resolution
+		}
+	})
+
+	switch answers["POWR"] {
+	case "0":
+		dell.ReportPowerState(c.uuid, dell.PowerOff)
+	case "1":
+		dell.ReportPowerState(c.uuid, dell.PowerOn)
+	case "2":
+		dell.ReportPowerState(c.uuid, dell.PowerCoolingDown)
+	case "3":
+		dell.ReportPowerState(c.uuid, dell.PowerWarmingUp)
+	}
+	return nil
+}
+
+// alarms turns the answer to ERST into alarms. It's the opposite of errorStatus, although PJLink's "other" error
+// doesn't have an alarm of its own so it's left out
+func alarms(status string) dell.Alarm {
+	var a dell.Alarm
+	set := func(i int, alarm dell.Alarm) {
+		if status[i] == '2' {
+			a |= alarm
+		}
+	}
+	set(0, dell.AlarmFanFailure)
+	set(1, dell.AlarmLampFailure)
+	set(2, dell.AlarmOverTemperature)
+	set(3, dell.AlarmLampDoorOpen)
+	return a
+}
@@ -0,0 +1,77 @@
+// Package pjlink lets PJLink software talk to dell's projectors, and lets dell talk to PJLink projectors.
+//
+// Lots of AV control software (and third party touch panels) only speaks PJLink, which the Dell projectors don't,
+// so Gateway pretends to be a PJLink Class 1 projector for each projector in dell.Projectors and turns PJLink
+// commands into dell commands.
+//
+//	gateway := pjlink.NewGateway()
+//	gateway.Password = "secret"
+//	err := gateway.Start()
+//
+// Going the other way, Client is a dell.Driver for PJLink Class 1 and 2 projectors. Importing this package registers
+// it, so PJLink projectors can be added like any other:
+//
+//	dell.AddProjector(dell.Projector{UUID: "room-4", IP: "10.0.0.40", Protocol: pjlink.Protocol, Password: "secret"})
+//
+// PJLink is a simple line based protocol on TCP port 4352. Commands look like "%1POWR 1\r" and replies like
+// "%1POWR=OK\r". See https://pjlink.jbmia.or.jp/english/ for the full specification
+package pjlink
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"sort"
+)
+
+// Port is the TCP port PJLink uses
+const Port = 4352
+
+// The error replies PJLink defines
+const (
+	ErrUndefined   = "ERR1" // Unknown command
+	ErrParameter   = "ERR2" // Bad parameter
+	ErrUnavailable = "ERR3" // The projector can't do that right now (e.g. it's cooling down)
+	ErrFailure     = "ERR4" // Something's wrong with the projector (or we can't reach it)
+	ErrAuth        = "ERRA" // Wrong password
+)
+
+// Inputs maps PJLink input codes to the names in dell.Commands.Input. PJLink codes are two digits: the type of input
+// (1 for RGB, 2 for video, 3 for digital, 4 for storage and 5 for network) and then which one of that type it is.
+// It's exported so that you can change it if necessary
+var Inputs = map[string]string{
+	"11": "VGAA",
+	"12": "VGAB",
+	"21": "Composite",
+	"22": "SVideo",
+	"31": "HDMI",
+	"32": "USBDisplay",
+	"41": "USBViewer",
+	"51": "Wireless",
+}
+
+// inputCode finds the PJLink code for one of the names in dell.Commands.Input
+func inputCode(name string) (string, bool) {
+	for code, input := range Inputs {
+		if input == name {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// inputCodes lists the PJLink input codes in order
+func inputCodes() []string {
+	var codes []string
+	for code := range Inputs {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// digest works out what goes in front of the first command when a password is set: the MD5 of the random
+// number the projector sent us, followed by the password
+func digest(random string, password string) string {
+	sum := md5.Sum([]byte(random + password))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,379 @@
+package pjlink
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// IdleTimeout is how long a PJLink client can go without sending anything before we hang up. The specification says 30 seconds
+var IdleTimeout = 30 * time.Second
+
+// Server answers PJLink commands for a single projector
+type Server struct {
+	UUID     string // The projector in dell.Projectors that we're pretending to be
+	Password string // If it's set, clients need to authenticate with it
+
+	listener net.Listener
+}
+
+// Listen starts accepting PJLink connections on addr (e.g. ":4352"). Use "127.0.0.1:0" to pick any free port,
+// then Addr to find out which
+func (s *Server) Listen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return nil
+}
+
+// Addr is the address the server is listening on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops accepting connections
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// serve talks to a single PJLink client until it hangs up (or goes quiet for IdleTimeout)
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	authenticated := s.Password == ""
+	random := ""
+	if authenticated {
+		fmt.Fprint(conn, "PJLINK 0\r")
+	} else {
+		buf := make([]byte, 4)
+		rand.Read(buf)
+		random = hex.EncodeToString(buf)
+		fmt.Fprint(conn, "PJLINK 1 "+random+"\r")
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(IdleTimeout))
+		line, err := r.ReadString('\r')
+		if err != nil {
+			return
+		}
+		line = strings.Trim(line, "\r\n")
+
+		// Only the first command needs the digest in front of it
+		if !authenticated {
+			if len(line) < 32 || line[:32] != digest(random, s.Password) {
+				fmt.Fprint(conn, "PJLINK "+ErrAuth+"\r")
+				return
+			}
+			line = line[32:]
+			authenticated = true
+		}
+
+		reply := s.handle(line)
+		if reply == "" {
+			continue
+		}
+		_, err = fmt.Fprint(conn, reply+"\r")
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handle works out the reply to a single command, e.g. "%1POWR ?"
+func (s *Server) handle(line string) string {
+	if len(line) < 6 || line[0] != '%' {
+		return "" // Not a command, so there's nothing sensible to say back
+	}
+	header := line[:6]
+	command := line[2:6]
+	param := ""
+	if len(line) > 7 {
+		param = strings.TrimSpace(line[7:])
+	}
+	reply := func(value string) string {
+		return header + "=" + value
+	}
+
+	if line[1] != '1' {
+		return reply(ErrUndefined)
+	}
+
+	projector, ok := dell.GetProjector(s.UUID)
+	if !ok {
+		return reply(ErrFailure)
+	}
+
+	// query handles the commands that can only be asked about, not set
+	query := func(value string) string {
+		if param != "?" {
+			return reply(ErrParameter)
+		}
+		return reply(value)
+	}
+
+	switch command {
+	case "POWR":
+		switch param {
+		case "?":
+			if !projector.Connected() {
+				return reply(ErrFailure)
+			}
+			switch projector.PowerState {
+			case dell.PowerOff:
+				return reply("0")
+			case dell.PowerOn:
+				return reply("1")
+			case dell.PowerCoolingDown:
+				return reply("2")
+			case dell.PowerWarmingUp:
+				return reply("3")
+			}
+			return reply(ErrUnavailable)
+		case "0", "1":
+			_, err := dell.SetPower(projector, param == "1")
+			return reply(result(err))
+		}
+		return reply(ErrParameter)
+
+	case "INPT":
+		if param == "?" {
+			code, ok := inputCode(dell.InputForSource(projector.Source))
+			if !ok || !poweredOn(projector) {
+				return reply(ErrUnavailable)
+			}
+			return reply(code)
+		}
+		input, ok := Inputs[param]
+		if !ok || !dell.Supports(projector, "Input."+input) {
+			return reply(ErrParameter)
+		}
+		if !poweredOn(projector) {
+			return reply(ErrUnavailable)
+		}
+		_, err := dell.SetInput(projector, input)
+		return reply(result(err))
+
+	case "AVMT":
+		if param == "?" {
+			switch {
+			case projector.PictureMuted && projector.VolumeMuted:
+				return reply("31")
+			case projector.PictureMuted:
+				return reply("11")
+			case projector.VolumeMuted:
+				return reply("21")
+			}
+			return reply("30")
+		}
+		if len(param) != 2 || (param[1] != '0' && param[1] != '1') || param[0] < '1' || param[0] > '3' {
+			return reply(ErrParameter)
+		}
+		if !poweredOn(projector) {
+			return reply(ErrUnavailable)
+		}
+		muted := param[1] == '1'
+		var err error
+		if param[0] == '1' || param[0] == '3' {
+			_, err = dell.SetPictureMute(projector, muted)
+		}
+		if err == nil && (param[0] == '2' || param[0] == '3') {
+			_, err = dell.SetMute(projector, muted)
+		}
+		return reply(result(err))
+
+	case "ERST":
+		return query(errorStatus(projector))
+
+	case "LAMP":
+		on := 0
+		if poweredOn(projector) {
+			on = 1
+		}
+		return query(fmt.Sprintf("%d %d", projector.LampHours, on))
+
+	case "INST":
+		var codes []string
+		for _, code := range inputCodes() {
+			if _, ok := dell.LookupCommand("Input." + Inputs[code]); ok && dell.Supports(projector, "Input."+Inputs[code]) {
+				codes = append(codes, code)
+			}
+		}
+		return query(strings.Join(codes, " "))
+
+	case "NAME":
+		return query(projector.Name)
+
+	case "INF1":
+		return query(projector.Make)
+
+	case "INF2":
+		return query(projector.Model)
+
+	case "INFO":
+		if projector.Revision == "" {
+			return query("")
+		}
+		return query("Revision " + projector.Revision)
+
+	case "CLSS":
+		return query("1")
+	}
+
+	return reply(ErrUndefined)
+}
+
+// poweredOn tells us whether a projector is on (or on its way), which is when PJLink says inputs and muting can be changed
+func poweredOn(projector dell.Projector) bool {
+	return projector.PowerState == dell.PowerOn || projector.PowerState == dell.PowerWarmingUp || projector.PowerState == dell.PowerUnknown
+}
+
+// result turns an error from one of dell's Set functions into a PJLink reply
+func result(err error) string {
+	switch {
+	case err == nil:
+		return "OK"
+	case errors.Is(err, dell.ErrCoolingDown):
+		return ErrUnavailable
+	case errors.Is(err, dell.ErrUnsupported), errors.Is(err, dell.ErrUnknownCommand):
+		return ErrParameter
+	}
+	return ErrFailure
+}
+
+// errorStatus builds the reply to ERST: six digits for the fan, lamp, temperature, cover, filter and anything else,
+// where 0 means OK, 1 means a warning and 2 means an error
+func errorStatus(projector dell.Projector) string {
+	status := []byte("000000")
+	set := func(i int, alarm dell.Alarm) {
+		if projector.Alarms.Has(alarm) {
+			status[i] = '2'
+		}
+	}
+
+	if projector.LampHours > 0 && dell.LampRemaining(projector) <= dell.LampWarningHours {
+		status[1] = '1'
+	}
+	set(0, dell.AlarmFanFailure)
+	set(1, dell.AlarmLampFailure)
+	set(1, dell.AlarmLampExpired)
+	set(2, dell.AlarmOverTemperature)
+	set(3, dell.AlarmLampDoorOpen)
+	set(5, dell.AlarmColorWheelFailure)
+	return string(status)
+}
+
+// Gateway runs a Server for every projector in dell.Projectors, including ones that are added later.
+// PJLink software expects to find each projector on port 4352 of its own IP address, so the best way to use the
+// gateway is to give this machine an extra IP address for each projector and put them in Addresses. Projectors that
+// aren't in Addresses are given ports counting up from BasePort
+type Gateway struct {
+	Password  string            // If it's set, clients need to authenticate with it
+	Addresses map[string]string // Projector UUIDs to the address to listen on for them, e.g. "10.0.0.21:4352"
+	BasePort  int               // Defaults to 4352
+
+	lock     sync.Mutex
+	servers  map[string]*Server
+	nextPort int
+	stop     func()
+}
+
+// NewGateway creates a gateway. Fill in Password and Addresses if you need them, then call Start
+func NewGateway() *Gateway {
+	return &Gateway{
+		Addresses: make(map[string]string),
+		BasePort:  Port,
+		servers:   make(map[string]*Server),
+	}
+}
+
+// Start starts a server for every projector we know about, and for each one that's added from now on
+func (g *Gateway) Start() error {
+	events, stop := dell.Subscribe()
+	g.stop = stop
+
+	for _, projector := range dell.ListProjectors() {
+		err := g.add(projector.UUID)
+		if err != nil {
+			stop()
+			return err
+		}
+	}
+
+	go func() {
+		for e := range events {
+			if e.Name == "projectoradded" {
+				g.add(e.ProjectorInfo.UUID)
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops every server
+func (g *Gateway) Stop() {
+	if g.stop != nil {
+		g.stop()
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for uuid, server := range g.servers {
+		server.Close()
+		delete(g.servers, uuid)
+	}
+}
+
+// Addr is the address a projector's server is listening on, or an empty string if it doesn't have one
+func (g *Gateway) Addr(uuid string) string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	server, ok := g.servers[uuid]
+	if !ok {
+		return ""
+	}
+	return server.Addr()
+}
+
+// add starts a server for a projector, if it doesn't already have one. Servers stay up when a projector goes away,
+// and answer with ERR4 until it comes back
+func (g *Gateway) add(uuid string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if _, ok := g.servers[uuid]; ok {
+		return nil
+	}
+
+	server := &Server{UUID: uuid, Password: g.Password}
+	addr, ok := g.Addresses[uuid]
+	if !ok {
+		addr = fmt.Sprintf(":%d", g.BasePort+g.nextPort)
+		g.nextPort++
+	}
+	err := server.Listen(addr)
+	if err != nil {
+		return err
+	}
+	g.servers[uuid] = server
+	return nil
+}
@@ -0,0 +1,273 @@
+package dell
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Policy decides when a projector that's been left on should be turned off automatically.
+// A projector is turned off when it's had no input signal for IdleTimeout, or when it's on during the curfew.
+// Before that happens, a "poweroffwarning" event is raised and Message is put on screen, and the projector is
+// only turned off if it's still idle (or still in curfew) once Warning has passed
+type Policy struct {
+	Disabled    bool
+	IdleTimeout time.Duration // Zero means projectors are never turned off for being idle
+	Curfew      string        // e.g. "22:00". Empty means there's no curfew
+	CurfewEnd   string        // e.g. "06:00". Empty means the curfew lasts until midnight
+	Warning     time.Duration // How long to wait between warning and turning the projector off
+	Message     string        // What to show on screen when warning
+}
+
+// DefaultPolicy is used for any projector that hasn't had a policy set with SetPolicy. It has no Message, as
+// messages can't be shown until MessageJoin is set
+var DefaultPolicy = Policy{
+	Warning: 5 * time.Minute,
+}
+
+// PolicyInterval is how often the policies are checked (and how often projectors are asked for their status)
+var PolicyInterval = 30 * time.Second
+
+// MessageJoin is the serial join used to put a message on the projector's screen. We don't know of one that works on
+// every model, so it's empty until you set it. Until then ShowMessage returns ErrNoMessageJoin, and StartPolicies
+// refuses to start if any policy has a Message (rather than warning nobody). A policy with a Message that's set after
+// the policies have started raises a "messagefailed" event in place of the message
+var MessageJoin = ""
+
+// ErrNoMessageJoin is returned by ShowMessage when MessageJoin hasn't been set
+var ErrNoMessageJoin = errors.New("no message join set (see MessageJoin)")
+
+// maxMessage is the longest text ShowMessage will send. The join's length has to fit in a single byte, and the join
+// type, join number and 0x03 flag take up 4 of those 255 bytes
+const maxMessage = 255 - 4
+
+// HasSignal decides whether a projector has an input signal. Source can't be used for this, because it's the name of
+// the selected input whether anything's plugged into it or not. Resolution is the resolution of the incoming signal,
+// which the projector sends on its own join, so a projector only has a signal if Resolution looks like "1280 x 800".
+// It's a variable so that you can swap in your own check if your projector reports signal differently
+var HasSignal = func(projector Projector) bool {
+	var width, height int
+	_, err := fmt.Sscanf(strings.ReplaceAll(projector.Resolution, " ", ""), "%dx%d", &width, &height)
+	return err == nil && width > 0 && height > 0
+}
+
+// policyLock guards all of the policy state below
+var policyLock sync.Mutex
+
+// policies holds per-projector overrides of DefaultPolicy
+var policies = make(map[string]Policy)
+
+// lastSignal is the last time we saw an input signal on each projector
+var lastSignal = make(map[string]time.Time)
+
+// warnings holds the time we warned each projector that it was about to be turned off
+var warnings = make(map[string]time.Time)
+
+// stopPolicies stops the goroutine started by StartPolicies
+var stopPolicies chan bool
+
+// SetPolicy overrides DefaultPolicy for a single projector
+func SetPolicy(uuid string, policy Policy) {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	policies[uuid] = policy
+}
+
+// ClearPolicy removes a projector's override, so it goes back to using DefaultPolicy
+func ClearPolicy(uuid string) {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	delete(policies, uuid)
+}
+
+// PolicyFor returns the policy that applies to a projector
+func PolicyFor(uuid string) Policy {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	if policy, ok := policies[uuid]; ok {
+		return policy
+	}
+	return DefaultPolicy
+}
+
+// StartPolicies starts checking every projector against its policy every PolicyInterval. If any policy has a Message
+// but MessageJoin isn't set, the message could never be shown, so it returns an error wrapping ErrNoMessageJoin
+// instead of starting
+func StartPolicies() (bool, error) {
+	policyLock.Lock()
+	if stopPolicies != nil {
+		policyLock.Unlock()
+		return false, nil
+	}
+	if MessageJoin == "" {
+		if who, ok := messagePolicy(); ok {
+			policyLock.Unlock()
+			return false, fmt.Errorf("%s has a Message, but there's %w", who, ErrNoMessageJoin)
+		}
+	}
+	stopPolicies = make(chan bool)
+	stop := stopPolicies
+	policyLock.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(PolicyInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				for _, p := range ListProjectors() {
+					checkPolicy(p, now)
+					// Ask for a fresh status, so Resolution is up to date next time round
+					if p.PowerState == PowerOn {
+						GetStatus(p)
+					}
+				}
+			}
+		}
+	}()
+
+	return true, nil
+}
+
+// messagePolicy finds a policy with a Message, and says whose it is (policyLock must be held)
+func messagePolicy() (string, bool) {
+	if DefaultPolicy.Message != "" && !DefaultPolicy.Disabled {
+		return "DefaultPolicy", true
+	}
+	for uuid, policy := range policies {
+		if policy.Message != "" && !policy.Disabled {
+			return "the policy for " + uuid, true
+		}
+	}
+	return "", false
+}
+
+// StopPolicies stops checking policies
+func StopPolicies() {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	if stopPolicies != nil {
+		close(stopPolicies)
+		stopPolicies = nil
+	}
+}
+
+// ShowMessage puts some text on the projector's screen, using MessageJoin. Text longer than 251 bytes doesn't fit in
+// a serial join, so it's cut short
+func ShowMessage(projector Projector, text string) (bool, error) {
+	if MessageJoin == "" {
+		return false, ErrNoMessageJoin
+	}
+	id, err := hex.DecodeString(MessageJoin)
+	if err != nil {
+		return false, err
+	}
+	for len(text) > maxMessage {
+		// Cut off whole characters, so we don't leave half of one at the end
+		_, size := utf8.DecodeLastRuneInString(text)
+		text = text[:len(text)-size]
+	}
+
+	// A serial join is 0x15, the join number, a 0x03 flag then the text. See feedback.go for the details
+	payload := append([]byte{joinSerial}, id...)
+	payload = append(payload, 0x03)
+	payload = append(payload, text...)
+	packet := []byte{cipData, byte((len(payload) + 3) >> 8), byte(len(payload) + 3), 0x00, 0x00, byte(len(payload))}
+	packet = append(packet, payload...)
+
+	err = sendRaw(hex.EncodeToString(packet), projector)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// checkPolicy works out whether a projector should be warned or turned off
+func checkPolicy(projector Projector, now time.Time) {
+	policy := PolicyFor(projector.UUID)
+	uuid := projector.UUID
+
+	policyLock.Lock()
+	if policy.Disabled || projector.PowerState != PowerOn {
+		// Start counting from scratch next time it's on
+		delete(lastSignal, uuid)
+		delete(warnings, uuid)
+		policyLock.Unlock()
+		return
+	}
+
+	last, seen := lastSignal[uuid]
+	if !seen || HasSignal(projector) {
+		last = now
+		lastSignal[uuid] = now
+	}
+
+	reason := ""
+	if policy.IdleTimeout > 0 && now.Sub(last) >= policy.IdleTimeout {
+		reason = "idle"
+	}
+	if inCurfew(policy, now) {
+		reason = "curfew"
+	}
+
+	warnedAt, warned := warnings[uuid]
+	switch {
+	case reason == "":
+		delete(warnings, uuid)
+	case !warned:
+		warnings[uuid] = now
+	case now.Sub(warnedAt) >= policy.Warning:
+		delete(warnings, uuid)
+		delete(lastSignal, uuid)
+	}
+	policyLock.Unlock()
+
+	switch {
+	case reason == "" && warned:
+		passDetail("poweroffcancelled", projector, "")
+	case reason != "" && !warned:
+		passDetail("poweroffwarning", projector, reason)
+		if policy.Message != "" {
+			if _, err := ShowMessage(projector, policy.Message); err != nil {
+				passDetail("messagefailed", projector, err.Error())
+			}
+		}
+	case reason != "" && now.Sub(warnedAt) >= policy.Warning:
+		SendCommand(projector, Commands.Power.Off)
+		passDetail("autopoweroff", projector, reason)
+	}
+}
+
+// inCurfew works out whether now is between the policy's Curfew and CurfewEnd
+func inCurfew(policy Policy, now time.Time) bool {
+	if policy.Curfew == "" {
+		return false
+	}
+	start, err := time.Parse("15:04", policy.Curfew)
+	if err != nil {
+		return false
+	}
+	from := start.Hour()*60 + start.Minute()
+
+	to := 24 * 60 // Midnight
+	if policy.CurfewEnd != "" {
+		end, err := time.Parse("15:04", policy.CurfewEnd)
+		if err != nil {
+			return false
+		}
+		to = end.Hour()*60 + end.Minute()
+	}
+
+	current := now.Hour()*60 + now.Minute()
+	if from < to {
+		return current >= from && current < to
+	}
+	// The curfew goes past midnight (e.g. 22:00 until 06:00)
+	return current >= from || current < to
+}
@@ -0,0 +1,230 @@
+package dell
+
+import (
+	"errors"
+	"time"
+)
+
+// PowerState is where a projector is in its power cycle. Projectors don't go straight from off to on; they spend
+// a while warming up (and cooling down on the way back) and ignore most commands while they're doing it
+type PowerState int
+
+// The power states a projector can be in. PowerUnknown is what we start with, before the projector has told us anything
+const (
+	PowerUnknown PowerState = iota
+	PowerOff
+	PowerWarmingUp
+	PowerOn
+	PowerCoolingDown
+)
+
+// String turns a PowerState into something a bit more readable
+func (s PowerState) String() string {
+	switch s {
+	case PowerOff:
+		return "Off"
+	case PowerWarmingUp:
+		return "Warming Up"
+	case PowerOn:
+		return "On"
+	case PowerCoolingDown:
+		return "Cooling Down"
+	}
+	return "Unknown"
+}
+
+// MarshalText lets PowerState show up as text (e.g. "Warming Up") in JSON
+func (s PowerState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// WarmUpTime is how long we assume a projector takes to warm up after being turned on
+var WarmUpTime = 30 * time.Second
+
+// CoolDownTime is how long we assume a projector takes to cool down after being turned off
+var CoolDownTime = 90 * time.Second
+
+// ScheduleDuringCoolDown decides what happens to Power.On if it's sent while the projector is cooling down.
+// If it's false, SendCommand returns ErrCoolingDown. If it's true, the command is held on to and sent once the projector is off
+var ScheduleDuringCoolDown = false
+
+// ErrCoolingDown is returned by SendCommand when you try to turn on a projector that's still cooling down
+var ErrCoolingDown = errors.New("projector is cooling down")
+
+// deferred holds commands (per projector UUID) that are waiting for the projector to finish warming up or cooling down
+var deferred = make(map[string][]string)
+
+// powerTimers holds the timer that will finish off a warm up or cool down, so we can cancel it if the state changes first
+var powerTimers = make(map[string]*time.Timer)
+
+// queueCommand decides whether a command can be sent right now. If the projector is busy warming up or cooling down,
+// the command is either deferred (and queued is true) or rejected with an error
+func queueCommand(projector Projector, command string) (queued bool, err error) {
+	projectorsLock.Lock()
+	defer projectorsLock.Unlock()
+
+	current, exists := Projectors[projector.UUID]
+	if !exists {
+		return false, nil
+	}
+
+	switch current.PowerState {
+	case PowerWarmingUp:
+		// Projectors ignore just about everything while warming up, so hold on to it until we're on
+		if command == Commands.Power.On {
+			return false, nil
+		}
+		deferred[projector.UUID] = append(deferred[projector.UUID], command)
+		return true, nil
+	case PowerCoolingDown:
+		if command != Commands.Power.On {
+			return false, nil
+		}
+		if !ScheduleDuringCoolDown {
+			return false, ErrCoolingDown
+		}
+		deferred[projector.UUID] = append(deferred[projector.UUID], command)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// commandSent is called after a command has gone out, so that power commands kick off a warm up or cool down straight
+// away without waiting for the projector to tell us about it. If we don't know what state the projector was in (it's
+// only just been added, say), we know it is now: we've just told it to warm up or cool down
+func commandSent(projector Projector, command string) {
+	switch command {
+	case Commands.Power.On:
+		transitionPower(projector.UUID, func(current PowerState) PowerState {
+			if current == PowerUnknown {
+				return PowerWarmingUp
+			}
+			return nextPowerState(current, PowerOn)
+		})
+	case Commands.Power.Off:
+		transitionPower(projector.UUID, func(current PowerState) PowerState {
+			if current == PowerUnknown {
+				return PowerCoolingDown
+			}
+			return nextPowerState(current, PowerOff)
+		})
+	}
+}
+
+// setPowerState feeds a power state (usually reported by the projector) into the state machine.
+// Going from off to on passes through PowerWarmingUp for WarmUpTime, and on to off passes through PowerCoolingDown
+// for CoolDownTime. If we don't know what state we're in (e.g. we've just connected), we take the projector's word for it
+func setPowerState(uuid string, reported PowerState) {
+	transitionPower(uuid, func(current PowerState) PowerState {
+		return nextPowerState(current, reported)
+	})
+}
+
+// nextPowerState works out which state a projector in the current state moves to when it reports another
+func nextPowerState(current PowerState, reported PowerState) PowerState {
+	switch reported {
+	case PowerOn:
+		switch current {
+		case PowerOff:
+			return PowerWarmingUp
+		case PowerWarmingUp, PowerCoolingDown:
+			// Wait for the timer. Projectors often say they're on before they'll actually listen to us,
+			// and can keep saying it for a while after they've been told to turn off
+			return current
+		}
+	case PowerOff:
+		switch current {
+		case PowerOn:
+			return PowerCoolingDown
+		case PowerWarmingUp, PowerCoolingDown:
+			return current
+		}
+	}
+	return reported
+}
+
+// transitionPower moves a projector to the state decide picks for it, starting the warm up or cool down timer if
+// need be, and sending anything deferred once it's settled on or off
+func transitionPower(uuid string, decide func(current PowerState) PowerState) {
+	projectorsLock.Lock()
+	projector, exists := Projectors[uuid]
+	if !exists {
+		projectorsLock.Unlock()
+		return
+	}
+
+	next := decide(projector.PowerState)
+	if next == projector.PowerState {
+		projectorsLock.Unlock()
+		return
+	}
+
+	if timer, ok := powerTimers[uuid]; ok {
+		timer.Stop()
+		delete(powerTimers, uuid)
+	}
+
+	switch next {
+	case PowerWarmingUp:
+		powerTimers[uuid] = time.AfterFunc(WarmUpTime, func() { finishPowerTransition(uuid, PowerWarmingUp, PowerOn) })
+	case PowerCoolingDown:
+		powerTimers[uuid] = time.AfterFunc(CoolDownTime, func() { finishPowerTransition(uuid, PowerCoolingDown, PowerOff) })
+	}
+
+	projector.PowerState = next
+	Projectors[uuid] = projector
+	projectorsLock.Unlock()
+
+	passMessage("powerstatechanged", projector)
+
+	if next == PowerOn || next == PowerOff {
+		flushDeferred(uuid)
+	}
+}
+
+// finishPowerTransition moves a projector on from warming up or cooling down once the timer runs out
+func finishPowerTransition(uuid string, from PowerState, to PowerState) {
+	projectorsLock.Lock()
+	projector, exists := Projectors[uuid]
+	if !exists || projector.PowerState != from {
+		projectorsLock.Unlock()
+		return
+	}
+
+	delete(powerTimers, uuid)
+	projector.PowerState = to
+	Projectors[uuid] = projector
+	projectorsLock.Unlock()
+
+	passMessage("powerstatechanged", projector)
+	flushDeferred(uuid)
+}
+
+// flushDeferred sends any commands that were held back while the projector was warming up or cooling down
+func flushDeferred(uuid string) {
+	projectorsLock.Lock()
+	commands := deferred[uuid]
+	delete(deferred, uuid)
+	projector := Projectors[uuid]
+	projectorsLock.Unlock()
+
+	for _, command := range commands {
+		_, err := SendCommand(projector, command)
+		if err != nil {
+			debug("Unable to send deferred command to", projector.IP, ":", err)
+		}
+	}
+}
+
+// clearPowerState forgets about any timers or deferred commands for a projector. Used when a projector is removed
+func clearPowerState(uuid string) {
+	projectorsLock.Lock()
+	defer projectorsLock.Unlock()
+
+	if timer, ok := powerTimers[uuid]; ok {
+		timer.Stop()
+		delete(powerTimers, uuid)
+	}
+	delete(deferred, uuid)
+}
@@ -0,0 +1,225 @@
+// Package relay passes DDDP beacons from one network to another, for when projectors are on an AV VLAN that control
+// PCs never see multicast from. A Relay reads beacons from a dell.BeaconSource (usually the multicast group on the AV
+// VLAN's interface) and sends them on, exactly as they arrived, to another interface's multicast group or to a list
+// of addresses:
+//
+//	iface, _ := dell.FindInterface("eth1")
+//	source, _ := dell.MulticastSourceOn(dell.MulticastAddr, iface)
+//	r := &relay.Relay{Source: source, Targets: []string{"10.30.0.5:9131"}}
+//	err := r.Run()
+//
+// Relayed beacons come from the relay's address rather than the projector's, as sending them from the projector's
+// address would mean forging it: that needs a raw socket (so root, or CAP_NET_RAW on Linux, and something the standard
+// library doesn't do portably), and routers doing reverse path filtering drop packets from a VLAN that claim to come
+// from another one. So anything listening sees the relay as the projector, and will try to connect to the relay.
+//
+// For dell, set AddOrigin, which adds an Origin tag with the projector's address to each beacon, and put the relay's
+// address in dell.TrustedRelays on the listening side. dell then connects to the Origin, but only for beacons from a
+// relay it trusts, so nothing else on the network can point it somewhere else. The tag is the one change AddOrigin
+// makes to a beacon, and DDDP listeners that don't know about it (Crestron Toolbox, RoomView and the like) will still
+// see the relay's address, or may throw the beacon away for having a tag they don't expect. If you need those to
+// work, route multicast between the VLANs (with PIM or an IGMP proxy) instead of using a relay.
+//
+// Relays pointed at each other would pass the same beacons back and forth forever, so each relay remembers what it's
+// relayed recently (by UUID and contents, both as it arrived and as it was sent) and drops it if it comes back within
+// LoopWindow
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/dddp"
+)
+
+// OriginTag is the tag AddOrigin adds to beacons, holding the address of the projector that sent it
+const OriginTag = dell.OriginTag
+
+// ErrNoTargets is returned by Run when the relay has nowhere to send beacons
+var ErrNoTargets = errors.New("relay: no interface or targets to relay to")
+
+// Relay passes beacons from Source on to Interface and/or Targets
+type Relay struct {
+	Source    dell.BeaconSource
+	Interface *net.Interface // If it's set, beacons are multicast to dell.MulticastAddr from this interface's address
+	Targets   []string       // Addresses to send beacons to, e.g. "10.30.0.5:9131"
+
+	// MinInterval is the least time between beacons from the same projector. Anything sooner is dropped.
+	// Projectors beacon every 30 seconds or so, so the default of 5 seconds only stops floods
+	MinInterval time.Duration
+	// MaxPerSecond is the most beacons relayed in any one second, from all projectors together. 0 means no limit
+	MaxPerSecond int
+	// LoopWindow is how long a relayed beacon is remembered for, so it isn't relayed again if it comes back. Loops
+	// come round in milliseconds, so the default of 2 seconds is plenty, and well short of how often projectors beacon
+	LoopWindow time.Duration
+	// AddOrigin adds an Origin tag with the projector's address to beacons that don't have one (see the package
+	// documentation). Off by default, so beacons are passed on unchanged
+	AddOrigin bool
+
+	lock     sync.Mutex
+	conns    []*net.UDPConn
+	lastSent map[string]time.Time // Keyed by UUID
+	recent   map[string]time.Time // When beacons were last relayed, keyed by UUID and contents
+	second   time.Time            // The start of the second we're counting beacons in
+	count    int                  // Beacons relayed this second
+	relayed  int
+	dropped  int
+}
+
+// Run relays beacons until Source is closed
+func (r *Relay) Run() error {
+	err := r.open()
+	if err != nil {
+		return err
+	}
+	defer r.closeConns()
+
+	for {
+		data, ip, err := r.Source.ReadBeacon()
+		if err != nil {
+			return err
+		}
+		packet, ok := r.allow(data, ip)
+		if !ok {
+			continue
+		}
+		for _, conn := range r.conns {
+			conn.Write(packet)
+		}
+	}
+}
+
+// Close stops the relay by closing its Source
+func (r *Relay) Close() error {
+	return r.Source.Close()
+}
+
+// Stats returns how many beacons have been relayed and how many have been dropped (because they'd just been relayed,
+// were too soon after the last one or went over MaxPerSecond, or weren't beacons at all)
+func (r *Relay) Stats() (relayed int, dropped int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.relayed, r.dropped
+}
+
+// open connects to everywhere we're relaying to
+func (r *Relay) open() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	targets := append([]string(nil), r.Targets...)
+	var local *net.UDPAddr
+	if r.Interface != nil {
+		targets = append(targets, dell.MulticastAddr)
+		// Sending from the interface's address makes the multicast go out of that interface
+		addrs, _ := r.Interface.Addrs()
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok && network.IP.To4() != nil {
+				local = &net.UDPAddr{IP: network.IP}
+				break
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return ErrNoTargets
+	}
+
+	for i, target := range targets {
+		addr, err := net.ResolveUDPAddr("udp4", target)
+		if err != nil {
+			r.closeLocked()
+			return err
+		}
+		var from *net.UDPAddr
+		if r.Interface != nil && i == len(targets)-1 {
+			from = local
+		}
+		conn, err := net.DialUDP("udp4", from, addr)
+		if err != nil {
+			r.closeLocked()
+			return err
+		}
+		r.conns = append(r.conns, conn)
+	}
+	return nil
+}
+
+func (r *Relay) closeConns() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.closeLocked()
+}
+
+// closeLocked closes everything we're relaying to (r.lock must be held)
+func (r *Relay) closeLocked() {
+	for _, conn := range r.conns {
+		conn.Close()
+	}
+	r.conns = nil
+}
+
+// allow decides whether a beacon should be relayed, and if so, returns what to send
+func (r *Relay) allow(data []byte, ip net.IP) ([]byte, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	beacon, err := dddp.Parse(data)
+	if err != nil {
+		r.dropped++
+		return nil, false
+	}
+
+	now := time.Now()
+	window := r.LoopWindow
+	if window == 0 {
+		window = 2 * time.Second
+	}
+	if r.recent == nil {
+		r.recent = make(map[string]time.Time)
+	}
+	for key, sent := range r.recent {
+		if now.Sub(sent) >= window {
+			delete(r.recent, key)
+		}
+	}
+	if _, looped := r.recent[beacon.UUID+"\x00"+string(data)]; looped {
+		r.dropped++
+		return nil, false
+	}
+
+	interval := r.MinInterval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	if r.lastSent == nil {
+		r.lastSent = make(map[string]time.Time)
+	}
+	if last, ok := r.lastSent[beacon.UUID]; ok && now.Sub(last) < interval {
+		r.dropped++
+		return nil, false
+	}
+	if now.Sub(r.second) >= time.Second {
+		r.second, r.count = now, 0
+	}
+	if r.MaxPerSecond > 0 && r.count >= r.MaxPerSecond {
+		r.dropped++
+		return nil, false
+	}
+
+	packet := append([]byte(nil), data...)
+	if _, tagged := beacon.Get(OriginTag); r.AddOrigin && !tagged {
+		// Any padding would be in the way of the tag
+		packet = append(bytes.TrimRight(packet, "\x00 \t\r\n"), "<-"+OriginTag+"="+ip.String()+">"...)
+	}
+	// Remember it both ways, so that it's dropped if it comes back whether or not another relay has added a tag
+	r.recent[beacon.UUID+"\x00"+string(data)] = now
+	r.recent[beacon.UUID+"\x00"+string(packet)] = now
+	r.lastSent[beacon.UUID] = now
+	r.count++
+	r.relayed++
+	return packet, true
+}
@@ -0,0 +1,187 @@
+package dell
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScanPorts are the ports Scan tries on each address
+var ScanPorts = []int{41794}
+
+// ScanConcurrency is how many addresses Scan probes at once. Anything less than 1 is treated as 1
+var ScanConcurrency = 32
+
+// ScanTimeout is how long Scan gives each address to connect and answer a status request
+var ScanTimeout = 2 * time.Second
+
+// scanQuiet is how long a projector has to go quiet before we decide it's finished sending its status
+var scanQuiet = 250 * time.Millisecond
+
+// ErrScanTooBig is returned by Scan for ranges of more than 65536 addresses
+var ErrScanTooBig = errors.New("scan range is too big")
+
+// Scan looks for projectors by trying to connect to every address in cidr (e.g. "10.20.0.0/24"), for networks where
+// beacons don't make it through (multicast is often blocked between VLANs). Anything that accepts a connection on one
+// of ScanPorts and answers a status request with CIP feedback is registered as if it had sent a beacon, so it raises
+// "projectorfound" (or "projectorupdated") in the same way. The projectors found are returned too.
+//
+// Projectors found by a scan don't say what their UUID is, so their MAC address (without the colons) is used instead.
+// They don't say what model they are either: the model is only in beacons, and PropertyList has no join for it, so
+// Model is left empty. If you know which serial join your projectors send their model on, add it to PropertyList as
+// "Model" before calling Init and Scan will fill it in.
+// If you're also listening for beacons, they'll also be found again under their DDDP UUID unless it's the same.
+// Scanned projectors don't beacon, so if you're listening for beacons they'll be "lost" after a while unless you scan
+// again. If ctx is cancelled, Scan stops and returns what it's found so far along with ctx's error
+func Scan(ctx context.Context, cidr string) ([]Projector, error) {
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	if ip.To4() == nil {
+		return nil, errors.New("only IPv4 ranges can be scanned")
+	}
+	ones, bits := network.Mask.Size()
+	if bits-ones > 16 {
+		return nil, ErrScanTooBig
+	}
+
+	var found []Projector
+	var foundLock sync.Mutex
+	var wait sync.WaitGroup
+	concurrency := ScanConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+
+	for _, host := range hosts(network) {
+		for _, port := range ScanPorts {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				wait.Wait()
+				return found, ctx.Err()
+			}
+
+			wait.Add(1)
+			go func(host net.IP, port int) {
+				defer wait.Done()
+				defer func() { <-slots }()
+
+				projector, ok := probe(ctx, host, port)
+				if !ok {
+					return
+				}
+				foundLock.Lock()
+				found = append(found, projector)
+				foundLock.Unlock()
+				sawBeacon(projector)
+			}(host, port)
+		}
+	}
+
+	wait.Wait()
+	return found, ctx.Err()
+}
+
+// hosts lists the addresses in a network. The network and broadcast addresses are left out, unless the network is
+// so small (a /31 or /32) that they're all there is
+func hosts(network *net.IPNet) []net.IP {
+	base := network.IP.To4()
+	ones, bits := network.Mask.Size()
+	size := uint32(1) << uint(bits-ones)
+	start := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8 | uint32(base[3])
+
+	first, last := uint32(0), size-1
+	if size > 2 {
+		first, last = 1, size-2
+	}
+	var ips []net.IP
+	for i := first; i <= last; i++ {
+		n := start + i
+		ips = append(ips, net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)))
+	}
+	return ips
+}
+
+// probe connects to an address, asks for a status dump and works out what it can about the projector from it.
+// ok is false if nothing's there, or whatever is there doesn't speak CIP
+func probe(ctx context.Context, host net.IP, port int) (Projector, bool) {
+	ctx, cancel := context.WithTimeout(ctx, ScanTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host.String(), strconv.Itoa(port)))
+	if err != nil {
+		return Projector{}, false
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetReadDeadline(deadline)
+
+	buf, ok := handshake(conn)
+	if !ok {
+		return Projector{}, false
+	}
+	request, _ := hex.DecodeString(statusRequest)
+	_, err = conn.Write(request)
+	if err != nil {
+		return Projector{}, false
+	}
+
+	projector := Projector{IP: host.String()}
+	if port != CIPPort {
+		projector.Port = port
+	}
+	var mac string
+	chunk := make([]byte, 4096)
+	answered := false
+	for {
+		// Wait until the projector goes quiet (once it's started talking), or we run out of time
+		wait := deadline
+		if answered && time.Now().Add(scanQuiet).Before(deadline) {
+			wait = time.Now().Add(scanQuiet)
+		}
+		conn.SetReadDeadline(wait)
+
+		n, err := conn.Read(chunk)
+		if n > 0 {
+			var joins []join
+			joins, buf = parseJoins(append(buf, chunk[:n]...))
+			for _, j := range joins {
+				answered = true
+				applyJoin(&projector, j)
+				if j.Type != joinSerial {
+					continue
+				}
+				switch properties[j.ID] {
+				case "MAC":
+					mac = j.Serial
+				case "Firmware":
+					projector.Revision = j.Serial
+				case "Model":
+					projector.Model = j.Serial
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if !answered {
+		return Projector{}, false
+	}
+
+	projector.UUID = strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(mac))
+	if projector.UUID == "" {
+		// We have to call it something
+		projector.UUID = net.JoinHostPort(projector.IP, strconv.Itoa(port))
+	}
+	return projector, true
+}
@@ -0,0 +1,183 @@
+package serial
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+func init() {
+	dell.RegisterDriver(Protocol, func() dell.Driver { return &Driver{} })
+}
+
+// Driver is a dell.Driver that talks RS-232 through a serial device server
+type Driver struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	lock   sync.Mutex // Only one command can be waiting for an answer at a time
+	tables *tables
+	uuid   string
+	polled bool
+}
+
+// Connect connects to the serial device server
+func (d *Driver) Connect(projector dell.Projector) (net.Conn, error) {
+	t, err := load()
+	if err != nil {
+		return nil, err
+	}
+	d.tables = t
+
+	port := projector.Port
+	if port == 0 {
+		port = Port
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(projector.IP, strconv.Itoa(port)), Timeout)
+	if err != nil {
+		return nil, err
+	}
+	d.conn = conn
+	d.r = bufio.NewReader(conn)
+	d.uuid = projector.UUID
+	return conn, nil
+}
+
+// Read polls the projector every PollInterval. Serial ports don't tell us when the other end goes away, so if the
+// projector stops answering, we give up on it
+func (d *Driver) Read(projector dell.Projector) error {
+	if d.polled {
+		time.Sleep(PollInterval)
+	}
+	d.polled = true
+	return d.Status()
+}
+
+func (d *Driver) Close() error {
+	if d.conn == nil {
+		return nil
+	}
+	return d.conn.Close()
+}
+
+func (d *Driver) PowerOn() error {
+	return d.Send(dell.Commands.Power.On)
+}
+
+func (d *Driver) PowerOff() error {
+	return d.Send(dell.Commands.Power.Off)
+}
+
+func (d *Driver) SetInput(input string) error {
+	return d.named("Input." + input)
+}
+
+func (d *Driver) Mute(muted bool) error {
+	if muted {
+		return d.named("Volume.Mute")
+	}
+	return d.named("Volume.Unmute")
+}
+
+// Send sends a command from dell.Commands, by looking up its name in CommandList
+func (d *Driver) Send(command string) error {
+	return d.named(dell.CommandName(command))
+}
+
+// named sends a command by its dotted name
+func (d *Driver) named(name string) error {
+	code, ok := d.tables.lookup(name)
+	if !ok {
+		return dell.ErrUnsupported
+	}
+	_, err := d.exchange(code, false)
+	return err
+}
+
+// Status asks the projector for its power, input and lamp hours
+func (d *Driver) Status() error {
+	answers := make(map[string][]byte)
+	for _, name := range []string{"power", "input", "lamp"} {
+		code, ok := d.tables.queries[name]
+		if !ok {
+			continue
+		}
+		answer, err := d.exchange(code, true)
+		if err == ErrRejected {
+			// Some queries (like input) are rejected while the projector is off
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		answers[name] = answer
+	}
+
+	dell.UpdateProjector(d.uuid, func(projector *dell.Projector) {
+		if input := answers["input"]; len(input) >= 1 {
+			if name, ok := d.tables.inputs[input[0]]; ok {
+				projector.Source = name
+			}
+		}
+		if lamp := answers["lamp"]; len(lamp) >= 2 {
+			projector.LampHours = int(lamp[0]) | int(lamp[1])<<8
+		}
+	})
+
+	if power := answers["power"]; len(power) >= 1 {
+		dell.ReportPowerState(d.uuid, powerState(power[0]))
+	}
+	return nil
+}
+
+// exchange sends a code and waits for the projector's answer. Queries are answered with ackData and some data;
+// everything else just gets an ack
+func (d *Driver) exchange(code []byte, query bool) ([]byte, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.conn == nil {
+		return nil, dell.ErrOffline
+	}
+
+	d.conn.SetDeadline(time.Now().Add(Timeout))
+	_, err := d.conn.Write(code)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch b {
+		case nak:
+			return nil, ErrRejected
+		case ack:
+			if !query {
+				return nil, nil
+			}
+		case ackData:
+			if !query {
+				continue
+			}
+			// ackData is followed by the number of bytes in the answer, then the answer
+			length, err := d.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			answer := make([]byte, length)
+			_, err = io.ReadFull(d.r, answer)
+			if err != nil {
+				return nil, err
+			}
+			return answer, nil
+		default:
+			// Anything else is line noise (or the projector's boot messages), so skip it
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package serial
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+)
+
+// FakeServer pretends to be a projector behind a serial device server. It understands the codes in CommandList
+// and QueryList, and keeps track of power, input and lamp hours so that queries give sensible answers.
+// Power changes straight away, without warming up or cooling down
+type FakeServer struct {
+	Power     byte   // 0 for off, 1 for on, 2 for warming up and 3 for cooling down
+	Input     string // One of the names in dell.Commands.Input
+	LampHours int
+
+	received []string
+	lock     sync.Mutex
+	listener net.Listener
+	tables   *tables
+}
+
+// Listen starts accepting connections on addr. Use "127.0.0.1:0" to pick any free port, then Addr to find out which
+func (f *FakeServer) Listen(addr string) error {
+	t, err := load()
+	if err != nil {
+		return err
+	}
+	f.tables = t
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	f.listener = l
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return nil
+}
+
+// Addr is the address the server is listening on
+func (f *FakeServer) Addr() string {
+	return f.listener.Addr().String()
+}
+
+// Close stops accepting connections
+func (f *FakeServer) Close() error {
+	return f.listener.Close()
+}
+
+// serve answers one connection. Codes can arrive split up or run together, like they would over a real serial line,
+// so we buffer what we've got until it matches something
+func (f *FakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	var buf []byte
+	chunk := make([]byte, 256)
+	for {
+		n, err := conn.Read(chunk)
+		if err != nil {
+			return
+		}
+		buf = append(buf, chunk[:n]...)
+
+		for {
+			answer, used := f.handle(buf)
+			if used == 0 {
+				break
+			}
+			buf = buf[used:]
+			conn.Write(answer)
+		}
+
+		// Throw away anything that can't be the start of a code
+		if len(buf) > 0 && buf[0] != 0xbe {
+			buf = buf[1:]
+		}
+	}
+}
+
+// handle answers the code at the start of buf, and returns how many bytes of buf it used. It returns 0 if buf doesn't
+// hold a whole code yet
+func (f *FakeServer) handle(buf []byte) ([]byte, int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for name, code := range f.tables.queries {
+		if !bytes.HasPrefix(buf, code) {
+			continue
+		}
+		var answer []byte
+		switch name {
+		case "power":
+			answer = []byte{f.Power}
+		case "input":
+			if f.Power != 1 {
+				return []byte{nak}, len(code)
+			}
+			input, _ := f.tables.inputByte(f.Input)
+			answer = []byte{input}
+		case "lamp":
+			answer = []byte{byte(f.LampHours), byte(f.LampHours >> 8)}
+		}
+		return append([]byte{ackData, byte(len(answer))}, answer...), len(code)
+	}
+
+	for name, code := range f.tables.commands {
+		if !bytes.HasPrefix(buf, code) {
+			continue
+		}
+		f.received = append(f.received, name)
+		switch {
+		case name == "power.on":
+			f.Power = 1
+		case name == "power.off":
+			f.Power = 0
+		case strings.HasPrefix(name, "input."):
+			if f.Power != 1 {
+				return []byte{nak}, len(code)
+			}
+			for _, input := range f.tables.inputs {
+				if strings.EqualFold("input."+input, name) {
+					f.Input = input
+				}
+			}
+		}
+		return []byte{ack}, len(code)
+	}
+
+	// If buf is as long as the longest code and still doesn't match, it never will
+	if len(buf) >= f.longest() {
+		return []byte{nak}, len(buf)
+	}
+	return nil, 0
+}
+
+// longest is the length of the longest code we know about
+func (f *FakeServer) longest() int {
+	longest := 0
+	for _, table := range []map[string][]byte{f.tables.commands, f.tables.queries} {
+		for _, code := range table {
+			if len(code) > longest {
+				longest = len(code)
+			}
+		}
+	}
+	return longest
+}
+
+// State returns the server's power, input and lamp hours without racing with connections
+func (f *FakeServer) State() (power byte, input string, lampHours int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.Power, f.Input, f.LampHours
+}
+
+// Received returns the names of the commands we've been sent, in order. The names are in lower case
+func (f *FakeServer) Received() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.received...)
+}
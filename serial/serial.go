@@ -0,0 +1,202 @@
+// Package serial is a dell.Driver for projectors that are controlled over RS-232 through a serial device server
+// (a serial-to-Ethernet converter), rather than the projector's own network port. The device server passes bytes
+// between a raw TCP socket and the projector's serial port, so we just need to send the right bytes.
+//
+// Importing this package registers the driver:
+//
+//	dell.AddProjector(dell.Projector{UUID: "room-7", IP: "10.0.0.70", Port: 4001, Protocol: serial.Protocol})
+//
+// Commands use the same names as dell.Commands, and are looked up in CommandList. Power, input and lamp hours are
+// polled with the queries in QueryList.
+//
+// None of the codes have been checked against a real projector. They're placeholders in the shape the driver expects,
+// and FakeServer is built from the same tables, so a test against FakeServer only shows that the two agree. Dell's
+// own RS-232 codes are framed differently (a BE EF header, a two byte length, a CRC and then the command, for example
+// BE EF 10 05 00 C6 FF 11 11 01 00 01), so copy each one from the RS-232 section of your projector's manual into
+// CommandList and QueryList before relying on the driver. Once that's done, run tests/serial against your device
+// server (go run ./tests/serial 10.0.0.70:4001) to see what the driver reads back
+//
+// FakeServer pretends to be a projector behind a device server, for testing
+package serial
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// Protocol is the name the serial driver is registered under
+const Protocol = "serial"
+
+// Port is the TCP port used if Projector.Port isn't set. It's the usual raw TCP port on Moxa device servers.
+// Other brands use different ports (Lantronix uses 10001, for example)
+var Port = 4001
+
+// PollInterval is how often the driver asks the projector for its power, input and lamp hours
+var PollInterval = 10 * time.Second
+
+// Timeout is how long we wait for the projector to answer
+var Timeout = 2 * time.Second
+
+// The bytes the projector answers with. Commands are answered with ack or nak, and queries are answered with
+// ackData followed by the answer
+const (
+	ack     = 0x06
+	nak     = 0x15
+	ackData = 0x1d
+)
+
+// ErrRejected is returned when the projector answers a command with a NAK
+var ErrRejected = errors.New("serial: projector rejected the command")
+
+// CommandList maps the names in dell.Commands to the hex codes sent over the serial port.
+// The codes are placeholders (see the package comment) rather than Dell's, and have no CRC, so they need replacing
+// with the ones in your projector's manual. It's exported so that you can overwrite it
+var CommandList = []byte(`
+  {
+	"Power.On": "beef10050001010000",
+	"Power.Off": "beef10050001020000",
+	"Input.VGAA": "beef10050002010000",
+	"Input.VGAB": "beef10050002020000",
+	"Input.Composite": "beef10050002030000",
+	"Input.SVideo": "beef10050002040000",
+	"Input.HDMI": "beef10050002050000",
+	"Input.Wireless": "beef10050002060000",
+	"Input.USBDisplay": "beef10050002070000",
+	"Input.USBViewer": "beef10050002080000",
+	"Volume.Up": "beef10050003010000",
+	"Volume.Down": "beef10050003020000",
+	"Volume.Mute": "beef10050003030000",
+	"Volume.Unmute": "beef10050003040000",
+	"Picture.Mute": "beef10050004010000",
+	"Picture.Unmute": "beef10050004020000",
+	"Picture.Freeze": "beef10050004030000",
+	"Picture.Unfreeze": "beef10050004040000",
+	"Picture.Contrast.Up": "beef10050004050000",
+	"Picture.Contrast.Down": "beef10050004060000",
+	"Picture.Brightness.Up": "beef10050004070000",
+	"Picture.Brightness.Down": "beef10050004080000",
+	"Menu.Menu": "beef10050005010000",
+	"Menu.Up": "beef10050005020000",
+	"Menu.Down": "beef10050005030000",
+	"Menu.Left": "beef10050005040000",
+	"Menu.Right": "beef10050005050000",
+	"Menu.OK": "beef10050005060000"
+}
+`)
+
+// QueryList holds the hex codes that ask the projector about its power, input and lamp hours.
+// The projector answers with 0x1D, the length of the answer and then the answer itself: one byte for power (0 for off,
+// 1 for on, 2 for warming up and 3 for cooling down), one byte for the input (see InputList) and two bytes
+// (little endian) for lamp hours. Like CommandList, the codes and the layout of the answers are placeholders
+var QueryList = []byte(`
+  {
+	"Power": "beef100500010f0000",
+	"Input": "beef100500020f0000",
+	"Lamp": "beef100500060f0000"
+}
+`)
+
+// InputList maps the input byte in the answer to the input query (in hex) to the names in dell.Commands.Input
+var InputList = []byte(`
+  {
+	"01": "VGAA",
+	"02": "VGAB",
+	"03": "Composite",
+	"04": "SVideo",
+	"05": "HDMI",
+	"06": "Wireless",
+	"07": "USBDisplay",
+	"08": "USBViewer"
+}
+`)
+
+// tables holds CommandList, QueryList and InputList once they've been unmarshalled
+type tables struct {
+	commands map[string][]byte // Keyed by lower case name
+	queries  map[string][]byte // Keyed by lower case name
+	inputs   map[byte]string
+}
+
+// load unmarshals CommandList, QueryList and InputList. It's called whenever a driver connects, so that changes to the
+// lists are picked up
+func load() (*tables, error) {
+	var t tables
+	var err error
+	t.commands, err = decodeTable(CommandList)
+	if err != nil {
+		return nil, err
+	}
+	t.queries, err = decodeTable(QueryList)
+	if err != nil {
+		return nil, err
+	}
+
+	var list map[string]string
+	err = json.Unmarshal(InputList, &list)
+	if err != nil {
+		return nil, err
+	}
+	t.inputs = make(map[byte]string)
+	for code, name := range list {
+		b, err := hex.DecodeString(code)
+		if err != nil || len(b) != 1 {
+			return nil, errors.New("serial: bad input code " + code)
+		}
+		t.inputs[b[0]] = name
+	}
+	return &t, nil
+}
+
+// decodeTable unmarshals a JSON object of names to hex codes, and decodes the codes
+func decodeTable(table []byte) (map[string][]byte, error) {
+	var list map[string]string
+	err := json.Unmarshal(table, &list)
+	if err != nil {
+		return nil, err
+	}
+	decoded := make(map[string][]byte)
+	for name, code := range list {
+		b, err := hex.DecodeString(strings.ReplaceAll(code, " ", ""))
+		if err != nil {
+			return nil, err
+		}
+		decoded[strings.ToLower(name)] = b
+	}
+	return decoded, nil
+}
+
+// lookup finds the code for a command by its dotted name. Like dell.LookupCommand, it isn't case sensitive
+func (t *tables) lookup(name string) ([]byte, bool) {
+	code, ok := t.commands[strings.ToLower(name)]
+	return code, ok
+}
+
+// inputByte finds the input byte for one of the names in dell.Commands.Input
+func (t *tables) inputByte(name string) (byte, bool) {
+	for b, input := range t.inputs {
+		if strings.EqualFold(input, name) {
+			return b, true
+		}
+	}
+	return 0, false
+}
+
+// powerState turns the answer to the power query into a dell.PowerState
+func powerState(b byte) dell.PowerState {
+	switch b {
+	case 0:
+		return dell.PowerOff
+	case 1:
+		return dell.PowerOn
+	case 2:
+		return dell.PowerWarmingUp
+	case 3:
+		return dell.PowerCoolingDown
+	}
+	return dell.PowerUnknown
+}
@@ -0,0 +1,278 @@
+// Package stream serves dell's events over HTTP as they happen, either as Server-Sent Events or over a WebSocket,
+// so that dashboards don't need to poll.
+//
+//	http.Handle("/events", stream.New(1000))
+//
+// Clients can narrow down what they get with ?projector=<uuid> and ?event=<name> (both can be repeated, or
+// comma separated). Every event has an ID, and a client that reconnects with a Last-Event-ID header (which
+// browsers send automatically for Server-Sent Events) or ?lastEventId=<id> gets anything it missed in the meantime.
+//
+// IDs start with an epoch that's different every time a Stream is created, so an ID from before a restart isn't
+// mistaken for one from now. If a client asks to carry on from an ID we can't carry on from (it's from another epoch,
+// or older than anything in the history), it's sent a "reset" event (whatever filters it asked for) followed by the
+// whole history, so that it knows it's missed something and should start again
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// ResetEvent is the name of the event a client is sent when it can't carry on from the ID it gave us
+const ResetEvent = "reset"
+
+// Event is a single event, as it's sent to clients
+type Event struct {
+	ID        string         `json:"id"` // The Stream's epoch and a sequence number, e.g. "lxq3b2k1-42"
+	Name      string         `json:"event"`
+	UUID      string         `json:"uuid"`
+	Detail    string         `json:"detail,omitempty"`
+	Time      time.Time      `json:"time"`
+	Projector dell.Projector `json:"projector"`
+
+	seq uint64
+}
+
+// Stream keeps a history of recent events and hands them out to any connected clients
+type Stream struct {
+	lock        sync.Mutex
+	history     []Event
+	size        int
+	epoch       string
+	nextSeq     uint64
+	subscribers map[chan Event]bool
+	stop        func()
+}
+
+// KeepAlive is how often an idle connection is sent something, so that proxies don't hang up on it
+var KeepAlive = 15 * time.Second
+
+// New starts recording events. size is how many events are kept for clients that reconnect
+func New(size int) *Stream {
+	events, stop := dell.Subscribe()
+	s := &Stream{
+		size:        size,
+		epoch:       strconv.FormatInt(time.Now().UnixNano(), 36),
+		nextSeq:     1,
+		subscribers: make(map[chan Event]bool),
+		stop:        stop,
+	}
+
+	go func() {
+		for e := range events {
+			s.publish(e)
+		}
+	}()
+
+	return s
+}
+
+// Close stops recording events
+func (s *Stream) Close() {
+	s.stop()
+}
+
+// publish records an event and passes it on to every client
+func (s *Stream) publish(e dell.EventStruct) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	event := Event{
+		ID:        s.id(s.nextSeq),
+		Name:      e.Name,
+		UUID:      e.ProjectorInfo.UUID,
+		Detail:    e.Detail,
+		Time:      time.Now(),
+		Projector: e.ProjectorInfo,
+		seq:       s.nextSeq,
+	}
+	s.nextSeq++
+
+	s.history = append(s.history, event)
+	if len(s.history) > s.size {
+		s.history = s.history[len(s.history)-s.size:]
+	}
+
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			// This client isn't keeping up. Drop it, and it can catch up from the history when it reconnects
+			delete(s.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// id makes an event ID from a sequence number
+func (s *Stream) id(seq uint64) string {
+	return s.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// subscribe registers a new client. Anything in the history after lastID is returned, so the client can catch up.
+// A client without a lastID gets the whole history, and one whose lastID we can't carry on from gets a reset event
+// followed by the whole history
+func (s *Stream) subscribe(lastID string) (chan Event, []Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// The first event we still have. Everything from here to nextSeq is in the history
+	oldest := s.nextSeq - uint64(len(s.history))
+
+	var missed []Event
+	var after uint64
+	if lastID != "" {
+		epoch, number, _ := strings.Cut(lastID, "-")
+		seq, err := strconv.ParseUint(number, 10, 64)
+		switch {
+		case epoch != s.epoch || err != nil || seq >= s.nextSeq:
+			missed = append(missed, s.reset(oldest, fmt.Sprintf("event %s is from before a restart", lastID)))
+		case seq+1 < oldest:
+			missed = append(missed, s.reset(oldest, fmt.Sprintf("events after %s are no longer in the history", lastID)))
+		default:
+			after = seq
+		}
+	}
+	for _, event := range s.history {
+		if event.seq > after {
+			missed = append(missed, event)
+		}
+	}
+
+	subscriber := make(chan Event, 100)
+	s.subscribers[subscriber] = true
+	return subscriber, missed
+}
+
+// reset makes a reset event, telling a client why it can't carry on from where it was. Its ID comes just before the
+// oldest event in the history, so a client that reconnects with it gets the whole history
+func (s *Stream) reset(oldest uint64, why string) Event {
+	return Event{ID: s.id(oldest - 1), Name: ResetEvent, Detail: why, Time: time.Now(), seq: oldest - 1}
+}
+
+// unsubscribe removes a client
+func (s *Stream) unsubscribe(subscriber chan Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.subscribers[subscriber] {
+		delete(s.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// ServeHTTP serves events over a WebSocket if the client asks for one, and as Server-Sent Events otherwise
+func (s *Stream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f := newFilter(r)
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		s.serveWebSocket(w, r, f, lastID)
+		return
+	}
+	s.serveSSE(w, r, f, lastID)
+}
+
+// serveSSE streams events as Server-Sent Events
+func (s *Stream) serveSSE(w http.ResponseWriter, r *http.Request, f filter, lastID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming isn't supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	subscriber, missed := s.subscribe(lastID)
+	defer s.unsubscribe(subscriber)
+
+	write := func(event Event) bool {
+		if !f.matches(event) {
+			return true
+		}
+		data, _ := json.Marshal(event)
+		_, err := w.Write([]byte("id: " + event.ID + "\nevent: " + event.Name + "\ndata: " + string(data) + "\n\n"))
+		return err == nil
+	}
+
+	for _, event := range missed {
+		if !write(event) {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(KeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := w.Write([]byte(": keep-alive\n\n")); err != nil {
+				return
+			}
+		case event, ok := <-subscriber:
+			if !ok || !write(event) {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// filter holds the ?projector= and ?event= filters a client asked for
+type filter struct {
+	projectors map[string]bool
+	events     map[string]bool
+}
+
+func newFilter(r *http.Request) filter {
+	split := func(values []string) map[string]bool {
+		if len(values) == 0 {
+			return nil
+		}
+		set := make(map[string]bool)
+		for _, v := range values {
+			for _, part := range strings.Split(v, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					set[part] = true
+				}
+			}
+		}
+		return set
+	}
+
+	query := r.URL.Query()
+	return filter{
+		projectors: split(query["projector"]),
+		events:     split(query["event"]),
+	}
+}
+
+// matches tells us whether a client wants an event. Reset events are always wanted
+func (f filter) matches(event Event) bool {
+	if event.Name == ResetEvent {
+		return true
+	}
+	if f.projectors != nil && !f.projectors[event.UUID] {
+		return false
+	}
+	if f.events != nil && !f.events[event.Name] {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package stream
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// websocketGUID is the magic string from RFC 6455 that's used to answer the handshake
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xa
+)
+
+// serveWebSocket upgrades the connection to a WebSocket and sends each event as a JSON text message
+func (s *Stream) serveWebSocket(w http.ResponseWriter, r *http.Request, f filter, lastID string) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websockets aren't supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n\r\n")
+	if buf.Flush() != nil {
+		return
+	}
+
+	ws := &websocket{conn: conn}
+	closed := make(chan bool)
+	go ws.readLoop(buf.Reader, closed)
+
+	subscriber, missed := s.subscribe(lastID)
+	defer s.unsubscribe(subscriber)
+
+	write := func(event Event) bool {
+		if !f.matches(event) {
+			return true
+		}
+		data, _ := json.Marshal(event)
+		return ws.writeFrame(opText, data) == nil
+	}
+
+	for _, event := range missed {
+		if !write(event) {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(KeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-keepAlive.C:
+			if ws.writeFrame(opPing, nil) != nil {
+				return
+			}
+		case event, ok := <-subscriber:
+			if !ok || !write(event) {
+				ws.writeFrame(opClose, nil)
+				return
+			}
+		}
+	}
+}
+
+// websocket is just enough of RFC 6455 to push messages to a client
+type websocket struct {
+	conn net.Conn
+	lock sync.Mutex // Because the read loop writes pongs while we're writing events
+}
+
+// writeFrame writes a single unfragmented frame. Frames from the server aren't masked
+func (ws *websocket) writeFrame(opcode byte, payload []byte) error {
+	ws.lock.Lock()
+	defer ws.lock.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(len(payload)))
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := ws.conn.Write(append(header, payload...))
+	return err
+}
+
+// readLoop reads frames from the client. We don't expect the client to send us anything except pings and a
+// close, so everything else is thrown away. closed is closed when the client goes away
+func (ws *websocket) readLoop(r *bufio.Reader, closed chan bool) {
+	defer close(closed)
+
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		opcode := header[0] & 0x0f
+		masked := header[1]&0x80 != 0
+
+		length := uint64(header[1] & 0x7f)
+		switch length {
+		case 126:
+			extended := make([]byte, 2)
+			if _, err := io.ReadFull(r, extended); err != nil {
+				return
+			}
+			length = uint64(binary.BigEndian.Uint16(extended))
+		case 127:
+			extended := make([]byte, 8)
+			if _, err := io.ReadFull(r, extended); err != nil {
+				return
+			}
+			length = binary.BigEndian.Uint64(extended)
+		}
+		if length > 1<<20 {
+			return
+		}
+
+		mask := make([]byte, 4)
+		if masked {
+			if _, err := io.ReadFull(r, mask); err != nil {
+				return
+			}
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case opClose:
+			ws.writeFrame(opClose, nil)
+			return
+		case opPing:
+			ws.writeFrame(opPong, payload)
+		}
+	}
+}
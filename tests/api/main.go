@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/api"
+	"github.com/Grayda/go-dell/emulator"
+)
+
+// This runs the HTTP API in front of a fake projector and checks every route and the status codes it gives back:
+// good requests, broken bodies, unknown projectors, commands and inputs, a projector that's cooling down, and finally
+// one that's gone offline, which should still be listed and give a 503 rather than a 404
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	server := httptest.NewServer(api.NewHandler())
+	defer server.Close()
+
+	fake := emulator.New("API01")
+	fake.Update(func(s *emulator.State) { s.Power = dell.PowerOn })
+	err = fake.Listen("127.0.0.1:0")
+	if err == nil {
+		_, err = dell.AddProjector(dell.Projector{UUID: "API01", IP: "127.0.0.1", Port: fake.Port()})
+	}
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	defer fake.Close()
+	projector, _ := dell.GetProjector("API01")
+	dell.GetStatus(projector)
+	time.Sleep(300 * time.Millisecond)
+
+	// request makes a request and returns the status code and the decoded body
+	request := func(method string, path string, body string) (int, interface{}) {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		var decoded interface{}
+		data, _ := io.ReadAll(resp.Body)
+		json.Unmarshal(data, &decoded)
+		return resp.StatusCode, decoded
+	}
+	settle := func() { time.Sleep(200 * time.Millisecond) }
+
+	code, body := request("GET", "/projectors", "")
+	list, _ := body.([]interface{})
+	check("GET /projectors lists the projector", code == 200 && len(list) == 1)
+	code, body = request("GET", "/projectors/API01", "")
+	found, _ := body.(map[string]interface{})
+	check("GET /projectors/{uuid} gives its status", code == 200 && found["Online"] == true && found["PowerState"] != nil)
+	code, _ = request("GET", "/projectors/NOPE", "")
+	check("unknown projector is a 404", code == 404)
+	code, _ = request("GET", "/commands", "")
+	check("GET /commands", code == 200)
+	code, body = request("GET", "/openapi.json", "")
+	description, _ := body.(map[string]interface{})
+	check("GET /openapi.json", code == 200 && description["paths"] != nil)
+
+	code, _ = request("POST", "/projectors/API01/commands/Picture.Mute", "")
+	settle()
+	check("POST a command is a 202", code == 202 && fake.State().PictureMuted)
+	code, _ = request("POST", "/projectors/API01/commands/Warp.Drive", "")
+	check("unknown command is a 404", code == 404)
+	code, _ = request("POST", "/projectors/NOPE/commands/Picture.Mute", "")
+	check("command to an unknown projector is a 404", code == 404)
+
+	code, _ = request("PUT", "/projectors/API01/input", `{"input": "VGAA"}`)
+	settle()
+	check("PUT input is a 202", code == 202 && fake.State().Input == "VGAA")
+	code, _ = request("PUT", "/projectors/API01/input", `{"input": "Betamax"}`)
+	check("unknown input is a 400", code == 400)
+	code, _ = request("PUT", "/projectors/API01/input", `{"input":`)
+	check("broken body is a 400", code == 400)
+
+	code, _ = request("PUT", "/projectors/API01/volume", `{"muted": true}`)
+	settle()
+	check("PUT volume is a 202", code == 202 && fake.State().VolumeMuted)
+
+	code, _ = request("PUT", "/projectors/API01/power", `{"on": false}`)
+	check("PUT power off is a 202", code == 202)
+	code, _ = request("PUT", "/projectors/API01/power", `{"on": true}`)
+	check("power on while cooling down is a 409", code == 409)
+
+	// Offline
+	fake.Close()
+	time.Sleep(300 * time.Millisecond)
+	_, connected := dell.GetProjector("API01")
+	check("closing the fake projector drops it", !connected)
+	code, body = request("GET", "/projectors/API01", "")
+	found, _ = body.(map[string]interface{})
+	check("offline projector is a 503, with its last status", code == 503 && found["Online"] == false && found["UUID"] == "API01")
+	code, _ = request("POST", "/projectors/API01/commands/Picture.Unmute", "")
+	check("command to an offline projector is a 503", code == 503)
+	code, _ = request("PUT", "/projectors/API01/power", `{"on": true}`)
+	check("power to an offline projector is a 503", code == 503)
+	code, body = request("GET", "/projectors", "")
+	list, _ = body.([]interface{})
+	check("offline projector is still listed", code == 200 && len(list) == 1 && list[0].(map[string]interface{})["Online"] == false)
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
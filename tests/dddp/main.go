@@ -0,0 +1,179 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+
+	"github.com/Grayda/go-dell/dddp"
+)
+
+// This fuzzes the dddp package. It parses some real beacons and some awkward ones, then throws a lot of random
+// beacons and random bytes at it, checking that:
+//
+//   - Parse never panics, whatever it's given
+//   - Anything Marshal writes, Parse reads back exactly
+//   - Anything Parse accepts can be marshalled and parsed again without changing
+//
+// The random numbers are seeded, so a failure can be repeated with the same -seed
+
+func main() {
+	seed := flag.Int64("seed", 1, "The random seed")
+	rounds := flag.Int("rounds", 100000, "How many random beacons to try")
+	flag.Parse()
+	r := rand.New(rand.NewSource(*seed))
+
+	failed := false
+	fail := func(format string, args ...interface{}) {
+		fmt.Printf("FAIL: "+format+"\n", args...)
+		failed = true
+	}
+
+	examples := []struct {
+		packet string
+		want   dddp.Beacon
+		err    error
+	}{
+		{"AMXB<-SDKClass=VideoProjector><-UUID=DEADBEEF><-Make=DULL><-Model=PROJ01><-Revision=0.2.0>",
+			dddp.Beacon{SDKClass: "VideoProjector", UUID: "DEADBEEF", Make: "DULL", Model: "PROJ01", Revision: "0.2.0"}, nil},
+		{"AMXB<-UUID=GlobalCache_000C1E024239><-SDKClass=Utility><-Make=GlobalCache><-Model=iTachIP2IR><-Revision=710-1005-05><-Pkg_Level=GCPK002><-Config-Name=GlobalCache><-Config-URL=http://192.168.1.70>\r\n",
+			dddp.Beacon{UUID: "GlobalCache_000C1E024239", SDKClass: "Utility", Make: "GlobalCache", Model: "iTachIP2IR", Revision: "710-1005-05",
+				ConfigName: "GlobalCache", ConfigURL: "http://192.168.1.70", Tags: []dddp.Tag{{Name: "Pkg_Level", Value: "GCPK002"}}}, nil},
+		{"AMXB<-UUID=A><-Model=Big > Small><-Make=Dell>\x00\x00",
+			dddp.Beacon{UUID: "A", Model: "Big > Small", Make: "Dell"}, nil},
+		{"AMXB<-UUID=A><-Model=ends in >>", dddp.Beacon{UUID: "A", Model: "ends in >"}, nil},
+		{"AMXB", dddp.Beacon{}, nil},
+		{"NOPE<-UUID=A>", dddp.Beacon{}, dddp.ErrNotDDDP},
+		{"AMXB<-UUID=A", dddp.Beacon{}, dddp.ErrMalformed},
+		{"AMXB<-=A>", dddp.Beacon{}, dddp.ErrMalformed},
+		{"AMXB junk", dddp.Beacon{}, dddp.ErrMalformed},
+	}
+	for _, e := range examples {
+		got, err := dddp.Parse([]byte(e.packet))
+		if err != e.err || !reflect.DeepEqual(got, e.want) {
+			fail("Parse(%q) = %+v, %v. Wanted %+v, %v", e.packet, got, err, e.want, e.err)
+		}
+	}
+
+	// Random beacons should survive a round trip
+	encoded := 0
+	for i := 0; i < *rounds; i++ {
+		b := randomBeacon(r)
+		data, err := dddp.Marshal(b)
+		if err != nil {
+			continue
+		}
+		encoded++
+		got, err := dddp.Parse(data)
+		if err != nil || !reflect.DeepEqual(got, b) {
+			fail("round trip of %+v gave %+v, %v (packet %q)", b, got, err, data)
+		}
+	}
+
+	// Random bytes, and mangled beacons, shouldn't panic, and whatever's accepted should be stable
+	accepted := 0
+	for i := 0; i < *rounds; i++ {
+		var data []byte
+		if i%2 == 0 {
+			data = randomBytes(r)
+		} else {
+			data, _ = dddp.Marshal(randomBeacon(r))
+			data = mangle(r, data)
+		}
+		b, ok := parse(data)
+		if !ok {
+			fail("Parse panicked on %q", data)
+			continue
+		}
+		if b == nil {
+			continue
+		}
+		accepted++
+		again, err := dddp.Marshal(*b)
+		if err != nil {
+			// Parse accepted it, so it has to be possible to write it out again
+			fail("Marshal failed on %+v (parsed from %q): %v", *b, data, err)
+			continue
+		}
+		b2, err := dddp.Parse(again)
+		if err != nil || !reflect.DeepEqual(*b, b2) {
+			fail("%q parsed to %+v, which came back as %+v, %v", data, *b, b2, err)
+		}
+	}
+
+	fmt.Println("Examples:", len(examples), "Round trips:", encoded, "Random packets accepted:", accepted, "of", *rounds)
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// parse calls dddp.Parse, catching any panic. ok is false if it panicked, and b is nil if the packet was rejected
+func parse(data []byte) (b *dddp.Beacon, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	beacon, err := dddp.Parse(data)
+	if err != nil {
+		return nil, true
+	}
+	return &beacon, true
+}
+
+// alphabet is heavy on the characters that matter to DDDP, to give the parser a hard time
+const alphabet = "<->=AMXB \x00\r\nab01"
+
+func randomString(r *rand.Rand, max int) string {
+	b := make([]byte, r.Intn(max+1))
+	for i := range b {
+		b[i] = alphabet[r.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+func randomBeacon(r *rand.Rand) dddp.Beacon {
+	b := dddp.Beacon{
+		UUID:     randomString(r, 6),
+		SDKClass: randomString(r, 6),
+		Make:     randomString(r, 6),
+		Model:    randomString(r, 6),
+		Revision: randomString(r, 6),
+	}
+	if r.Intn(2) == 0 {
+		b.ConfigName, b.ConfigURL = randomString(r, 6), randomString(r, 6)
+	}
+	for i := r.Intn(3); i > 0; i-- {
+		b.Tags = append(b.Tags, dddp.Tag{Name: "X" + randomString(r, 4), Value: randomString(r, 6)})
+	}
+	return b
+}
+
+func randomBytes(r *rand.Rand) []byte {
+	data := []byte(randomString(r, 40))
+	if r.Intn(2) == 0 {
+		data = append([]byte(dddp.Header), data...)
+	}
+	return data
+}
+
+// mangle changes, inserts or removes a few bytes
+func mangle(r *rand.Rand, data []byte) []byte {
+	for i := r.Intn(3) + 1; i > 0 && len(data) > 0; i-- {
+		at := r.Intn(len(data))
+		c := alphabet[r.Intn(len(alphabet))]
+		switch r.Intn(3) {
+		case 0:
+			data[at] = c
+		case 1:
+			data = append(data[:at], append([]byte{c}, data[at:]...)...)
+		case 2:
+			data = append(data[:at], data[at+1:]...)
+		}
+	}
+	return data
+}
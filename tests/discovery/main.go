@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+)
+
+// This tries discovery end to end without multicast, so it runs anywhere (containers, CI sandboxes and so on).
+// A fake projector beacons straight to 127.0.0.1, dell listens with a UnicastSource, and once the projector is found
+// we connect to it and ask for its status. Then a ChannelSource is fed beacons by hand, with no network at all: one for
+// a new projector, one that moves the first projector somewhere that doesn't answer (which should leave it connected
+// where it was), and one that moves it to a second fake projector (which should reconnect it there)
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	events, unsubscribe := dell.Subscribe()
+	defer unsubscribe()
+
+	source, err := dell.UnicastSource("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error listening for beacons:", err)
+		os.Exit(1)
+	}
+	go dell.ListenOn(source)
+	fmt.Println("Listening for beacons on", source.Addr())
+
+	fake := emulator.New("UNICAST")
+	err = fake.Listen("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	defer fake.Close()
+	err = fake.Announce(source.Addr().String(), time.Second)
+	if err != nil {
+		fmt.Println("Error announcing:", err)
+		os.Exit(1)
+	}
+
+	found := waitFor(events, "projectorfound", "UNICAST")
+	fmt.Printf("Found %s at %s:%d\n", found.UUID, found.IP, found.Port)
+	dell.AddProjector(found)
+	projector, _ := dell.GetProjector("UNICAST")
+	dell.GetStatus(projector)
+	time.Sleep(500 * time.Millisecond)
+	projector, _ = dell.GetProjector("UNICAST")
+	fmt.Println("Name:", projector.Name)
+	source.Close()
+
+	channel := dell.NewChannelSource()
+	done := make(chan error)
+	go func() {
+		_, err := dell.ListenOn(channel)
+		done <- err
+	}()
+	channel.Send([]byte("AMXB<-SDKClass=VideoProjector><-UUID=CHANNEL><-Make=Dell><-Model=S300wi><-Revision=1.0>"), "10.0.0.5")
+	byHand := waitFor(events, "projectorfound", "CHANNEL")
+	fmt.Printf("Found %s (%s) at %s\n", byHand.UUID, byHand.Model, byHand.IP)
+
+	// Moving somewhere that doesn't answer keeps the old connection
+	channel.Send([]byte("AMXB<-SDKClass=VideoProjector><-UUID=UNICAST><-Port=1>"), "127.0.0.1")
+	time.Sleep(500 * time.Millisecond)
+	stayed, ok := dell.GetProjector("UNICAST")
+	fmt.Println("After moving to a dead port, still connected on port", stayed.Port, ":", ok && stayed.Connected())
+	kept := ok && stayed.Connected() && stayed.Port == fake.Port()
+
+	// Moving somewhere that does answer reconnects there
+	moved := emulator.New("UNICAST")
+	err = moved.Listen("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	defer moved.Close()
+	channel.Send([]byte(fmt.Sprintf("AMXB<-SDKClass=VideoProjector><-UUID=UNICAST><-Port=%d>", moved.Port())), "127.0.0.1")
+	reconnected := waitFor(events, "projectoradded", "UNICAST")
+	fmt.Println("Reconnected on port", reconnected.Port, "as", reconnected.Name)
+	current, _ := dell.GetProjector("UNICAST")
+	kept = kept && reconnected.Port == moved.Port() && current.Port == moved.Port() && current.Connected()
+
+	channel.Close()
+	err = <-done
+	fmt.Println("ListenOn returned:", err)
+
+	if projector.Name != "D33128" || byHand.IP != "10.0.0.5" || !kept || err != dell.ErrSourceClosed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// waitFor waits for an event about a particular projector
+func waitFor(events chan dell.EventStruct, name string, uuid string) dell.Projector {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if e.Name == name && e.ProjectorInfo.UUID == uuid {
+				return e.ProjectorInfo
+			}
+		case <-timeout:
+			fmt.Println("FAIL: no", name, "event for", uuid)
+			os.Exit(1)
+		}
+	}
+}
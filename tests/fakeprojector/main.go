@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+)
+
+// This runs a fake projector from the emulator package, adds it with dell.AddProjector, asks for its status, then
+// turns it on, changes the input and mutes it, checking that the feedback makes it back into dell.Projectors.
+// It finishes by raising an alarm on the fake projector, which should be pushed to us without asking. The fan failure
+// alarm is moved to another join first, to check that both ends pick up the change. Before all that, a bare TCP client
+// checks that the fake asks it to register, and ignores it until it sends a connect request
+
+func main() {
+	err := dell.SetAlarmJoin("FanFailure", "5c14")
+	if err != nil || dell.SetAlarmJoin("Gremlins", "0000") != dell.ErrUnknownAlarm {
+		fmt.Println("Error changing alarm joins:", err)
+		os.Exit(1)
+	}
+	_, err = dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	dell.WarmUpTime = 500 * time.Millisecond
+
+	fake := emulator.New("FAKE01")
+	fake.WarmUpTime = 500 * time.Millisecond
+	err = fake.Listen("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	defer fake.Close()
+	fmt.Println("Fake projector listening on", fake.Addr())
+
+	registered := unregistered(fake.Addr())
+
+	events, unsubscribe := dell.Subscribe()
+	defer unsubscribe()
+	go func() {
+		for e := range events {
+			fmt.Println("Event:", e.Name, e.Detail)
+		}
+	}()
+
+	_, err = dell.AddProjector(dell.Projector{UUID: "FAKE01", IP: "127.0.0.1", Port: fake.Port()})
+	if err != nil {
+		fmt.Println("Error connecting to fake projector:", err)
+		os.Exit(1)
+	}
+	projector, _ := dell.GetProjector("FAKE01")
+	dell.GetStatus(projector)
+	time.Sleep(500 * time.Millisecond)
+	show()
+
+	fmt.Println("Turning on, switching to VGA-A and muting..")
+	dell.SetPower(projector, true)
+	time.Sleep(time.Second)
+	dell.SetInput(projector, "VGAA")
+	dell.SetMute(projector, true)
+	time.Sleep(500 * time.Millisecond)
+	show()
+
+	fmt.Println("Raising an alarm..")
+	fake.Update(func(s *emulator.State) {
+		s.Alarms |= dell.AlarmFanFailure
+		s.LampHours++
+	})
+	time.Sleep(500 * time.Millisecond)
+	show()
+
+	fmt.Println("Commands received:", fake.Received())
+	projector, _ = dell.GetProjector("FAKE01")
+	if !registered || projector.PowerState != dell.PowerOn || projector.Source != "VGA-A" || !projector.VolumeMuted ||
+		projector.Name != "D33128" || projector.LampHours != 276 || !projector.Alarms.Has(dell.AlarmFanFailure) {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// unregistered connects to the fake projector without registering, and asks for its status. It returns true if the
+// fake asked us to register (0x0f), didn't answer the status request, and then accepted a connect request (0x02)
+func unregistered(addr string) bool {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		fmt.Println("Error connecting to fake projector:", err)
+		return false
+	}
+	defer conn.Close()
+	buf := make([]byte, 4096)
+	read := func() []byte {
+		conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		n, _ := conn.Read(buf)
+		return append([]byte(nil), buf[:n]...)
+	}
+
+	asked := read()
+	status, _ := hex.DecodeString("050005000002031e")
+	conn.Write(status)
+	ignored := read()
+	connect, _ := hex.DecodeString("01000b00000000000340fffff101")
+	conn.Write(connect)
+	accepted := read()
+	fmt.Printf("Unregistered client was sent % x, then % x for a status request, then % x for a connect request\n", asked, ignored, accepted)
+	return len(asked) > 0 && asked[0] == 0x0f && len(ignored) == 0 && len(accepted) > 0 && accepted[0] == 0x02
+}
+
+func show() {
+	p, _ := dell.GetProjector("FAKE01")
+	fmt.Printf("Name: %s, Power: %s, Source: %s, Muted: %t, Lamp: %d hours (%s), Alarms: %s\n", p.Name, p.PowerState, p.Source, p.VolumeMuted, p.LampHours, p.LampMode, p.Alarms)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+)
+
+// This runs a fake projector from the emulator package and makes it misbehave in different ways, checking how dell
+// copes with each one: slow, fragmented and noisy replies, dropped feedback, ignored commands, a projector that hangs,
+// one that resets the connection halfway through a reply and one that won't accept connections at all
+
+var failed bool
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	dell.WarmUpTime = 100 * time.Millisecond
+	dell.CoolDownTime = 100 * time.Millisecond
+
+	fake := emulator.New("FAULTY")
+	err = fake.Listen("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	defer fake.Close()
+
+	fmt.Println("Slow, fragmented replies with a stray packet in front..")
+	fake.SetFaults(emulator.Faults{
+		Latency:       200 * time.Millisecond,
+		FragmentSize:  7,
+		FragmentDelay: time.Millisecond,
+		Garbage:       []byte{0x0f, 0x00, 0x02, 0xde, 0xad}, // A packet type we don't know, which should be skipped
+	})
+	connect(fake)
+	dell.GetStatus(get())
+	time.Sleep(time.Second)
+	check("status still arrives", get().Name == "D33128" && get().PowerState == dell.PowerOff)
+
+	fmt.Println("Ignoring Input.VGAA, and dropping every other packet..")
+	fake.SetFaults(emulator.Faults{Reject: []string{"Input.VGAA"}, DropEvery: 2})
+	dell.SetPower(get(), true)
+	time.Sleep(300 * time.Millisecond)
+	dell.SetInput(get(), "VGAA")
+	time.Sleep(300 * time.Millisecond)
+	check("rejected command leaves the input alone", fake.State().Input == "HDMI" && get().Source == "HDMI")
+
+	fmt.Println("Hanging..")
+	fake.SetFaults(emulator.Faults{Hang: true})
+	fake.Update(func(s *emulator.State) { s.Name = "Hung" })
+	dell.GetStatus(get())
+	time.Sleep(500 * time.Millisecond)
+	check("nothing arrives from a hung projector", get().Name == "D33128" && get().Connected())
+
+	fmt.Println("Resetting halfway through the reply to the next command..")
+	fake.SetFaults(emulator.Faults{})
+	fake.Script(emulator.Step{After: 1, Faults: emulator.Faults{ResetAfter: 10}})
+	dell.SetMute(get(), true)
+	time.Sleep(500 * time.Millisecond)
+	_, connected := dell.GetProjector("FAULTY")
+	check("reset projector is removed", !connected)
+
+	fmt.Println("Refusing connections..")
+	fake.SetFaults(emulator.Faults{Refuse: true})
+	connect(fake)
+	time.Sleep(500 * time.Millisecond)
+	_, connected = dell.GetProjector("FAULTY")
+	check("refused projector is removed", !connected)
+
+	fmt.Println("Commands received:", fake.Received())
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+func connect(fake *emulator.FakeProjector) {
+	_, err := dell.AddProjector(dell.Projector{UUID: "FAULTY", IP: "127.0.0.1", Port: fake.Port()})
+	if err != nil {
+		fmt.Println("Error connecting to fake projector:", err)
+	}
+}
+
+func get() dell.Projector {
+	p, _ := dell.GetProjector("FAULTY")
+	return p
+}
+
+func check(what string, ok bool) {
+	if ok {
+		fmt.Println("  OK:", what)
+		return
+	}
+	fmt.Println("  FAIL:", what)
+	failed = true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+)
+
+// This starts a fleet of fake projectors from a config file (the fleet.json next to this file, by default, wherever
+// it's run from), connects to every one of them and asks for its status, then checks that each projector's name made
+// it into dell.Projectors
+
+func main() {
+	config := flag.String("config", "", "The fleet to start (the fleet.json next to this file, by default)")
+	flag.Parse()
+	if *config == "" {
+		_, source, _, _ := runtime.Caller(0)
+		*config = filepath.Join(filepath.Dir(source), "fleet.json")
+	}
+
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+
+	fleet, err := emulator.LoadFleet(*config)
+	if err != nil {
+		fmt.Println("Error loading fleet:", err)
+		os.Exit(1)
+	}
+	err = fleet.Start()
+	if err != nil {
+		fmt.Println("Error starting fleet:", err)
+		os.Exit(1)
+	}
+	defer fleet.Close()
+	fmt.Println("Started", len(fleet.Projectors), "fake projectors")
+
+	start := time.Now()
+	for i, fake := range fleet.Projectors {
+		host, port, _ := net.SplitHostPort(fleet.Listen[i])
+		portNumber, _ := strconv.Atoi(port)
+		_, err := dell.AddProjector(dell.Projector{UUID: fake.UUID, IP: host, Port: portNumber})
+		if err != nil {
+			fmt.Println("Error connecting to", fake.UUID, ":", err)
+			os.Exit(1)
+		}
+		projector, _ := dell.GetProjector(fake.UUID)
+		dell.GetStatus(projector)
+	}
+	fmt.Println("Connected to every projector in", time.Since(start))
+
+	// Wait for the status dumps to arrive
+	missing := len(fleet.Projectors)
+	for wait := 0; wait < 50 && missing > 0; wait++ {
+		time.Sleep(100 * time.Millisecond)
+		missing = 0
+		for _, fake := range fleet.Projectors {
+			projector, _ := dell.GetProjector(fake.UUID)
+			if projector.Name != fake.State().Name {
+				missing++
+			}
+		}
+	}
+	fmt.Println("Status received from every projector in", time.Since(start))
+
+	lab, _ := dell.GetProjector("LAB3")
+	room, _ := dell.GetProjector("SIM300")
+	fmt.Printf("%s: %s (%s), %s, %s:%d\n", room.UUID, room.Name, room.Location, room.PowerState, room.IP, room.Port)
+	fmt.Printf("%s: %s, %s, %s, %s:%d\n", lab.UUID, lab.Name, lab.PowerState, lab.Source, lab.IP, lab.Port)
+	if missing > 0 || room.Location != "Building 300" || room.IP != "127.0.2.44" || lab.Source != "VGA-A" || lab.PowerState != dell.PowerOn {
+		fmt.Println("FAIL:", missing, "projectors didn't answer")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+	"github.com/Grayda/go-dell/inventory"
+)
+
+// This checks groups and bulk control. Three fake projectors are connected, two of which say they're in Lecture
+// Theatre 2. Groups are made by Location, by a fixed list (including a projector that doesn't exist) and by a tag
+// query on an inventory, and commands sent to each are checked against what the fake projectors received
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	fakes := make(map[string]*emulator.FakeProjector)
+	locations := map[string]string{"LT2A": "Lecture Theatre 2", "LT2B": "lecture theatre 2", "C101": "C101"}
+	for _, uuid := range []string{"C101", "LT2A", "LT2B"} {
+		fake := emulator.New(uuid)
+		fake.Update(func(s *emulator.State) {
+			s.Power = dell.PowerOn
+			s.Location = locations[uuid]
+		})
+		err = fake.Listen("127.0.0.1:0")
+		if err == nil {
+			_, err = dell.AddProjector(dell.Projector{UUID: uuid, IP: "127.0.0.1", Port: fake.Port()})
+		}
+		if err != nil {
+			fmt.Println("Error starting fake projector:", err)
+			os.Exit(1)
+		}
+		defer fake.Close()
+		fakes[uuid] = fake
+		projector, _ := dell.GetProjector(uuid)
+		dell.GetStatus(projector)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	uuids := func(results []dell.Result) []string {
+		var list []string
+		for _, r := range results {
+			list = append(list, r.UUID)
+		}
+		return list
+	}
+
+	// By Location
+	dell.DefineGroup(dell.Group{Name: "LT2", Location: "Lecture Theatre 2"})
+	lt2, err := dell.GetGroup("LT2")
+	check("defined groups can be found", err == nil)
+	report := lt2.SetInput("VGAA")
+	time.Sleep(200 * time.Millisecond)
+	check("location group has both projectors in the room", reflect.DeepEqual(uuids(report.Succeeded()), []string{"LT2A", "LT2B"}))
+	check("report says it all worked", report.OK() && report.Action == "Input.VGAA" && report.Group == "LT2")
+	check("command reached the projectors in the room", fakes["LT2A"].State().Input == "VGAA" && fakes["LT2B"].State().Input == "VGAA")
+	check("command didn't reach the one that isn't", fakes["C101"].State().Input == "HDMI")
+
+	// A fixed list, with a projector we aren't connected to
+	fixed := dell.Group{Name: "fixed", UUIDs: []string{"C101", "GHOST"}}
+	report = fixed.SetMute(true)
+	time.Sleep(200 * time.Millisecond)
+	check("connected projectors succeed", reflect.DeepEqual(uuids(report.Succeeded()), []string{"C101"}))
+	check("missing projectors are offline", reflect.DeepEqual(uuids(report.Offline()), []string{"GHOST"}) && !report.OK())
+	check("mute reached the projector", fakes["C101"].State().VolumeMuted)
+
+	// Failures
+	report = lt2.SetInput("Betamax")
+	check("failures are reported per projector", len(report.Failed()) == 2 && errors.Is(report.Failed()[0].Err, dell.ErrUnknownCommand))
+
+	// Tag queries from an inventory
+	inv := &inventory.Inventory{}
+	inv.Put(inventory.Entry{UUID: "C101", Tags: []string{"building-c"}})
+	inv.Put(inventory.Entry{UUID: "LT2A", Tags: []string{"building-c", "lecture"}})
+	inv.Put(inventory.Entry{UUID: "LT2B", Tags: []string{"building-c", "lecture", "spare"}})
+	inv.Put(inventory.Entry{UUID: "C102", Tags: []string{"building-c"}})
+	buildingC := inv.TagGroup("Building C", "building-c", "!spare")
+	dell.GroupConcurrency = 0 // Treated as one at a time, which should work just as well
+	report = buildingC.SetPictureMute(true)
+	time.Sleep(200 * time.Millisecond)
+	check("tag query picks its projectors", reflect.DeepEqual(uuids(report.Succeeded()), []string{"C101", "LT2A"}))
+	check("tagged projectors we aren't connected to are offline", reflect.DeepEqual(uuids(report.Offline()), []string{"C102"}))
+	check("picture mute reached them", fakes["C101"].State().PictureMuted && fakes["LT2A"].State().PictureMuted && !fakes["LT2B"].State().PictureMuted)
+
+	inv.Put(inventory.Entry{UUID: "C101", Room: "Lecture Theatre 2"})
+	room := inv.RoomGroup("LT2 and friends", "lecture theatre 2")
+	check("room groups use the inventory and Location", reflect.DeepEqual(uuids(room.Do("nothing", func(dell.Projector) error { return nil }).Results), []string{"C101", "LT2A", "LT2B"}))
+
+	_, err = dell.GetGroup("nowhere")
+	check("unknown groups are an error", errors.Is(err, dell.ErrUnknownGroup))
+	check("group names are listed", reflect.DeepEqual(dell.GroupNames(), []string{"LT2"}))
+	dell.RemoveGroup("LT2")
+	check("groups can be removed", len(dell.GroupNames()) == 0)
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
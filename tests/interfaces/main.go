@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+)
+
+// This checks interface-selectable discovery. It looks interfaces up by name and by CIDR, then (if this machine has a
+// multicast-capable interface) runs a fake projector on that interface's address, listens for beacons on just that
+// interface, and checks that the projector is recorded as found on it and connected to through it
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	loopback, err := dell.FindInterface("127.0.0.0/8")
+	check("finds loopback by CIDR", err == nil && loopback.Flags&net.FlagLoopback != 0)
+	if err == nil {
+		byName, err := dell.FindInterface(loopback.Name)
+		check("finds loopback by name", err == nil && byName.Index == loopback.Index)
+	}
+	_, err = dell.FindInterface("198.51.100.0/24")
+	check("doesn't find a network we're not on", errors.Is(err, dell.ErrNoInterface))
+
+	iface, ip := multicastInterface()
+	if iface == "" {
+		fmt.Println("No multicast-capable interface, so skipping discovery")
+	} else {
+		fmt.Println("Discovering on", iface, "("+ip.String()+")")
+		dell.AnnounceInterval = 200 * time.Millisecond
+		events, unsubscribe := dell.Subscribe()
+		defer unsubscribe()
+
+		fake := emulator.New("VLAN10")
+		err = fake.Listen(ip.String() + ":0")
+		if err == nil {
+			err = fake.Announce(emulator.MulticastAddr, 200*time.Millisecond)
+		}
+		if err != nil {
+			fmt.Println("Error starting fake projector:", err)
+			os.Exit(1)
+		}
+		defer fake.Close()
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := dell.ListenInterfaces(iface)
+			errs <- err
+		}()
+
+		timeout := time.After(5 * time.Second)
+	wait:
+		for {
+			select {
+			case err := <-errs:
+				fmt.Println("Error listening:", err)
+				os.Exit(1)
+			case <-timeout:
+				check("projector found", false)
+				break wait
+			case e := <-events:
+				if e.Name != "projectorfound" || e.ProjectorInfo.UUID != "VLAN10" {
+					continue
+				}
+				fmt.Printf("Found %s at %s:%d on %s\n", e.ProjectorInfo.UUID, e.ProjectorInfo.IP, e.ProjectorInfo.Port, e.ProjectorInfo.Interface)
+				check("found on the right interface", e.ProjectorInfo.Interface == iface)
+				_, err := dell.AddProjector(e.ProjectorInfo)
+				p, _ := dell.GetProjector("VLAN10")
+				check("connected through it", err == nil && p.Conn.LocalAddr().(*net.TCPAddr).IP.Equal(ip))
+				break wait
+			}
+		}
+	}
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// multicastInterface finds an interface that's up, can do multicast, isn't loopback and has an IPv4 address
+func multicastInterface() (string, net.IP) {
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok && network.IP.To4() != nil {
+				return iface.Name, network.IP
+			}
+		}
+	}
+	return "", nil
+}
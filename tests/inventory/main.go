@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+	"github.com/Grayda/go-dell/inventory"
+)
+
+// This checks the projector inventory. It reads a hand-written YAML file, writes it back out as YAML and JSON and
+// reads those again, merges discovered projectors into it (including one that clashes with another's address),
+// connects to a fake projector listed in it, and edits the file underneath it to check the changes are picked up
+
+const handWritten = `# Projectors in Building C
+projectors:
+  - uuid: 0005A6123456
+    ip: 10.20.3.14   # The left one
+    name: LT2 Left
+    room: "Lecture Theatre 2"
+    tags: [building-c, lecture]
+  -
+    uuid: LOBBY
+    ip: 10.20.1.5
+    protocol: pjlink
+    room: 'Lobby #1'
+    tags:
+      - building-c
+      - "signage, foyer"
+    credentials: env:LOBBY_PJLINK
+`
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	dir, err := os.MkdirTemp("", "inventory")
+	if err != nil {
+		fmt.Println("Error making a temporary directory:", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(dir)
+
+	// Reading and writing
+	path := filepath.Join(dir, "projectors.yaml")
+	os.WriteFile(path, []byte(handWritten), 0644)
+	inv, err := inventory.Load(path)
+	check("hand-written YAML loads", err == nil)
+	entries := inv.Entries()
+	check("both projectors read", len(entries) == 2)
+	if len(entries) == 2 {
+		check("plain, double quoted and commented values", entries[0].IP == "10.20.3.14" && entries[0].Room == "Lecture Theatre 2")
+		check("flow list of tags", reflect.DeepEqual(entries[0].Tags, []string{"building-c", "lecture"}))
+		check("single quoted value with a #", entries[1].Room == "Lobby #1")
+		check("block list of tags", reflect.DeepEqual(entries[1].Tags, []string{"building-c", "signage, foyer"}))
+		check("credentials reference", entries[1].Credentials == "env:LOBBY_PJLINK" && entries[1].Protocol == "pjlink")
+	}
+
+	os.Setenv("LOBBY_PJLINK", "secret")
+	lobby, _ := inv.Get("LOBBY")
+	projector, err := lobby.Projector()
+	check("credentials resolved into the password", err == nil && projector.Password == "secret")
+	os.Unsetenv("LOBBY_PJLINK")
+	_, err = lobby.Projector()
+	check("missing credentials are an error", err != nil)
+
+	for _, name := range []string{"copy.yaml", "copy.json"} {
+		copyPath := filepath.Join(dir, name)
+		copied := &inventory.Inventory{Path: copyPath}
+		for _, e := range entries {
+			copied.Put(e)
+		}
+		err = copied.Save()
+		reloaded, loadErr := inventory.Load(copyPath)
+		check(name+" round trips", err == nil && loadErr == nil && reflect.DeepEqual(reloaded.Entries(), entries))
+	}
+
+	bad := filepath.Join(dir, "bad.yaml")
+	os.WriteFile(bad, []byte("projectors:\n  - uuid: A\n    colour: red\n"), 0644)
+	_, err = inventory.Load(bad)
+	check("unknown keys are an error with a line number", err != nil && strings.Contains(err.Error(), "line 3"))
+
+	empty, err := inventory.Load(filepath.Join(dir, "new.json"))
+	check("a missing file is an empty inventory", err == nil && len(empty.Entries()) == 0)
+
+	// Merging
+	changed, conflicts := inv.Merge(dell.Projector{UUID: "0005A6123456", IP: "10.20.3.99", Model: "S300wi", Name: "0005A6123456"})
+	merged, _ := inv.Get("0005A6123456")
+	check("merge updates the address and model", changed && len(conflicts) == 0 && merged.IP == "10.20.3.99" && merged.Model == "S300wi")
+	check("merge keeps the name, room and tags", merged.Name == "LT2 Left" && merged.Room == "Lecture Theatre 2" && len(merged.Tags) == 2)
+	changed, _ = inv.Merge(dell.Projector{UUID: "0005A6123456", IP: "10.20.3.99", Model: "S300wi"})
+	check("merging the same thing again changes nothing", !changed)
+
+	changed, conflicts = inv.Merge(dell.Projector{UUID: "0005A6ABCDEF", IP: "10.20.3.99", Name: "LT2 Right"})
+	added, _ := inv.Get("0005A6ABCDEF")
+	check("merge adds new projectors", changed && added.Name == "LT2 Right")
+	check("two UUIDs on one address is a conflict", len(conflicts) == 1 && conflicts[0].Kind == inventory.ConflictSharedIP &&
+		reflect.DeepEqual(conflicts[0].UUIDs, []string{"0005A6123456", "0005A6ABCDEF"}))
+	if len(conflicts) == 1 {
+		fmt.Println("  Conflict:", conflicts[0])
+	}
+
+	// Tracking discovery
+	tracked, _ := inventory.Load(filepath.Join(dir, "tracked.json"))
+	reported := make(chan inventory.Conflict, 10)
+	stopTracking := tracked.Track(func(c inventory.Conflict) { reported <- c })
+	channel := dell.NewChannelSource()
+	go dell.ListenOn(channel)
+	channel.Send([]byte("AMXB<-UUID=TRACK1><-SDKClass=VideoProjector><-Model=S500wi>"), "10.9.0.1")
+	channel.Send([]byte("AMXB<-UUID=TRACK2><-SDKClass=VideoProjector>"), "10.9.0.1")
+	select {
+	case c := <-reported:
+		check("tracking reports conflicts", c.Kind == inventory.ConflictSharedIP && c.Key == "10.9.0.1")
+	case <-time.After(2 * time.Second):
+		check("tracking reports conflicts", false)
+	}
+	stopTracking()
+	channel.Close()
+	saved, err := inventory.Load(filepath.Join(dir, "tracked.json"))
+	check("tracking saves discovered projectors", err == nil && len(saved.Entries()) == 2)
+
+	// Connecting and watching
+	first, second := emulator.New("WATCH1"), emulator.New("WATCH2")
+	for _, fake := range []*emulator.FakeProjector{first, second} {
+		if err := fake.Listen("127.0.0.1:0"); err != nil {
+			fmt.Println("Error starting fake projector:", err)
+			os.Exit(1)
+		}
+		defer fake.Close()
+	}
+	watchPath := filepath.Join(dir, "watched.yaml")
+	write := func(fakes ...*emulator.FakeProjector) {
+		text := "projectors:\n"
+		for _, fake := range fakes {
+			text += "  - uuid: " + fake.UUID + "\n    ip: 127.0.0.1\n    port: " + strconv.Itoa(fake.Port()) + "\n"
+			text += "    name: Projector " + fake.UUID + "\n    room: C101\n"
+		}
+		// Write it somewhere else and move it into place, like most editors do
+		os.WriteFile(watchPath+".tmp", []byte(text), 0644)
+		os.Rename(watchPath+".tmp", watchPath)
+	}
+	write(first)
+
+	watched, _ := inventory.Load(watchPath)
+	err = watched.Connect()
+	projector, connected := dell.GetProjector("WATCH1")
+	check("connects to projectors in the file", err == nil && connected)
+	check("with the name and room from the file", projector.Name == "Projector WATCH1" && projector.Location == "C101")
+
+	reloads := make(chan error, 10)
+	unwatch := watched.Watch(50*time.Millisecond, func(err error) { reloads <- err })
+	defer unwatch()
+	waitReload := func() error {
+		select {
+		case err := <-reloads:
+			return err
+		case <-time.After(2 * time.Second):
+			return fmt.Errorf("no reload")
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond) // So the file's modification time moves on
+	write(second)
+	err = waitReload()
+	_, stillThere := dell.GetProjector("WATCH1")
+	_, nowThere := dell.GetProjector("WATCH2")
+	check("edits are picked up", err == nil && !stillThere && nowThere)
+
+	time.Sleep(20 * time.Millisecond)
+	os.WriteFile(watchPath, []byte("projectors:\n  - uuid WATCH1\n"), 0644)
+	err = waitReload()
+	_, nowThere = dell.GetProjector("WATCH2")
+	check("a broken file is reported and ignored", err != nil && nowThere && len(watched.Entries()) == 1)
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+)
+
+// This checks the discovery lifecycle. A fake projector beacons a few times (which should only raise one
+// "projectorfound"), then turns up at a new IP address (which should raise "projectorupdated" and reconnect us to the
+// new address), then goes quiet (which should raise "projectorlost" after BeaconExpiry announce intervals).
+// Beacons are fed in through a ChannelSource, so no network is needed for discovery. Finally, a projector we're
+// connected to turns up somewhere that doesn't answer, which mustn't hold up beacons from anything else
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	dell.AnnounceInterval = 200 * time.Millisecond
+	dell.BeaconExpiry = 3
+
+	// The same projector, before and after it's been given a new address by DHCP
+	before := emulator.New("MOVER")
+	after := emulator.New("MOVER")
+	after.Update(func(s *emulator.State) { s.Name = "Moved" })
+	for ip, fake := range map[string]*emulator.FakeProjector{"127.0.0.1": before, "127.0.0.2": after} {
+		err = fake.Listen(ip + ":0")
+		if err != nil {
+			fmt.Println("Error starting fake projector:", err)
+			os.Exit(1)
+		}
+		defer fake.Close()
+	}
+
+	events, unsubscribe := dell.Subscribe()
+	defer unsubscribe()
+	counts := make(map[string]int)
+	var countsLock sync.Mutex
+	found := make(chan bool, 1)
+	go func() {
+		for e := range events {
+			if e.Name == "projectorfound" && e.ProjectorInfo.UUID == "BYSTANDER" {
+				found <- true
+			}
+			if e.ProjectorInfo.UUID != "MOVER" {
+				continue
+			}
+			switch e.Name {
+			case "projectorfound":
+				dell.AddProjector(e.ProjectorInfo)
+				p, _ := dell.GetProjector(e.ProjectorInfo.UUID)
+				dell.GetStatus(p)
+				fallthrough
+			case "projectorupdated", "projectorlost":
+				fmt.Printf("%s: %s at %s:%d\n", e.Name, e.ProjectorInfo.UUID, e.ProjectorInfo.IP, e.ProjectorInfo.Port)
+				countsLock.Lock()
+				counts[e.Name]++
+				countsLock.Unlock()
+			}
+		}
+	}()
+
+	source := dell.NewChannelSource()
+	go dell.ListenOn(source)
+
+	fmt.Println("Beaconing from 127.0.0.1..")
+	for i := 0; i < 5; i++ {
+		source.Send(before.Beacon(), "127.0.0.1")
+		time.Sleep(100 * time.Millisecond)
+	}
+	show()
+
+	fmt.Println("Beaconing from 127.0.0.2..")
+	for i := 0; i < 5; i++ {
+		source.Send(after.Beacon(), "127.0.0.2")
+		time.Sleep(100 * time.Millisecond)
+	}
+	show()
+
+	fmt.Println("Going quiet..")
+	time.Sleep(time.Second)
+	_, seen := dell.LastSeen("MOVER")
+	p, _ := dell.GetProjector("MOVER")
+
+	stuck := emulator.New("STUCK")
+	stuck.Listen("127.0.0.1:0")
+	defer stuck.Close()
+	dell.AddProjector(dell.Projector{UUID: "STUCK", IP: "127.0.0.1", Port: stuck.Port()})
+	fmt.Println("Moving STUCK somewhere that doesn't answer..")
+	dell.ConnectTimeout = 3 * time.Second
+	source.Send([]byte("AMXB<-UUID=STUCK><-SDKClass=VideoProjector>"), "192.0.2.1") // TEST-NET-1, which goes nowhere
+	source.Send([]byte("AMXB<-UUID=BYSTANDER><-SDKClass=VideoProjector>"), "127.0.0.3")
+	select {
+	case <-found:
+		fmt.Println("Other beacons carried on while reconnecting")
+	case <-time.After(time.Second):
+		fmt.Println("FAIL: beacons were held up by the reconnect")
+		os.Exit(1)
+	}
+
+	countsLock.Lock()
+	defer countsLock.Unlock()
+	if counts["projectorfound"] != 1 || counts["projectorupdated"] != 1 || counts["projectorlost"] != 1 ||
+		seen || p.IP != "127.0.0.2" || p.Name != "Moved" || after.Clients() != 1 || before.Clients() != 0 {
+		fmt.Println("FAIL:", counts)
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+func show() {
+	p, _ := dell.GetProjector("MOVER")
+	fmt.Printf("Connected to %s at %s:%d\n", p.Name, p.IP, p.Port)
+}
@@ -45,34 +45,28 @@ func main() {
 				fmt.Println("Projector Removed:", msg.ProjectorInfo.UUID)
 			case "projectoradded":
 				fmt.Println("Connected to projector. Sending command to turn on the projector..")
-				// dell.SendCommand(msg.ProjectorInfo, dell.Commands.Power.On)
-				// fmt.Println("Waiting 30 seconds for the projector to turn on..")
-				// time.Sleep(time.Second * 30)
-				//
-				// fmt.Println("Sending command to projector to get status..")
-				// dell.GetStatus(msg.ProjectorInfo)
-				// time.Sleep(time.Second * 3)
-				//
-				// fmt.Println("Sending command to set input to VGA A..")
-				// dell.SendCommand(msg.ProjectorInfo, dell.Commands.Input.VGAA)
-				// fmt.Println("Waiting 3 seconds for the input to change..")
-				// time.Sleep(time.Second * 3)
-				//
-				// fmt.Println("Sending command to set input to VGA B..")
-				// dell.SendCommand(msg.ProjectorInfo, dell.Commands.Input.VGAB)
-				// fmt.Println("Waiting 3 seconds for the input to change..")
-				// time.Sleep(time.Second * 3)
-				//
-				// fmt.Println("Sending command to set input to HDMI..")
+				dell.SendCommand(msg.ProjectorInfo, dell.Commands.Power.On)
 
-				// fmt.Println("Waiting 3 seconds for the input to change..")
-				// time.Sleep(time.Second * 3)
-				//
-				// fmt.Println("Turning the projector off..")
-				for {
-					dell.GetStatus(msg.ProjectorInfo)
-					time.Sleep(time.Second * 10)
-				}
+				// Anything sent while the projector warms up is held back until it's on, so there's no need to wait around
+				fmt.Println("Sending command to set input to HDMI..")
+				dell.SendCommand(msg.ProjectorInfo, dell.Commands.Input.HDMI)
+
+				go func(projector dell.Projector) {
+					for {
+						dell.GetStatus(projector)
+						time.Sleep(time.Second * 10)
+					}
+				}(msg.ProjectorInfo)
+			case "powerstatechanged":
+				fmt.Println("Projector", msg.ProjectorInfo.UUID, "is now:", msg.ProjectorInfo.PowerState)
+			case "lampreplacement":
+				fmt.Println("Projector", msg.ProjectorInfo.UUID, "needs a new lamp soon. Hours remaining:", dell.LampRemaining(msg.ProjectorInfo))
+			case "alarmraised":
+				fmt.Println("Projector", msg.ProjectorInfo.UUID, "raised an alarm:", msg.Detail)
+			case "alarmcleared":
+				fmt.Println("Projector", msg.ProjectorInfo.UUID, "cleared an alarm:", msg.Detail)
+			case "commanddeferred":
+				fmt.Println("Command deferred until the projector has warmed up")
 
 			default:
 
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+	"github.com/Grayda/go-dell/metrics"
+)
+
+// This serves metrics for a fake projector, sends it a command and asks for its status, then scrapes the handler and
+// checks the gauges, counters and the round trip histogram. Last of all it closes the collector and checks that round
+// trips stop being counted, and that a second collector doesn't count them twice
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	collector := metrics.New()
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	fake := emulator.New("METRICS01")
+	fake.Update(func(s *emulator.State) { s.Power = dell.PowerOn })
+	err = fake.Listen("127.0.0.1:0")
+	if err == nil {
+		_, err = dell.AddProjector(dell.Projector{UUID: "METRICS01", IP: "127.0.0.1", Port: fake.Port(), Model: "S300wi"})
+	}
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	defer fake.Close()
+	projector, _ := dell.GetProjector("METRICS01")
+	dell.GetStatus(projector)
+	time.Sleep(300 * time.Millisecond)
+	dell.SetMute(projector, true)
+	time.Sleep(300 * time.Millisecond)
+
+	scraped, contentType := scrape(server.URL)
+	check("served as the Prometheus text format", strings.HasPrefix(contentType, "text/plain; version=0.0.4"))
+	check("every metric has a TYPE line", scraped.types["dell_projector_power_on"] == "gauge" &&
+		scraped.types["dell_commands_sent_total"] == "counter" && scraped.types["dell_command_round_trip_seconds"] == "histogram")
+
+	labels := `{uuid="METRICS01",model="S300wi",name="D33128",location=""}`
+	check("connected gauge", scraped.values["dell_projector_connected"+labels] == 1)
+	check("power gauges", scraped.values["dell_projector_power_on"+labels] == 1 &&
+		scraped.values["dell_projector_power_state"+labels] == float64(dell.PowerOn))
+	check("lamp hours gauge", scraped.values["dell_projector_lamp_hours"+labels] == 275)
+	check("volume muted gauge", scraped.values["dell_projector_volume_muted"+labels] == 1)
+	check("alarm gauge", scraped.values[`dell_projector_alarm{uuid="METRICS01",model="S300wi",name="D33128",location="",alarm="FanFailure"}`] == 0)
+	check("commands sent counter", scraped.values[`dell_commands_sent_total{uuid="METRICS01"}`] == 1)
+
+	count := scraped.values[`dell_command_round_trip_seconds_count{uuid="METRICS01"}`]
+	check("round trips are counted", count >= 2)
+	check("the +Inf bucket matches the count", scraped.values[`dell_command_round_trip_seconds_bucket{uuid="METRICS01",le="+Inf"}`] == count)
+	cumulative, previous := true, 0.0
+	for _, bound := range metrics.Buckets {
+		value := scraped.values[`dell_command_round_trip_seconds_bucket{uuid="METRICS01",le="`+strconv.FormatFloat(bound, 'g', -1, 64)+`"}`]
+		cumulative = cumulative && value >= previous && value <= count
+		previous = value
+	}
+	check("buckets are cumulative", cumulative)
+	check("the sum is there", scraped.values[`dell_command_round_trip_seconds_sum{uuid="METRICS01"}`] > 0)
+
+	// Closing stops round trips being counted, and another collector only counts them once
+	collector.Close()
+	second := metrics.New()
+	defer second.Close()
+	secondServer := httptest.NewServer(second)
+	defer secondServer.Close()
+	dell.GetStatus(projector)
+	time.Sleep(300 * time.Millisecond)
+	after, _ := scrape(server.URL)
+	check("a closed collector stops counting round trips", after.values[`dell_command_round_trip_seconds_count{uuid="METRICS01"}`] == count)
+	fresh, _ := scrape(secondServer.URL)
+	check("a new collector counts each round trip once", fresh.values[`dell_command_round_trip_seconds_count{uuid="METRICS01"}`] == 1)
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// metricSet is a scrape, with each sample keyed by its name and labels
+type metricSet struct {
+	types  map[string]string
+	values map[string]float64
+}
+
+// scrape fetches the metrics and parses the text format
+func scrape(url string) (metricSet, string) {
+	resp, err := http.Get(url)
+	if err != nil {
+		fmt.Println("Error scraping metrics:", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	set := metricSet{types: make(map[string]string), values: make(map[string]float64)}
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# TYPE ") {
+			fields := strings.Fields(line)
+			set.types[fields[2]] = fields[3]
+			continue
+		}
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			fmt.Println("  FAIL: can't parse", line)
+			continue
+		}
+		set.values[line[:i]] = value
+	}
+	return set, resp.Header.Get("Content-Type")
+}
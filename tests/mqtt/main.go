@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+	"github.com/Grayda/go-dell/mqtt"
+)
+
+// This runs the MQTT bridge against the in-process broker, with a fake projector behind it. It checks the Home
+// Assistant discovery configs, sends a command through a command topic and watches it reach the projector (and its
+// new state come back), checks that a bad command is reported on the error topic, and then cuts the bridge's
+// connection to check that the broker publishes its will. The bridge connects through a small proxy, so the connection
+// can be cut without the bridge saying goodbye
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	fake := emulator.New("MQTT01")
+	fake.Update(func(s *emulator.State) { s.Power = dell.PowerOn })
+	err = fake.Listen("127.0.0.1:0")
+	if err == nil {
+		_, err = dell.AddProjector(dell.Projector{UUID: "MQTT01", IP: "127.0.0.1", Port: fake.Port()})
+	}
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	defer fake.Close()
+	projector, _ := dell.GetProjector("MQTT01")
+	dell.GetStatus(projector)
+	time.Sleep(300 * time.Millisecond)
+
+	broker := mqtt.NewBroker()
+	err = broker.Listen("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error starting broker:", err)
+		os.Exit(1)
+	}
+	defer broker.Close()
+	proxy, cut := startProxy(broker.Addr())
+
+	bridge := mqtt.NewBridge(&mqtt.Client{ClientID: "go-dell"})
+	err = bridge.Start(proxy)
+	if err != nil {
+		fmt.Println("Error starting bridge:", err)
+		os.Exit(1)
+	}
+
+	retained := func(topic string) (map[string]interface{}, bool) {
+		m, ok := broker.Retained(topic)
+		var v map[string]interface{}
+		return v, ok && json.Unmarshal(m.Payload, &v) == nil
+	}
+	waitFor := func(ok func() bool) bool {
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+			if ok() {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Discovery
+	var power map[string]interface{}
+	check("power switch discovery config", waitFor(func() bool {
+		var ok bool
+		power, ok = retained("homeassistant/switch/dell_MQTT01/power/config")
+		return ok
+	}) && power["command_topic"] == "dell/MQTT01/power/set" && power["unique_id"] == "dell_MQTT01_power")
+	input, ok := retained("homeassistant/select/dell_MQTT01/input/config")
+	check("input select discovery config", ok && input["command_topic"] == "dell/MQTT01/input/set" && fmt.Sprint(input["options"]) == fmt.Sprint(dell.InputNames()))
+	for _, path := range []string{"switch/dell_MQTT01/mute", "sensor/dell_MQTT01/lamp_hours", "binary_sensor/dell_MQTT01/problem"} {
+		_, ok = retained("homeassistant/" + path + "/config")
+		check(path+" discovery config", ok)
+	}
+	status, ok := broker.Retained("dell/status")
+	check("bridge is online", ok && string(status.Payload) == "online")
+
+	// Commands
+	observer := &mqtt.Client{ClientID: "observer"}
+	err = observer.Connect(broker.Addr())
+	if err != nil {
+		fmt.Println("Error connecting observer:", err)
+		os.Exit(1)
+	}
+	defer observer.Close()
+	errorsSeen := make(chan string, 10)
+	observer.Subscribe("dell/+/error", func(m mqtt.Message) { errorsSeen <- string(m.Payload) })
+
+	observer.Publish(mqtt.Message{Topic: "dell/MQTT01/input/set", Payload: []byte("VGAA")})
+	check("command topic reaches the projector", waitFor(func() bool { return fake.State().Input == "VGAA" }))
+	check("and the new state is published", waitFor(func() bool {
+		state, ok := retained("dell/MQTT01/state")
+		return ok && state["input"] == "VGAA"
+	}))
+
+	observer.Publish(mqtt.Message{Topic: "dell/MQTT01/command", Payload: []byte("Warp.Drive")})
+	select {
+	case text := <-errorsSeen:
+		fmt.Println("  Error topic:", text)
+		check("failed commands are published to the error topic", true)
+	case <-time.After(2 * time.Second):
+		check("failed commands are published to the error topic", false)
+	}
+
+	// The will
+	cut()
+	check("broker publishes the bridge's will when it disappears", waitFor(func() bool {
+		status, ok := broker.Retained("dell/status")
+		return ok && string(status.Payload) == "offline"
+	}))
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// startProxy passes connections through to addr. It returns the proxy's address, and a function that drops every
+// connection without warning
+func startProxy(addr string) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error starting proxy:", err)
+		os.Exit(1)
+	}
+	var conns []net.Conn
+	var lock sync.Mutex
+	go func() {
+		for {
+			client, err := l.Accept()
+			if err != nil {
+				return
+			}
+			server, err := net.Dial("tcp", addr)
+			if err != nil {
+				client.Close()
+				continue
+			}
+			lock.Lock()
+			conns = append(conns, client, server)
+			lock.Unlock()
+			go io.Copy(server, client)
+			go io.Copy(client, server)
+		}
+	}()
+	return l.Addr().String(), func() {
+		lock.Lock()
+		defer lock.Unlock()
+		l.Close()
+		for _, c := range conns {
+			c.Close()
+		}
+	}
+}
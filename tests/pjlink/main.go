@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/pjlink"
+)
+
+// This connects to a Dell projector (or the emulator) over CIP, puts the PJLink gateway in front of it, then connects
+// to the gateway with the PJLink driver. Both copies of the projector should end up with the same status.
+//
+// Usage: go run tests/pjlink/main.go <projector IP>
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println("Usage: go run tests/pjlink/main.go <projector IP>")
+		os.Exit(2)
+	}
+
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+
+	_, err = dell.AddProjector(dell.Projector{UUID: "cip", IP: os.Args[1]})
+	if err != nil {
+		fmt.Println("Error connecting to projector:", err)
+		os.Exit(1)
+	}
+	projector, _ := dell.GetProjector("cip")
+	dell.GetStatus(projector)
+	time.Sleep(2 * time.Second)
+
+	server := &pjlink.Server{UUID: "cip", Password: "dell"}
+	err = server.Listen("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error starting the PJLink gateway:", err)
+		os.Exit(1)
+	}
+	fmt.Println("PJLink gateway listening on", server.Addr())
+
+	_, port, _ := net.SplitHostPort(server.Addr())
+	portNumber, _ := strconv.Atoi(port)
+	_, err = dell.AddProjector(dell.Projector{UUID: "pjlink", IP: "127.0.0.1", Port: portNumber, Protocol: pjlink.Protocol, Password: "dell"})
+	if err != nil {
+		fmt.Println("Error connecting to the PJLink gateway:", err)
+		os.Exit(1)
+	}
+	time.Sleep(2 * time.Second)
+
+	for _, uuid := range []string{"cip", "pjlink"} {
+		p, _ := dell.GetProjector(uuid)
+		fmt.Printf("%-6s Name: %s, Power: %s, Source: %s, Lamp hours: %d, Alarms: %s\n", uuid, p.Name, p.PowerState, p.Source, p.LampHours, p.Alarms)
+	}
+
+	fmt.Println("Turning the projector on through PJLink..")
+	p, _ := dell.GetProjector("pjlink")
+	_, err = dell.SetPower(p, true)
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+	"github.com/Grayda/go-dell/pjlink"
+)
+
+// This puts the PJLink gateway in front of a fake projector and talks to it as a PJLink client would, with nothing
+// but a TCP connection. It checks that a wrong password is refused, that the right one is accepted, and that POWR,
+// INPT, AVMT, LAMP, ERST and the INF commands reach the projector and report what it says
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	fake := emulator.New("PJ01")
+	fake.Update(func(s *emulator.State) { s.Power = dell.PowerOn })
+	fake.CoolDownTime = 100 * time.Millisecond
+	err = fake.Listen("127.0.0.1:0")
+	if err == nil {
+		_, err = dell.AddProjector(dell.Projector{UUID: "PJ01", IP: "127.0.0.1", Port: fake.Port(), Make: "Dell", Model: "S300wi"})
+	}
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	defer fake.Close()
+	projector, _ := dell.GetProjector("PJ01")
+	dell.GetStatus(projector)
+	time.Sleep(300 * time.Millisecond)
+
+	gateway := pjlink.NewGateway()
+	gateway.Password = "dell"
+	gateway.Addresses["PJ01"] = "127.0.0.1:0"
+	err = gateway.Start()
+	if err != nil {
+		fmt.Println("Error starting gateway:", err)
+		os.Exit(1)
+	}
+	defer gateway.Stop()
+	addr := gateway.Addr("PJ01")
+
+	// A wrong password is refused
+	conn, r, random := dial(addr)
+	check("gateway asks for a password", random != "")
+	fmt.Fprint(conn, digest(random, "wrong")+"%1POWR ?\r")
+	reply, _ := r.ReadString('\r')
+	check("wrong password is refused", reply == "PJLINK ERRA\r")
+	conn.Close()
+
+	// The right one is accepted, and only needed on the first command
+	conn, r, random = dial(addr)
+	defer conn.Close()
+	first := true
+	send := func(command string) string {
+		if first {
+			command = digest(random, "dell") + command
+			first = false
+		}
+		fmt.Fprint(conn, command+"\r")
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		reply, err := r.ReadString('\r')
+		if err != nil {
+			return err.Error()
+		}
+		return strings.TrimSuffix(reply, "\r")
+	}
+	settle := func() { time.Sleep(200 * time.Millisecond) }
+
+	check("right password is accepted, POWR ? says on", send("%1POWR ?") == "%1POWR=1")
+
+	check("INPT 11 accepted", send("%1INPT 11") == "%1INPT=OK")
+	settle()
+	check("INPT reached the projector", fake.State().Input == "VGAA")
+	check("INPT ? reports it", send("%1INPT ?") == "%1INPT=11")
+	check("INPT with an unknown input", send("%1INPT 99") == "%1INPT=ERR2")
+
+	check("AVMT 31 accepted", send("%1AVMT 31") == "%1AVMT=OK")
+	settle()
+	check("AVMT reached the projector", fake.State().PictureMuted && fake.State().VolumeMuted)
+	check("AVMT ? reports it", send("%1AVMT ?") == "%1AVMT=31")
+
+	check("LAMP ? reports hours and lamp on", send("%1LAMP ?") == "%1LAMP=275 1")
+
+	check("ERST ? with no alarms", send("%1ERST ?") == "%1ERST=000000")
+	fake.Update(func(s *emulator.State) { s.Alarms |= dell.AlarmFanFailure })
+	settle()
+	check("ERST ? reports a fan failure", send("%1ERST ?") == "%1ERST=200000")
+
+	check("INF1 ? is the make", send("%1INF1 ?") == "%1INF1=Dell")
+	check("INF2 ? is the model", send("%1INF2 ?") == "%1INF2=S300wi")
+	check("INFO ? answers", strings.HasPrefix(send("%1INFO ?"), "%1INFO="))
+	check("NAME ? is the projector's name", send("%1NAME ?") == "%1NAME=D33128")
+	check("unknown commands", send("%1WHAT ?") == "%1WHAT=ERR1")
+
+	check("POWR 0 accepted", send("%1POWR 0") == "%1POWR=OK")
+	settle()
+	check("POWR reached the projector", fake.State().Power != dell.PowerOn)
+	check("POWR ? says cooling down", send("%1POWR ?") == "%1POWR=2")
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// dial connects to a PJLink server and reads its greeting. random is the number to make the digest from, or an empty
+// string if the server doesn't want a password
+func dial(addr string) (net.Conn, *bufio.Reader, string) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		fmt.Println("Error connecting to gateway:", err)
+		os.Exit(1)
+	}
+	r := bufio.NewReader(conn)
+	greeting, _ := r.ReadString('\r')
+	return conn, r, strings.TrimPrefix(strings.TrimSuffix(greeting, "\r"), "PJLINK 1 ")
+}
+
+// digest is the MD5 of the server's random number and the password, as the PJLink specification describes
+func digest(random string, password string) string {
+	sum := md5.Sum([]byte(random + password))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+)
+
+// This runs the power-off policies against fake projectors, with timers cut down to fractions of a second. A projector
+// with no input signal should be warned and then turned off, while one with a signal is left alone. Without a
+// MessageJoin the warning can't be shown, so the policies shouldn't start while one has a Message. With one, the
+// (overly long) message should reach the projector cut down to size, and a signal turning up again should cancel the
+// power off. Last of all, a curfew should turn off a projector whether it has a signal or not
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	dell.PolicyInterval = 100 * time.Millisecond
+	dell.DefaultPolicy = dell.Policy{
+		IdleTimeout: 300 * time.Millisecond,
+		Warning:     300 * time.Millisecond,
+		Message:     "This projector will turn off soon",
+	}
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	// Every event, so we can look back through them
+	var seenLock sync.Mutex
+	var seen []dell.EventStruct
+	events, unsubscribe := dell.Subscribe()
+	defer unsubscribe()
+	go func() {
+		for e := range events {
+			seenLock.Lock()
+			seen = append(seen, e)
+			seenLock.Unlock()
+		}
+	}()
+	// raised looks for an event for a projector, and returns its Detail
+	raised := func(name string, uuid string) (string, bool) {
+		seenLock.Lock()
+		defer seenLock.Unlock()
+		for _, e := range seen {
+			if e.Name == name && e.ProjectorInfo.UUID == uuid {
+				return e.Detail, true
+			}
+		}
+		return "", false
+	}
+	waitFor := func(ok func() bool) bool {
+		for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+			if ok() {
+				return true
+			}
+		}
+		return false
+	}
+	waitForEvent := func(name string, uuid string) (string, bool) {
+		var detail string
+		ok := waitFor(func() bool {
+			var ok bool
+			detail, ok = raised(name, uuid)
+			return ok
+		})
+		return detail, ok
+	}
+
+	fakes := make(map[string]*emulator.FakeProjector)
+	resolutions := map[string]string{"IDLE": "", "BUSY": "1280 x 800"}
+	for _, uuid := range []string{"IDLE", "BUSY"} {
+		fake := emulator.New(uuid)
+		fake.Update(func(s *emulator.State) {
+			s.Power = dell.PowerOn
+			s.Resolution = resolutions[uuid]
+		})
+		err = fake.Listen("127.0.0.1:0")
+		if err == nil {
+			_, err = dell.AddProjector(dell.Projector{UUID: uuid, IP: "127.0.0.1", Port: fake.Port()})
+		}
+		if err != nil {
+			fmt.Println("Error starting fake projector:", err)
+			os.Exit(1)
+		}
+		defer fake.Close()
+		fakes[uuid] = fake
+		projector, _ := dell.GetProjector(uuid)
+		dell.GetStatus(projector)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	busy, _ := dell.GetProjector("BUSY")
+	idle, _ := dell.GetProjector("IDLE")
+	check("a projector showing a resolution has a signal", dell.HasSignal(busy))
+	check("one without doesn't, even though it has an input selected", !dell.HasSignal(idle) && idle.Source != "")
+	_, err = dell.ShowMessage(busy, "Hello")
+	check("messages can't be shown without a MessageJoin", errors.Is(err, dell.ErrNoMessageJoin))
+
+	// Policies with a message won't start without a MessageJoin
+	started, err := dell.StartPolicies()
+	check("policies with a Message don't start without a MessageJoin", !started && errors.Is(err, dell.ErrNoMessageJoin))
+
+	// Idle projectors are turned off
+	dell.DefaultPolicy.Message = ""
+	started, err = dell.StartPolicies()
+	check("policies without a Message start", started && err == nil)
+	detail, ok := waitForEvent("poweroffwarning", "IDLE")
+	check("projector with no signal is warned", ok && detail == "idle")
+	_, ok = raised("messagefailed", "IDLE")
+	check("and no message is attempted", !ok)
+	_, ok = waitForEvent("autopoweroff", "IDLE")
+	check("and it's turned off once the warning has passed", ok)
+	check("the power off reached the projector", waitFor(func() bool { return fakes["IDLE"].State().Power != dell.PowerOn }))
+	_, ok = raised("poweroffwarning", "BUSY")
+	check("projector with a signal is left alone", !ok && fakes["BUSY"].State().Power == dell.PowerOn)
+	dell.StopPolicies()
+
+	// Messages, and cancelling the power off when the signal comes back
+	dell.MessageJoin = "13c0"
+	fakes["BUSY"].Update(func(s *emulator.State) { s.Resolution = "" })
+	dell.SetPolicy("BUSY", dell.Policy{
+		IdleTimeout: 300 * time.Millisecond,
+		Warning:     10 * time.Second,
+		Message:     strings.Repeat("Turning off ", 30),
+	})
+	dell.StartPolicies()
+	_, ok = waitForEvent("poweroffwarning", "BUSY")
+	check("projector that loses its signal is warned", ok)
+	check("the message reaches the projector, cut down to 251 bytes", waitFor(func() bool {
+		return fakes["BUSY"].State().Message == strings.Repeat("Turning off ", 30)[:251]
+	}))
+	fakes["BUSY"].Update(func(s *emulator.State) { s.Resolution = "1920 x 1080" })
+	_, ok = waitForEvent("poweroffcancelled", "BUSY")
+	check("the power off is cancelled when the signal comes back", ok && fakes["BUSY"].State().Power == dell.PowerOn)
+	dell.StopPolicies()
+
+	// Curfews turn projectors off regardless
+	now := time.Now()
+	dell.SetPolicy("BUSY", dell.Policy{
+		Curfew:    now.Add(-time.Minute).Format("15:04"),
+		CurfewEnd: now.Add(2 * time.Minute).Format("15:04"),
+		Warning:   300 * time.Millisecond,
+	})
+	dell.StartPolicies()
+	detail, ok = waitForEvent("autopoweroff", "BUSY")
+	check("curfew turns off a projector with a signal", ok && detail == "curfew")
+	check("the power off reached the projector", waitFor(func() bool { return fakes["BUSY"].State().Power != dell.PowerOn }))
+	dell.StopPolicies()
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
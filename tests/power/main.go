@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+)
+
+// This checks the power state machine on a projector we've only just added, before it's told us anything about its
+// power. A command sent straight after Power.On should be held back until the projector has warmed up, and a
+// Power.On sent straight after Power.Off should be refused while it cools down
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	dell.WarmUpTime = 500 * time.Millisecond
+	dell.CoolDownTime = 500 * time.Millisecond
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	fake := emulator.New("POWER01")
+	fake.WarmUpTime = 100 * time.Millisecond
+	fake.CoolDownTime = 100 * time.Millisecond
+	err = fake.Listen("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	defer fake.Close()
+
+	events, unsubscribe := dell.Subscribe()
+	defer unsubscribe()
+	deferred := make(chan string, 10)
+	go func() {
+		for e := range events {
+			if e.Name == "commanddeferred" {
+				deferred <- e.Detail
+			}
+		}
+	}()
+
+	_, err = dell.AddProjector(dell.Projector{UUID: "POWER01", IP: "127.0.0.1", Port: fake.Port()})
+	if err != nil {
+		fmt.Println("Error connecting to fake projector:", err)
+		os.Exit(1)
+	}
+	projector, _ := dell.GetProjector("POWER01")
+	check("a fresh projector's power is unknown", projector.PowerState == dell.PowerUnknown)
+
+	dell.SetPower(projector, true)
+	projector, _ = dell.GetProjector("POWER01")
+	check("Power.On on an unknown projector starts it warming up", projector.PowerState == dell.PowerWarmingUp)
+
+	ok, err := dell.SetInput(projector, "VGAA")
+	check("a command straight after Power.On is accepted", ok && err == nil)
+	select {
+	case <-deferred:
+		check("and deferred", true)
+	case <-time.After(time.Second):
+		check("and deferred", false)
+	}
+	time.Sleep(200 * time.Millisecond)
+	check("it isn't sent while warming up", reflect.DeepEqual(fake.Received(), []string{"Power.On"}))
+
+	time.Sleep(time.Second)
+	projector, _ = dell.GetProjector("POWER01")
+	check("it's on once warmed up", projector.PowerState == dell.PowerOn)
+	check("the deferred command is sent then", reflect.DeepEqual(fake.Received(), []string{"Power.On", "Input.VGAA"}))
+
+	// The same in the other direction, on another fresh projector
+	dell.RemoveProjector(projector)
+	dell.AddProjector(dell.Projector{UUID: "POWER01", IP: "127.0.0.1", Port: fake.Port()})
+	projector, _ = dell.GetProjector("POWER01")
+	dell.SetPower(projector, false)
+	projector, _ = dell.GetProjector("POWER01")
+	check("Power.Off on an unknown projector starts it cooling down", projector.PowerState == dell.PowerCoolingDown)
+	_, err = dell.SetPower(projector, true)
+	check("Power.On while cooling down is refused", err == dell.ErrCoolingDown)
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
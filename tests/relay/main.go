@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+	"github.com/Grayda/go-dell/relay"
+)
+
+// This checks the beacon relay. A fake projector on 127.0.0.2 beacons to a relay with AddOrigin set, which passes the
+// beacons on to where dell is listening. As the relay is one of dell.TrustedRelays, dell should find the projector at
+// 127.0.0.2 (not at the relay) and be able to connect to it. It also checks that beacons are rate limited, that a
+// relay without AddOrigin passes beacons on unchanged, that an Origin from anywhere else is ignored, and that two
+// relays pointed at each other don't pass a beacon back and forth
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	listener, err := dell.UnicastSource("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error listening for beacons:", err)
+		os.Exit(1)
+	}
+	source, err := dell.UnicastSource("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error listening for beacons to relay:", err)
+		os.Exit(1)
+	}
+	events, unsubscribe := dell.Subscribe()
+	defer unsubscribe()
+	dell.TrustedRelays = []string{"127.0.0.1"}
+	go dell.ListenOn(listener)
+
+	r := &relay.Relay{Source: source, Targets: []string{listener.Addr().String()}, MinInterval: 300 * time.Millisecond,
+		LoopWindow: 100 * time.Millisecond, AddOrigin: true}
+	go r.Run()
+
+	fake := emulator.New("RELAYED")
+	err = fake.Listen("127.0.0.2:0")
+	if err == nil {
+		err = fake.Announce(source.Addr().String(), 50*time.Millisecond)
+	}
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	started := time.Now()
+
+	var found dell.Projector
+	timeout := time.After(5 * time.Second)
+wait:
+	for {
+		select {
+		case <-timeout:
+			break wait
+		case e := <-events:
+			if e.Name == "projectorfound" && e.ProjectorInfo.UUID == "RELAYED" {
+				found = e.ProjectorInfo
+				break wait
+			}
+		}
+	}
+	check("relayed projector found", found.UUID == "RELAYED")
+	check("found at the projector's address, not the relay's", found.IP == "127.0.0.2")
+	check("found on the projector's port", found.Port == fake.Port())
+
+	if found.UUID != "" {
+		_, err = dell.AddProjector(found)
+		check("connects to the relayed projector", err == nil)
+		if err == nil {
+			added, _ := dell.GetProjector("RELAYED")
+			dell.RemoveProjector(added)
+		}
+	}
+
+	// The fake beacons every 50ms, but the relay only passes one on every 300ms
+	time.Sleep(time.Second - time.Since(started))
+	fake.Close()
+	time.Sleep(100 * time.Millisecond)
+	relayed, dropped := r.Stats()
+	fmt.Println("Relayed", relayed, "and dropped", dropped, "in a second")
+	check("beacons are rate limited per projector", relayed >= 2 && relayed <= 5 && dropped >= 10)
+
+	r.Close()
+
+	// Without AddOrigin, beacons are passed on exactly as they arrived
+	received, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error listening for relayed beacons:", err)
+		os.Exit(1)
+	}
+	defer received.Close()
+	plain := dell.NewChannelSource()
+	unchanged := &relay.Relay{Source: plain, Targets: []string{received.LocalAddr().String()}}
+	go unchanged.Run()
+	beacon := []byte("AMXB<-UUID=PLAIN><-SDKClass=VideoProjector><-Make=Dell>\x00\x00")
+	time.Sleep(50 * time.Millisecond)
+	plain.Send(beacon, "10.0.0.9")
+	buf := make([]byte, 1024)
+	received.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, _ := received.ReadFrom(buf)
+	check("beacons are relayed unchanged", string(buf[:n]) == string(beacon))
+	unchanged.Close()
+
+	// An Origin from something that isn't a trusted relay is ignored
+	untrusted := dell.NewChannelSource()
+	go dell.ListenOn(untrusted)
+	untrusted.Send([]byte("AMXB<-UUID=FORGED><-SDKClass=VideoProjector><-"+relay.OriginTag+"=10.66.66.66>"), "10.0.0.7")
+	forged := dell.Projector{}
+	timeout = time.After(time.Second)
+forgery:
+	for {
+		select {
+		case <-timeout:
+			break forgery
+		case e := <-events:
+			if e.Name == "projectorfound" && e.ProjectorInfo.UUID == "FORGED" {
+				forged = e.ProjectorInfo
+				break forgery
+			}
+		}
+	}
+	check("Origin from an untrusted address is ignored", forged.IP == "10.0.0.7")
+	untrusted.Close()
+
+	// Two relays pointed at each other. MinInterval is next to nothing, so only the loop check can stop them
+	a, _ := dell.UnicastSource("127.0.0.1:0")
+	b, _ := dell.UnicastSource("127.0.0.1:0")
+	relayA := &relay.Relay{Source: a, Targets: []string{b.Addr().String()}, MinInterval: time.Nanosecond, AddOrigin: true}
+	relayB := &relay.Relay{Source: b, Targets: []string{a.Addr().String()}, MinInterval: time.Nanosecond}
+	go relayA.Run()
+	go relayB.Run()
+	time.Sleep(50 * time.Millisecond)
+	conn, err := net.Dial("udp4", a.Addr().String())
+	if err == nil {
+		conn.Write([]byte("AMXB<-UUID=LOOPED><-SDKClass=VideoProjector>"))
+		conn.Close()
+	}
+	time.Sleep(300 * time.Millisecond)
+	relayedA, droppedA := relayA.Stats()
+	relayedB, droppedB := relayB.Stats()
+	fmt.Println("Looping relays relayed", relayedA, "and", relayedB, "and dropped", droppedA, "and", droppedB)
+	check("relays pointed at each other don't loop", relayedA == 1 && relayedB == 1 && droppedA == 1 && droppedB == 0)
+	relayA.Close()
+	relayB.Close()
+
+	// MaxPerSecond limits everything together
+	channel := dell.NewChannelSource()
+	limited := &relay.Relay{Source: channel, Targets: []string{listener.Addr().String()}, MaxPerSecond: 2}
+	done := make(chan error, 1)
+	go func() { done <- limited.Run() }()
+	for i := 0; i < 5; i++ {
+		channel.Send([]byte("AMXB<-UUID=BURST"+strconv.Itoa(i)+"><-SDKClass=Utility>"), "127.0.0.3")
+	}
+	time.Sleep(100 * time.Millisecond)
+	relayed, dropped = limited.Stats()
+	check("MaxPerSecond limits the total", relayed == 2 && dropped == 3)
+	limited.Close()
+	select {
+	case err := <-done:
+		check("Run returns when the source is closed", errors.Is(err, dell.ErrSourceClosed))
+	case <-time.After(time.Second):
+		check("Run returns when the source is closed", false)
+	}
+
+	err = (&relay.Relay{Source: dell.NewChannelSource()}).Run()
+	check("relay with nowhere to send fails", errors.Is(err, relay.ErrNoTargets))
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+)
+
+// This scans for projectors instead of listening for beacons. It starts a handful of fake projectors on loopback
+// ports, plus things on other ports that aren't projectors: a web server, something that sends CIP-looking packets
+// without ever doing the CIP handshake, and something that does the handshake but turns us down. It scans 127.0.0.1
+// on all of those ports, one at a time (ScanConcurrency 0 should be treated as 1), and checks that only the projectors
+// are found, each with the right name, and that each one raises "projectorfound"
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+
+	dell.ScanPorts = nil
+	var fakes []*emulator.FakeProjector
+	for i := 1; i <= 5; i++ {
+		fake := emulator.New(fmt.Sprintf("SCAN%d", i))
+		fake.Update(func(s *emulator.State) {
+			s.Name = fmt.Sprintf("Room %d", i)
+			s.Network.MAC = fmt.Sprintf("02:00:00:00:00:%02X", i)
+		})
+		err = fake.Listen("127.0.0.1:0")
+		if err != nil {
+			fmt.Println("Error starting fake projector:", err)
+			os.Exit(1)
+		}
+		defer fake.Close()
+		fakes = append(fakes, fake)
+		dell.ScanPorts = append(dell.ScanPorts, fake.Port())
+	}
+
+	// Something that answers, but doesn't speak CIP
+	other, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer other.Close()
+	go func() {
+		for {
+			conn, err := other.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("HTTP/1.0 400 Bad Request\r\n\r\n"))
+			conn.Close()
+		}
+	}()
+	dell.ScanPorts = append(dell.ScanPorts, other.Addr().(*net.TCPAddr).Port)
+
+	// Something that sends feedback, but never answers a connect request
+	dell.ScanPorts = append(dell.ScanPorts, impostor(func(conn net.Conn, packetType byte) {
+		// The "Name" serial join, saying "Impostor"
+		conn.Write(append([]byte{0x05, 0x00, 0x0f, 0x00, 0x00, 0x0c, 0x15, 0x13, 0xb9, 0x03}, "Impostor"...))
+	}))
+	// Something that does the handshake, but won't let us in
+	dell.ScanPorts = append(dell.ScanPorts, impostor(func(conn net.Conn, packetType byte) {
+		if packetType == 0x01 {
+			conn.Write([]byte{0x02, 0x00, 0x03, 0xff, 0xff, 0x02})
+		}
+	}))
+	dell.ScanConcurrency = 0
+	dell.ScanTimeout = 500 * time.Millisecond
+
+	events, unsubscribe := dell.Subscribe()
+	defer unsubscribe()
+	var foundEvents int
+	var lock sync.Mutex
+	go func() {
+		for e := range events {
+			if e.Name == "projectorfound" {
+				lock.Lock()
+				foundEvents++
+				lock.Unlock()
+			}
+		}
+	}()
+
+	start := time.Now()
+	found, err := dell.Scan(context.Background(), "127.0.0.1/32")
+	fmt.Println("Scanned", len(dell.ScanPorts), "ports in", time.Since(start))
+	if err != nil {
+		fmt.Println("Error scanning:", err)
+		os.Exit(1)
+	}
+	names := make(map[string]bool)
+	for _, p := range found {
+		fmt.Printf("Found %s (%s) at %s:%d, firmware %s\n", p.UUID, p.Name, p.IP, p.Port, p.Revision)
+		names[p.Name] = true
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = dell.Scan(cancelled, "10.0.0.0/24")
+	fmt.Println("Cancelled scan returned:", err)
+
+	time.Sleep(100 * time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	if len(found) != len(fakes) || len(names) != len(fakes) || foundEvents != len(fakes) || err != context.Canceled {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// impostor listens on a loopback port and calls answer with the type of every packet it's sent. It returns the port
+func impostor(answer func(conn net.Conn, packetType byte)) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error starting impostor:", err)
+		os.Exit(1)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				header := make([]byte, 3)
+				for {
+					if _, err := io.ReadFull(conn, header); err != nil {
+						return
+					}
+					if _, err := io.ReadFull(conn, make([]byte, int(header[1])<<8|int(header[2]))); err != nil {
+						return
+					}
+					answer(conn, header[0])
+				}
+			}()
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/serial"
+)
+
+// This starts a fake serial device server, adds it as a projector using the serial driver, then turns it on,
+// changes the input and checks that the driver reads the changes back.
+//
+// The fake is built from the same tables as the driver, so this only shows that they agree. Once you've copied the
+// codes from your projector's manual into serial.CommandList and serial.QueryList, pass your device server's address
+// to see what the driver reads from a real projector: go run ./tests/serial 10.0.0.70:4001
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	dell.WarmUpTime = time.Second
+	serial.PollInterval = 500 * time.Millisecond
+
+	if len(os.Args) > 1 {
+		device(os.Args[1])
+		return
+	}
+
+	fake := &serial.FakeServer{Input: "VGAA", LampHours: 1234}
+	err = fake.Listen("127.0.0.1:0")
+	if err != nil {
+		fmt.Println("Error starting fake serial server:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Fake serial server listening on", fake.Addr())
+
+	add(fake.Addr())
+	time.Sleep(time.Second)
+	show()
+
+	fmt.Println("Turning on and switching to HDMI..")
+	projector, _ := dell.GetProjector("serial")
+	_, err = dell.SetPower(projector, true)
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+	_, err = dell.SetInput(projector, "HDMI")
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+	time.Sleep(2 * time.Second)
+	show()
+
+	fmt.Println("Commands received by the fake server:", fake.Received())
+	projector, _ = dell.GetProjector("serial")
+	if projector.PowerState != dell.PowerOn || projector.Source != "HDMI" || projector.LampHours != 1234 {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+func show() {
+	p, _ := dell.GetProjector("serial")
+	fmt.Printf("Power: %s, Source: %s, Lamp hours: %d\n", p.PowerState, p.Source, p.LampHours)
+}
+
+// device polls a projector behind the device server at addr, without sending it any commands, and prints what the
+// driver makes of its answers
+func device(addr string) {
+	add(addr)
+	time.Sleep(serial.Timeout + time.Second)
+	show()
+	p, _ := dell.GetProjector("serial")
+	if p.PowerState == dell.PowerUnknown && p.Source == "" && p.LampHours == 0 {
+		fmt.Println("FAIL: the projector didn't answer any of the queries. Check serial.QueryList against its manual")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// add adds the device server at addr as a projector, using the serial driver
+func add(addr string) {
+	host, port, _ := net.SplitHostPort(addr)
+	portNumber, _ := strconv.Atoi(port)
+	_, err := dell.AddProjector(dell.Projector{UUID: "serial", IP: host, Port: portNumber, Protocol: serial.Protocol})
+	if err != nil {
+		fmt.Println("Error connecting to serial server:", err)
+		os.Exit(1)
+	}
+}
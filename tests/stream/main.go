@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/emulator"
+	"github.com/Grayda/go-dell/stream"
+)
+
+// This serves events from a fake projector with the stream package, and reads them back as a browser would. It checks
+// the Server-Sent Events framing, the filters, carrying on from a Last-Event-ID, the reset event for IDs from before a
+// restart or older than the history, and the WebSocket handshake and framing
+
+// frame is a single Server-Sent Event
+type frame struct {
+	ID    string
+	Event string
+	Data  message
+}
+
+// message is the part of a stream.Event we look at. The projector in it can't be unmarshalled, as its Conn and Driver
+// are interfaces
+type message struct {
+	ID   string `json:"id"`
+	Name string `json:"event"`
+	UUID string `json:"uuid"`
+}
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	failed := false
+	check := func(what string, ok bool) {
+		if ok {
+			fmt.Println("  OK:", what)
+			return
+		}
+		fmt.Println("  FAIL:", what)
+		failed = true
+	}
+
+	events := stream.New(100)
+	defer events.Close()
+	server := httptest.NewServer(events)
+	defer server.Close()
+
+	fake := emulator.New("STREAM01")
+	err = fake.Listen("127.0.0.1:0")
+	if err == nil {
+		_, err = dell.AddProjector(dell.Projector{UUID: "STREAM01", IP: "127.0.0.1", Port: fake.Port()})
+	}
+	if err != nil {
+		fmt.Println("Error starting fake projector:", err)
+		os.Exit(1)
+	}
+	defer fake.Close()
+	projector, _ := dell.GetProjector("STREAM01")
+	dell.GetStatus(projector)
+	time.Sleep(300 * time.Millisecond)
+
+	// Everything so far, framed properly
+	history := read(server.URL, "", 500*time.Millisecond)
+	check("a new client is sent the history", len(history) > 1 && history[0].Event == "projectoradded")
+	framed := len(history) > 0
+	for _, f := range history {
+		framed = framed && f.ID == f.Data.ID && f.Event == f.Data.Name && f.Data.UUID == "STREAM01"
+	}
+	check("each event has matching id, event and data lines", framed)
+	epoch, _, _ := strings.Cut(history[0].ID, "-")
+	check("IDs start with the stream's epoch", epoch != "" && strings.HasPrefix(history[len(history)-1].ID, epoch+"-"))
+
+	// Filters
+	added := read(server.URL+"?event=projectoradded", "", 300*time.Millisecond)
+	check("?event= only sends that event", len(added) == 1 && added[0].Event == "projectoradded")
+	nobody := read(server.URL+"?projector=NOPE,NOBODY", "", 300*time.Millisecond)
+	check("?projector= leaves out other projectors", len(nobody) == 0)
+
+	// Carrying on from where we were, including events that happen while we're away
+	last := history[len(history)-1].ID
+	fake.Update(func(s *emulator.State) { s.LampHours++ })
+	time.Sleep(200 * time.Millisecond)
+	resumed := read(server.URL, last, 300*time.Millisecond)
+	check("Last-Event-ID carries on with the events after it", len(resumed) > 0 && resumed[0].Data.Name == "propertychanged" &&
+		resumed[0].ID != last && resumed[0].ID != history[0].ID)
+	query := read(server.URL+"?lastEventId="+last, "", 300*time.Millisecond)
+	check("?lastEventId does too", len(query) == len(resumed))
+
+	// IDs we can't carry on from
+	restarted := read(server.URL, "abc123-500", 300*time.Millisecond)
+	check("an ID from before a restart gets a reset, then the history",
+		len(restarted) == len(history)+len(resumed)+1 && restarted[0].Event == stream.ResetEvent && restarted[1].ID == history[0].ID)
+	small := stream.New(2)
+	defer small.Close()
+	smallServer := httptest.NewServer(small)
+	defer smallServer.Close()
+	fake.Update(func(s *emulator.State) { s.LampHours++ })
+	time.Sleep(100 * time.Millisecond)
+	first := read(smallServer.URL, "", 300*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		fake.Update(func(s *emulator.State) { s.LampHours++ })
+		time.Sleep(100 * time.Millisecond)
+	}
+	gap := read(smallServer.URL, first[0].ID, 300*time.Millisecond)
+	check("an ID older than the history gets a reset, then the history", len(first) == 1 && len(gap) == 3 &&
+		gap[0].Event == stream.ResetEvent)
+	again := read(smallServer.URL, gap[0].ID, 300*time.Millisecond)
+	check("carrying on from the reset gets the history without another reset", len(again) == 2 && again[0].ID == gap[1].ID)
+	filtered := read(smallServer.URL+"?event=nothing", "nope-1", 300*time.Millisecond)
+	check("reset events get through any filter", len(filtered) == 1 && filtered[0].Event == stream.ResetEvent)
+
+	// WebSockets
+	everything := read(server.URL, "", 300*time.Millisecond)
+	accept, messages, ok := webSocket(server.URL)
+	check("WebSocket handshake is answered with the right Sec-WebSocket-Accept", accept == "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+	check("WebSocket sends each event as a JSON text frame", len(messages) == len(everything) &&
+		messages[0].ID == history[0].ID)
+	check("WebSocket answers a close with a close", ok)
+
+	if failed {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// read connects as a Server-Sent Events client (with a Last-Event-ID header, if lastID isn't empty) and returns every
+// event that arrives within wait
+func read(url string, lastID string, wait time.Duration) []frame {
+	req, _ := http.NewRequest("GET", url, nil)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		fmt.Println("Error connecting to stream:", err)
+		os.Exit(1)
+	}
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		fmt.Println("FAIL: stream isn't text/event-stream, it's", resp.Header.Get("Content-Type"))
+		os.Exit(1)
+	}
+	time.AfterFunc(wait, func() { resp.Body.Close() })
+
+	var frames []frame
+	var f frame
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch field {
+		case "id":
+			f.ID = value
+		case "event":
+			f.Event = value
+		case "data":
+			json.Unmarshal([]byte(value), &f.Data)
+		case "":
+			// A blank line ends the event
+			if f.Event != "" {
+				frames = append(frames, f)
+			}
+			f = frame{}
+		}
+	}
+	return frames
+}
+
+// webSocket connects with a WebSocket using the example key from RFC 6455, reads events for a while, then closes the
+// connection. It returns the Sec-WebSocket-Accept header, the events, and whether the close was answered
+func webSocket(url string) (string, []message, bool) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		fmt.Println("Error connecting to stream:", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		return "", nil, false
+	}
+	accept := resp.Header.Get("Sec-WebSocket-Accept")
+
+	// readFrame reads one unmasked frame from the server
+	readFrame := func() (byte, []byte, error) {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return 0, nil, err
+		}
+		length := uint64(header[1] & 0x7f)
+		switch length {
+		case 126:
+			extended := make([]byte, 2)
+			io.ReadFull(r, extended)
+			length = uint64(binary.BigEndian.Uint16(extended))
+		case 127:
+			extended := make([]byte, 8)
+			io.ReadFull(r, extended)
+			length = binary.BigEndian.Uint64(extended)
+		}
+		payload := make([]byte, length)
+		_, err := io.ReadFull(r, payload)
+		return header[0] & 0x0f, payload, err
+	}
+
+	var messages []message
+	for {
+		conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		opcode, payload, err := readFrame()
+		if err != nil {
+			break
+		}
+		var event message
+		if opcode == 0x1 && json.Unmarshal(payload, &event) == nil {
+			messages = append(messages, event)
+		}
+	}
+
+	// Frames from the client have to be masked. A zero mask leaves the (empty) payload as it is
+	conn.Write([]byte{0x88, 0x80, 0, 0, 0, 0})
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	opcode, _, err := readFrame()
+	return accept, messages, err == nil && opcode == 0x8
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Grayda/go-dell"
+	"github.com/Grayda/go-dell/webui"
+)
+
+// This runs the fake web pages with httptest, adds them as a projector using the web UI driver, then turns it on,
+// changes the input, mutes it and renames it, checking that the driver scrapes each change back, and that the
+// projector shows up as online (without a Conn) until it's removed. It then scrapes a hand-written status page that's
+// laid out differently to the fake's own.
+//
+// The fake is built from the same variables as the driver, so this only shows that they agree. To see how the driver
+// gets on with a real projector's status page, save it and pass it in: go run ./tests/webui status.htm
+
+// handWritten is a status page in a different style to Fake's: header cells, colons, nested tags and entities
+const handWritten = `<html><body><table border="0">
+<tr><th align="left">Projector Name:</th><td class="value"><b>Lab &amp; Studio</b></td></tr>
+<tr><th>Power Status:</th>
+    <td>Warming Up</td></tr>
+<tr><th>Lamp Hours:</th><td><span id="lamp">1203</span> Hours</td></tr>
+<tr><th>Input Source:</th><td>HDMI</td></tr>
+<tr><th>Audio Mute:</th><td>Muted</td></tr>
+</table></body></html>`
+
+func main() {
+	_, err := dell.Init()
+	if err != nil {
+		fmt.Println("Error preparing commands. Error is:", err)
+		os.Exit(1)
+	}
+	dell.WarmUpTime = time.Second
+	webui.PollInterval = 500 * time.Millisecond
+
+	if len(os.Args) > 1 {
+		savedPage(os.Args[1])
+		return
+	}
+
+	fake := &webui.Fake{
+		Password:  "admin",
+		Power:     "Standby",
+		Source:    "VGA-A",
+		LampHours: 812,
+		LampMode:  "Eco Mode",
+		Name:      "Room 9",
+		Model:     "S500wi",
+		Revision:  "1.0.3",
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	fmt.Println("Fake web pages at", server.URL)
+
+	add("webui", server, "admin")
+	time.Sleep(time.Second)
+	show("webui")
+	projector, _ := dell.GetProjector("webui")
+	online := projector.Conn == nil && projector.Connected()
+
+	fmt.Println("Turning on, switching to HDMI and muting..")
+	check(dell.SetPower(projector, true))
+	check(dell.SetInput(projector, "HDMI"))
+	check(dell.SetMute(projector, true))
+	time.Sleep(2 * time.Second)
+
+	fmt.Println("Logging everyone out, then renaming..")
+	fake.Expire()
+	err = webui.SetName(projector, "Room 9 (North)", "Level 2")
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+	show("webui")
+
+	fmt.Println("Buttons pressed:", fake.Pressed())
+	projector, _ = dell.GetProjector("webui")
+	ok := projector.PowerState == dell.PowerOn && projector.Source == "HDMI" && projector.VolumeMuted &&
+		projector.Name == "Room 9 (North)" && projector.Location == "Level 2" && projector.LampMode == dell.LampEco
+
+	// Removing the projector closes the driver, so it's offline (and a command says so)
+	dell.RemoveProjector(projector)
+	_, err = dell.SetMute(projector, false)
+	fmt.Println("Online while added:", online, "and after removing:", projector.Connected(), "with", err)
+	ok = ok && online && !projector.Connected() && err == dell.ErrOffline
+
+	// A status page laid out differently
+	other := httptest.NewServer(&webui.Fake{Page: handWritten})
+	defer other.Close()
+	add("handwritten", other, "")
+	time.Sleep(500 * time.Millisecond)
+	show("handwritten")
+	scraped, _ := dell.GetProjector("handwritten")
+	ok = ok && scraped.Name == "Lab & Studio" && scraped.LampHours == 1203 && scraped.Source == "HDMI" &&
+		scraped.VolumeMuted && scraped.PowerState == dell.PowerWarmingUp
+
+	if !ok {
+		fmt.Println("FAIL")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// savedPage serves a status page saved from a real projector, and prints what the driver can make of it
+func savedPage(path string) {
+	page, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("Error reading saved page:", err)
+		os.Exit(1)
+	}
+	server := httptest.NewServer(&webui.Fake{Page: string(page)})
+	defer server.Close()
+	add("saved", server, "")
+	time.Sleep(500 * time.Millisecond)
+
+	p, _ := dell.GetProjector("saved")
+	found := 0
+	for _, field := range []struct {
+		Name  string
+		Value interface{}
+		Found bool
+	}{
+		{"Power", p.PowerState, p.PowerState != dell.PowerUnknown},
+		{"Source", p.Source, p.Source != ""},
+		{"Lamp hours", p.LampHours, p.LampHours != 0},
+		{"Lamp mode", p.LampMode, p.LampMode != dell.LampUnknown},
+		{"Name", p.Name, p.Name != "saved"},
+		{"Location", p.Location, p.Location != ""},
+		{"Model", p.Model, p.Model != ""},
+		{"Firmware", p.Revision, p.Revision != ""},
+		{"Resolution", p.Resolution, p.Resolution != ""},
+		{"Volume muted", p.VolumeMuted, p.VolumeMuted},
+		{"Picture muted", p.PictureMuted, p.PictureMuted},
+	} {
+		if field.Found {
+			found++
+			fmt.Printf("  %s: %v\n", field.Name, field.Value)
+		} else {
+			fmt.Printf("  %s: not found (or off). Check its label in webui.StatusLabels\n", field.Name)
+		}
+	}
+	if found == 0 {
+		fmt.Println("FAIL: nothing was scraped from the page")
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
+
+// add adds a projector that uses the web UI driver, served by server
+func add(uuid string, server *httptest.Server, password string) {
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	_, err := dell.AddProjector(dell.Projector{UUID: uuid, IP: host, Port: portNumber, Protocol: webui.Protocol, Password: password})
+	if err != nil {
+		fmt.Println("Error connecting to fake web pages:", err)
+		os.Exit(1)
+	}
+}
+
+func check(_ bool, err error) {
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+}
+
+func show(uuid string) {
+	p, _ := dell.GetProjector(uuid)
+	fmt.Printf("Name: %s, Location: %s, Power: %s, Source: %s, Muted: %t, Lamp: %d hours (%s)\n", p.Name, p.Location, p.PowerState, p.Source, p.VolumeMuted, p.LampHours, p.LampMode)
+}
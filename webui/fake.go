@@ -0,0 +1,190 @@
+package webui
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Fake mimics a projector's web pages: the login form, the status page and the forms behind the buttons. It's an
+// http.Handler, so it can be run with httptest.NewServer. It understands the forms in ActionList, and shows the labels
+// in StatusLabels, so it follows any changes made to them. That also means it can't tell you whether they're right
+// (see the package documentation)
+type Fake struct {
+	Password string // If it's set, the pages can't be used until it's been posted to LoginPath
+
+	// The status the pages show
+	Power        string // "On", "Standby", "Warming Up" or "Cooling Down"
+	Source       string
+	LampHours    int
+	LampMode     string
+	Name         string
+	Location     string
+	Model        string
+	Revision     string
+	Resolution   string
+	VolumeMuted  bool
+	PictureMuted bool
+
+	// Page is served as the status page instead of one built from the fields above, if it's set. Use it to try the
+	// driver on a page saved from a real projector
+	Page string
+
+	lock     sync.Mutex
+	sessions map[string]bool
+	pressed  []string
+}
+
+// ServeHTTP serves the fake pages
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if r.URL.Path == LoginPath {
+		f.login(w, r)
+		return
+	}
+
+	if f.Password != "" {
+		cookie, err := r.Cookie("session")
+		if err != nil || !f.sessions[cookie.Value] {
+			http.Error(w, "Please log in", http.StatusForbidden)
+			return
+		}
+	}
+
+	switch r.URL.Path {
+	case StatusPath:
+		f.status(w)
+	case ControlPath:
+		f.control(w, r)
+	case SettingsPath:
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		f.Name = r.PostFormValue(NameField)
+		f.Location = r.PostFormValue(LocationField)
+		fmt.Fprint(w, "<html><body>Settings saved</body></html>")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Pressed returns the names (in lower case) of the buttons that have been pressed, in order
+func (f *Fake) Pressed() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.pressed...)
+}
+
+// Expire logs everyone out, as if their sessions had timed out
+func (f *Fake) Expire() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.sessions = nil
+}
+
+func (f *Fake) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.PostFormValue(PasswordField) != f.Password {
+		http.Error(w, "Wrong password", http.StatusForbidden)
+		return
+	}
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	session := hex.EncodeToString(buf)
+	if f.sessions == nil {
+		f.sessions = make(map[string]bool)
+	}
+	f.sessions[session] = true
+	http.SetCookie(w, &http.Cookie{Name: "session", Value: session, Path: "/"})
+	fmt.Fprint(w, "<html><body>Logged in</body></html>")
+}
+
+func (f *Fake) status(w http.ResponseWriter) {
+	if f.Page != "" {
+		fmt.Fprint(w, f.Page)
+		return
+	}
+
+	var labels map[string]string
+	json.Unmarshal(StatusLabels, &labels)
+
+	onOff := func(b bool) string {
+		if b {
+			return "On"
+		}
+		return "Off"
+	}
+	values := map[string]string{
+		"PowerState":   f.Power,
+		"Source":       f.Source,
+		"LampHours":    fmt.Sprintf("%d Hours", f.LampHours),
+		"LampMode":     f.LampMode,
+		"Name":         f.Name,
+		"Location":     f.Location,
+		"Model":        f.Model,
+		"Revision":     f.Revision,
+		"Resolution":   f.Resolution,
+		"VolumeMuted":  onOff(f.VolumeMuted),
+		"PictureMuted": onOff(f.PictureMuted),
+	}
+
+	fmt.Fprint(w, "<html><head><title>Projector Status</title></head><body>\n<table class=\"status\">\n")
+	for field, label := range labels {
+		fmt.Fprintf(w, "<tr><td class=\"label\">%s</td><td><span>%s</span></td></tr>\n", html.EscapeString(label), html.EscapeString(values[field]))
+	}
+	fmt.Fprint(w, "</table>\n</body></html>")
+}
+
+func (f *Fake) control(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+
+	var actions map[string]string
+	json.Unmarshal(ActionList, &actions)
+	for name, form := range actions {
+		values, err := url.ParseQuery(form)
+		if err != nil || !sameForm(values, r.PostForm) {
+			continue
+		}
+
+		full := name
+		name = strings.ToLower(name)
+		f.pressed = append(f.pressed, name)
+		switch {
+		case name == "power.on":
+			f.Power = "On"
+		case name == "power.off":
+			f.Power = "Standby"
+		case strings.HasPrefix(name, "input."):
+			f.Source = full[len("input."):]
+		case name == "volume.mute" || name == "volume.unmute":
+			f.VolumeMuted = name == "volume.mute"
+		case name == "picture.mute" || name == "picture.unmute":
+			f.PictureMuted = name == "picture.mute"
+		}
+		fmt.Fprint(w, "<html><body>OK</body></html>")
+		return
+	}
+	http.Error(w, "Unknown button", http.StatusBadRequest)
+}
+
+// sameForm tells us whether every field in want was posted with the same value
+func sameForm(want url.Values, got url.Values) bool {
+	for field := range want {
+		if got.Get(field) != want.Get(field) {
+			return false
+		}
+	}
+	return true
+}
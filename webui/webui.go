@@ -0,0 +1,398 @@
+// Package webui is a dell.Driver that controls projectors through their built in web pages, for networks where the
+// CIP port (41794) is firewalled but HTTP isn't. Buttons are pressed by posting the same forms the web pages do, and
+// status is scraped from the status page.
+//
+// Importing this package registers the driver:
+//
+//	dell.AddProjector(dell.Projector{UUID: "room-9", IP: "10.0.0.90", Protocol: webui.Protocol, Password: "admin"})
+//
+// The paths, form fields and labels that the driver looks for are all in exported variables, as they change a little
+// between firmware versions. Fake mimics the pages, for testing.
+//
+// None of the defaults have been checked against a real projector's pages. They're placeholders in the shape the
+// driver expects, and Fake is built from the same variables, so a test against Fake only shows that the two agree.
+// Before relying on the driver, save the status page from one of your projectors and run tests/webui with it
+// (go run ./tests/webui status.htm), which prints what the driver manages to scrape from it, and fix StatusLabels
+// until everything's there. Then press each button in a browser with its developer tools open, and copy the requests
+// it makes into the paths and ActionList. Some projectors' web pages are a Flash application (Crestron e-Control)
+// that talks CIP to port 41794 itself, with no forms to post. The driver can't help with those
+package webui
+
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Grayda/go-dell"
+)
+
+// Protocol is the name the web UI driver is registered under
+const Protocol = "webui"
+
+// Port is the port used if Projector.Port isn't set
+var Port = 80
+
+// PollInterval is how often the driver scrapes the status page
+var PollInterval = 10 * time.Second
+
+// Timeout is how long we wait for the projector's web server to answer
+var Timeout = 5 * time.Second
+
+// The pages the driver uses
+var (
+	LoginPath    = "/login.cgi"    // Where the password is posted to (as PasswordField)
+	StatusPath   = "/status.htm"   // The page that's scraped for status
+	ControlPath  = "/control.cgi"  // Where the forms in ActionList are posted to
+	SettingsPath = "/settings.cgi" // Where the name and location are posted to (as NameField and LocationField)
+)
+
+// The form fields for logging in and changing the name and location
+var (
+	PasswordField = "pwd"
+	NameField     = "name"
+	LocationField = "location"
+)
+
+// ErrLoginFailed is returned when the projector doesn't accept the password
+var ErrLoginFailed = errors.New("webui: login failed")
+
+// ActionList maps the names in dell.Commands to the form that's posted to ControlPath when the matching button is
+// pressed on the web page. Each form is written like a query string.
+// It's exported so that you can overwrite it if necessary
+var ActionList = []byte(`
+  {
+	"Power.On": "btn=power&value=on",
+	"Power.Off": "btn=power&value=off",
+	"Input.VGAA": "btn=source&value=vgaa",
+	"Input.VGAB": "btn=source&value=vgab",
+	"Input.Composite": "btn=source&value=composite",
+	"Input.SVideo": "btn=source&value=svideo",
+	"Input.HDMI": "btn=source&value=hdmi",
+	"Input.Wireless": "btn=source&value=wireless",
+	"Input.USBDisplay": "btn=source&value=usbdisplay",
+	"Input.USBViewer": "btn=source&value=usbviewer",
+	"Volume.Up": "btn=volume&value=up",
+	"Volume.Down": "btn=volume&value=down",
+	"Volume.Mute": "btn=audiomute&value=on",
+	"Volume.Unmute": "btn=audiomute&value=off",
+	"Picture.Mute": "btn=videomute&value=on",
+	"Picture.Unmute": "btn=videomute&value=off",
+	"Picture.Freeze": "btn=freeze&value=on",
+	"Picture.Unfreeze": "btn=freeze&value=off"
+}
+`)
+
+// StatusLabels maps Projector fields to the label that's next to them on the status page. The status page is a
+// table, and the value is taken from the cell after the label
+var StatusLabels = []byte(`
+  {
+	"PowerState": "Power Status",
+	"Source": "Input Source",
+	"LampHours": "Lamp Hours",
+	"LampMode": "Lamp Mode",
+	"Name": "Projector Name",
+	"Location": "Projector Location",
+	"Model": "Model Name",
+	"Revision": "Firmware Version",
+	"Resolution": "Resolution",
+	"VolumeMuted": "Audio Mute",
+	"PictureMuted": "Video Mute"
+}
+`)
+
+func init() {
+	dell.RegisterDriver(Protocol, func() dell.Driver { return &Driver{} })
+}
+
+// Driver is a dell.Driver that uses the projector's web pages
+type Driver struct {
+	client   *http.Client
+	base     string
+	password string
+	uuid     string
+	actions  map[string]url.Values // Keyed by lower case name
+	labels   map[string]string
+	polled   bool
+
+	lock      sync.Mutex
+	connected bool // From a successful Connect until Close
+}
+
+// Connect logs in to the projector's web pages (if it has a password) and checks that the status page can be read.
+// HTTP doesn't keep a connection open, so there's no net.Conn to hand back and Projector.Conn stays nil. The driver
+// is dell.Connectionless instead, so dell still knows the projector is online
+func (d *Driver) Connect(projector dell.Projector) (net.Conn, error) {
+	err := d.load()
+	if err != nil {
+		return nil, err
+	}
+
+	port := projector.Port
+	if port == 0 {
+		port = Port
+	}
+	jar, _ := cookiejar.New(nil)
+	d.client = &http.Client{Jar: jar, Timeout: Timeout}
+	d.base = "http://" + net.JoinHostPort(projector.IP, strconv.Itoa(port))
+	d.password = projector.Password
+	d.uuid = projector.UUID
+
+	if d.password != "" {
+		err = d.login()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = d.page()
+	if err != nil {
+		return nil, err
+	}
+
+	d.lock.Lock()
+	d.connected = true
+	d.lock.Unlock()
+	return nil, nil
+}
+
+// Connected is true from when Connect succeeds until the projector is removed (see dell.Connectionless)
+func (d *Driver) Connected() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.connected
+}
+
+// load unmarshals ActionList and StatusLabels
+func (d *Driver) load() error {
+	var actions map[string]string
+	err := json.Unmarshal(ActionList, &actions)
+	if err != nil {
+		return err
+	}
+	d.actions = make(map[string]url.Values)
+	for name, form := range actions {
+		values, err := url.ParseQuery(form)
+		if err != nil {
+			return err
+		}
+		d.actions[strings.ToLower(name)] = values
+	}
+	return json.Unmarshal(StatusLabels, &d.labels)
+}
+
+// Read scrapes the status page every PollInterval, until the driver is closed. If the page can't be read, the
+// error is passed back and dell removes the projector
+func (d *Driver) Read(projector dell.Projector) error {
+	if d.polled {
+		time.Sleep(PollInterval)
+	}
+	d.polled = true
+	if !d.Connected() {
+		return net.ErrClosed
+	}
+	return d.Status()
+}
+
+func (d *Driver) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.connected = false
+	return nil
+}
+
+func (d *Driver) PowerOn() error {
+	return d.action("Power.On")
+}
+
+func (d *Driver) PowerOff() error {
+	return d.action("Power.Off")
+}
+
+func (d *Driver) SetInput(input string) error {
+	return d.action("Input." + input)
+}
+
+func (d *Driver) Mute(muted bool) error {
+	if muted {
+		return d.action("Volume.Mute")
+	}
+	return d.action("Volume.Unmute")
+}
+
+// Send presses the button for a command from dell.Commands, if the web pages have one
+func (d *Driver) Send(command string) error {
+	return d.action(dell.CommandName(command))
+}
+
+// SetName changes a projector's name and location through its web pages. The projector has to be using this driver
+func SetName(projector dell.Projector, name string, location string) error {
+	if current, ok := dell.GetProjector(projector.UUID); ok {
+		projector = current
+	}
+	d, ok := projector.Driver.(*Driver)
+	if !ok {
+		return dell.ErrUnsupported
+	}
+	err := d.post(SettingsPath, url.Values{NameField: {name}, LocationField: {location}})
+	if err != nil {
+		return err
+	}
+	return d.Status()
+}
+
+// action posts the form for a command
+func (d *Driver) action(name string) error {
+	form, ok := d.actions[strings.ToLower(name)]
+	if !ok {
+		return dell.ErrUnsupported
+	}
+	return d.post(ControlPath, form)
+}
+
+// login posts the password. The projector gives us a session cookie, which the cookie jar hangs on to
+func (d *Driver) login() error {
+	resp, err := d.client.PostForm(d.base+LoginPath, url.Values{PasswordField: {d.password}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ErrLoginFailed
+	}
+	return nil
+}
+
+// post posts a form, logging in again if the session has expired
+func (d *Driver) post(path string, form url.Values) error {
+	for attempt := 0; ; attempt++ {
+		resp, err := d.client.PostForm(d.base+path, form)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		switch {
+		case expired(resp) && attempt == 0 && d.password != "":
+			err = d.login()
+			if err != nil {
+				return err
+			}
+		case resp.StatusCode >= 300:
+			return errors.New("webui: " + resp.Status)
+		default:
+			return nil
+		}
+	}
+}
+
+// page fetches the status page, logging in again if the session has expired
+func (d *Driver) page() (string, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := d.client.Get(d.base + StatusPath)
+		if err != nil {
+			return "", err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case expired(resp) && attempt == 0 && d.password != "":
+			err = d.login()
+			if err != nil {
+				return "", err
+			}
+		case resp.StatusCode >= 300:
+			return "", errors.New("webui: " + resp.Status)
+		default:
+			return string(body), nil
+		}
+	}
+}
+
+// expired tells us whether a response means we need to log in (again)
+func expired(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+}
+
+// Status scrapes the status page and passes what it finds on to dell.Projectors
+func (d *Driver) Status() error {
+	page, err := d.page()
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]string)
+	for field, label := range d.labels {
+		if value, ok := scrape(page, label); ok {
+			values[field] = value
+		}
+	}
+
+	dell.UpdateProjector(d.uuid, func(projector *dell.Projector) {
+		for field, value := range values {
+			switch field {
+			case "Source":
+				projector.Source = value
+			case "LampHours":
+				if hours, ok := dell.ParseLampHours(value); ok {
+					projector.LampHours = hours
+				}
+			case "LampMode":
+				projector.LampMode = dell.ParseLampMode(value)
+			case "Name":
+				projector.Name = value
+			case "Location":
+				projector.Location = value
+			case "Model":
+				projector.Model = value
+			case "Revision":
+				projector.Revision = value
+			case "Resolution":
+				projector.Resolution = value
+			case "VolumeMuted":
+				projector.VolumeMuted = isOn(value)
+			case "PictureMuted":
+				projector.PictureMuted = isOn(value)
+			}
+		}
+	})
+
+	if state, ok := dell.ParsePowerState(values["PowerState"]); ok {
+		dell.ReportPowerState(d.uuid, state)
+	}
+	return nil
+}
+
+// tags matches HTML tags, so they can be stripped out of a table cell
+var tags = regexp.MustCompile(`<[^>]*>`)
+
+// scrape finds a label in a table on the page, and returns the text of the cell after it
+func scrape(page string, label string) (string, bool) {
+	cell := regexp.MustCompile(`(?is)>\s*` + regexp.QuoteMeta(label) + `\s*:?\s*</t[dh]>\s*<td[^>]*>(.*?)</td>`)
+	match := cell.FindStringSubmatch(page)
+	if match == nil {
+		return "", false
+	}
+	return strings.TrimSpace(html.UnescapeString(tags.ReplaceAllString(match[1], ""))), true
+}
+
+// isOn works out whether a cell like "On" or "Muted" means yes
+func isOn(value string) bool {
+	switch strings.ToLower(value) {
+	case "on", "yes", "muted", "enabled", "1":
+		return true
+	}
+	return false
+}